@@ -0,0 +1,303 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+)
+
+// 导出文件的格式版本，格式有不兼容的改动时加一
+const collectionVersion = 1
+
+// 保存请求集合的文件
+var collectionFile = "collections.json"
+
+// SavedRequest 是保存下来的一次调用，字段和调用历史的记录一致
+type SavedRequest struct {
+	Name           string            `json:"name"`
+	Service        string            `json:"service"`
+	Endpoint       string            `json:"endpoint"`
+	Transport      string            `json:"transport,omitempty"`
+	Address        string            `json:"address,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	Request        json.RawMessage   `json:"request"`
+	Timeout        string            `json:"timeout,omitempty"`
+	RequestTimeout string            `json:"request_timeout,omitempty"`
+	Retries        *int              `json:"retries,omitempty"`
+}
+
+// Collection 是一组有名字的请求
+type Collection struct {
+	Name     string          `json:"name"`
+	Requests []*SavedRequest `json:"requests"`
+}
+
+// collectionExport 是导出和导入的文件格式
+type collectionExport struct {
+	Version     int           `json:"version"`
+	Collections []*Collection `json:"collections"`
+}
+
+type collectionSet struct {
+	sync.RWMutex
+	file        string
+	collections []*Collection
+}
+
+var collections = &collectionSet{}
+
+// loadCollectionFile 加载保存的请求集合，文件不存在时在第一次保存时创建
+func loadCollectionFile(file string) error {
+	collections.Lock()
+	defer collections.Unlock()
+	collections.file = file
+	if len(file) == 0 {
+		return nil
+	}
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	export, err := parseCollectionExport(b)
+	if err != nil {
+		return fmt.Errorf("load %s: %v", file, err)
+	}
+	collections.collections = export.Collections
+	return nil
+}
+
+// parseCollectionExport 解析导出的文件，拒绝比当前版本新的文件
+func parseCollectionExport(b []byte) (*collectionExport, error) {
+	export := new(collectionExport)
+	if err := json.Unmarshal(b, export); err != nil {
+		return nil, err
+	}
+	if export.Version > collectionVersion {
+		return nil, fmt.Errorf("unsupported collection version %d", export.Version)
+	}
+	return export, nil
+}
+
+// save 把集合写回文件，调用时需持有锁
+func (s *collectionSet) save() error {
+	if len(s.file) == 0 {
+		return nil
+	}
+	b, err := json.MarshalIndent(s.export(), "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.file, b, 0600)
+}
+
+func (s *collectionSet) export() *collectionExport {
+	return &collectionExport{Version: collectionVersion, Collections: s.collections}
+}
+
+// collection 按名字找集合，没有时创建，调用时需持有锁
+func (s *collectionSet) collection(name string) *Collection {
+	for _, c := range s.collections {
+		if c.Name == name {
+			return c
+		}
+	}
+	c := &Collection{Name: name}
+	s.collections = append(s.collections, c)
+	sort.Slice(s.collections, func(i, j int) bool {
+		return s.collections[i].Name < s.collections[j].Name
+	})
+	return c
+}
+
+// put 保存请求，同一集合中同名的请求会被替换
+func (s *collectionSet) put(collection string, reqs ...*SavedRequest) error {
+	s.Lock()
+	defer s.Unlock()
+	c := s.collection(collection)
+	for _, req := range reqs {
+		req.Metadata = redactMetadata(req.Metadata)
+		replaced := false
+		for i, old := range c.Requests {
+			if old.Name == req.Name {
+				c.Requests[i] = req
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			c.Requests = append(c.Requests, req)
+		}
+	}
+	return s.save()
+}
+
+// get 找到集合中的请求，没有时返回 nil
+func (s *collectionSet) get(collection, name string) *SavedRequest {
+	s.RLock()
+	defer s.RUnlock()
+	for _, c := range s.collections {
+		if c.Name != collection {
+			continue
+		}
+		for _, req := range c.Requests {
+			if req.Name == name {
+				return req
+			}
+		}
+	}
+	return nil
+}
+
+// delete 删除集合中的请求，name 为空时删除整个集合
+func (s *collectionSet) delete(collection, name string) error {
+	s.Lock()
+	defer s.Unlock()
+	for i, c := range s.collections {
+		if c.Name != collection {
+			continue
+		}
+		if len(name) == 0 {
+			s.collections = append(s.collections[:i], s.collections[i+1:]...)
+			return s.save()
+		}
+		for j, req := range c.Requests {
+			if req.Name == name {
+				c.Requests = append(c.Requests[:j], c.Requests[j+1:]...)
+				return s.save()
+			}
+		}
+	}
+	return nil
+}
+
+// marshal 在锁内序列化，避免和修改同时进行
+func (s *collectionSet) marshal(indent bool) ([]byte, error) {
+	s.RLock()
+	defer s.RUnlock()
+	if indent {
+		return json.MarshalIndent(s.export(), "", "\t")
+	}
+	return json.Marshal(s.export())
+}
+
+// collectionHandler 显示请求集合，JSON 请求时返回集合
+// GET /collections?export=true 下载导出文件
+// GET /collections?collection=greeter&name=hello 返回一个请求
+// POST /collections?collection=greeter 保存请求
+// DELETE /collections?collection=greeter&name=hello 删除请求，不带 name 时删除集合
+func collectionHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "ParseForm err:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	collection := r.Form.Get("collection")
+	name := r.Form.Get("name")
+
+	switch r.Method {
+	case "POST":
+		req := new(SavedRequest)
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(collection) == 0 || len(req.Name) == 0 {
+			http.Error(w, "Error occurred: collection and name are required", http.StatusBadRequest)
+			return
+		}
+		if err := collections.put(collection, req); err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	case "DELETE":
+		if err := collections.delete(collection, name); err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if len(collection) > 0 && len(name) > 0 {
+		req := collections.get(collection, name)
+		if req == nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(req)
+		if err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+		return
+	}
+
+	export := r.Form.Get("export") == "true"
+	if !export && r.Header.Get("Content-Type") != "application/json" {
+		render(w, r, collectionTemplate, nil)
+		return
+	}
+	b, err := collections.marshal(export)
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if export {
+		w.Header().Set("Content-Disposition", `attachment; filename="collections.json"`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// importHandler 导入请求集合，支持导出的文件、Postman v2.1 集合和 grpcurl 命令
+// POST /collections/import?format=postman&collection=greeter
+func importHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "ParseForm err:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var b []byte
+	if f, _, err := r.FormFile("file"); err == nil {
+		defer f.Close()
+		b, err = ioutil.ReadAll(f)
+		if err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		if b, err = ioutil.ReadAll(r.Body); err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	imported, skipped, err := importCollections(r.Form.Get("format"), r.Form.Get("collection"), b)
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	count := 0
+	for _, c := range imported {
+		if err := collections.put(c.Name, c.Requests...); err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		count += len(c.Requests)
+	}
+
+	if skipped == nil {
+		skipped = []string{}
+	}
+	rsp, _ := json.Marshal(map[string]interface{}{
+		"imported": count,
+		"skipped":  skipped,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(rsp)
+}
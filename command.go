@@ -0,0 +1,22 @@
+package web
+
+// loadCommandFiles 加载 scenario、load 和 replay 命令共用的参数：字段名的写法、proto 描述、
+// 默认 metadata 和环境，naming 为空时使用 fieldNaming 当前的值，文件名为空时不加载
+func loadCommandFiles(naming string, descriptorSets, protoFiles []string, headerFile, environmentFile string) error {
+	if len(naming) > 0 {
+		fieldNaming = naming
+	}
+	if err := checkFieldNaming(fieldNaming); err != nil {
+		return err
+	}
+	if err := loadDescriptorFiles(descriptorSets); err != nil {
+		return err
+	}
+	if err := loadRegisteredDescriptors(protoFiles); err != nil {
+		return err
+	}
+	if err := loadHeaderFile(headerFile); err != nil {
+		return err
+	}
+	return loadEnvironmentFile(environmentFile)
+}
@@ -0,0 +1,21 @@
+package web
+
+import "testing"
+
+func TestLoadCommandFiles(t *testing.T) {
+	defer func(naming string) { fieldNaming = naming }(fieldNaming)
+
+	if err := loadCommandFiles("kebab", nil, nil, "", ""); err == nil {
+		t.Fatal("expected an error for an unknown field naming")
+	}
+	fieldNaming = namingCamel
+	if err := loadCommandFiles("", nil, nil, "", ""); err != nil || fieldNaming != namingCamel {
+		t.Fatalf("expected the current naming to be kept, got %s, %v", fieldNaming, err)
+	}
+	if err := loadCommandFiles(namingSnake, nil, []string{"missing.proto"}, "", ""); err == nil {
+		t.Fatal("expected an error for a proto file that is not registered")
+	}
+	if fieldNaming != namingSnake {
+		t.Fatalf("expected snake naming, got %s", fieldNaming)
+	}
+}
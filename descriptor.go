@@ -0,0 +1,403 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/micro/go-micro/registry"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// descriptorSet 保存已知的 proto 文件描述
+// 来源有两种：编译进来并通过 proto.RegisterFile 注册的文件，以及页面上传的 FileDescriptorSet
+type descriptorSet struct {
+	sync.RWMutex
+	files    map[string]*descriptor.FileDescriptorProto
+	messages map[string]*descriptor.DescriptorProto     // 按全名索引，如 .go.micro.srv.hello.Request
+	enums    map[string]*descriptor.EnumDescriptorProto // 按全名索引
+	methods  map[string][]*descriptor.MethodDescriptorProto
+	// 按全名索引生成的 Go 类型名，嵌套的消息为 Outer_Inner
+	goNames map[string]string
+	// gRPC 调用用到的 protoregistry.Files，加载新文件后重建
+	resolved *protoregistry.Files
+}
+
+var descriptors = newDescriptorSet()
+
+func newDescriptorSet() *descriptorSet {
+	return &descriptorSet{
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		methods:  make(map[string][]*descriptor.MethodDescriptorProto),
+		goNames:  make(map[string]string),
+	}
+}
+
+// RegisterDescriptor 加载一个已经通过 proto.RegisterFile 注册的文件，如 "hello.proto"
+func RegisterDescriptor(filename string) error {
+	gz := proto.FileDescriptor(filename)
+	if gz == nil {
+		return fmt.Errorf("proto file %s is not registered", filename)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	fd := new(descriptor.FileDescriptorProto)
+	if err := proto.Unmarshal(b, fd); err != nil {
+		return err
+	}
+	descriptors.add(fd)
+	return nil
+}
+
+// loadRegisteredDescriptors 加载启动参数指定的、编译进 dashboard 的 proto 文件
+func loadRegisteredDescriptors(names []string) error {
+	for _, name := range names {
+		if err := RegisterDescriptor(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadDescriptorSet 加载 protoc --descriptor_set_out 生成的文件内容
+func LoadDescriptorSet(b []byte) error {
+	set := new(descriptor.FileDescriptorSet)
+	if err := proto.Unmarshal(b, set); err != nil {
+		return err
+	}
+	for _, fd := range set.File {
+		descriptors.add(fd)
+	}
+	return nil
+}
+
+// loadDescriptorFiles 加载启动参数指定的 descriptor set 文件
+func loadDescriptorFiles(paths []string) error {
+	for _, path := range paths {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := LoadDescriptorSet(b); err != nil {
+			return fmt.Errorf("load %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+func (d *descriptorSet) add(fd *descriptor.FileDescriptorProto) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.files[fd.GetName()] = fd
+	d.resolved = nil
+	prefix := "."
+	if len(fd.GetPackage()) > 0 {
+		prefix += fd.GetPackage() + "."
+	}
+	for _, m := range fd.MessageType {
+		d.addMessage(prefix, "", m)
+	}
+	for _, e := range fd.EnumType {
+		d.enums[prefix+e.GetName()] = e
+	}
+	// go-micro 的 endpoint 名是 Service.Method，不带包名
+	for _, s := range fd.Service {
+		for _, m := range s.Method {
+			key := s.GetName() + "." + m.GetName()
+			d.methods[key] = append(d.methods[key], m)
+		}
+	}
+}
+
+func (d *descriptorSet) addMessage(prefix, goPrefix string, m *descriptor.DescriptorProto) {
+	name := prefix + m.GetName()
+	d.messages[name] = m
+	d.goNames[name] = goPrefix + m.GetName()
+	for _, n := range m.NestedType {
+		d.addMessage(name+".", goPrefix+m.GetName()+"_", n)
+	}
+	for _, e := range m.EnumType {
+		d.enums[name+"."+e.GetName()] = e
+	}
+}
+
+// Files 返回已加载的文件名
+func (d *descriptorSet) Files() []string {
+	d.RLock()
+	defer d.RUnlock()
+	var names []string
+	for name := range d.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// method 按 endpoint 名找到方法描述，用 registry 中的请求类型名确认是同一个方法
+// 类型名对不上时可能是别的包中的同名服务，返回 nil，不用错误的描述生成示例
+func (d *descriptorSet) method(ep *registry.Endpoint) *descriptor.MethodDescriptorProto {
+	d.RLock()
+	defer d.RUnlock()
+	ms := d.methods[ep.Name]
+	if ep.Request == nil {
+		// 没有类型可以比较，只有一个候选时才用它
+		if len(ms) == 1 {
+			return ms[0]
+		}
+		return nil
+	}
+	for _, m := range ms {
+		if d.goTypeName(m.GetInputType()) == ep.Request.Type {
+			return m
+		}
+	}
+	return nil
+}
+
+// methodFile 返回方法所在的文件和服务，用来取 proto 包名和 go_package
+func (d *descriptorSet) methodFile(m *descriptor.MethodDescriptorProto) (*descriptor.FileDescriptorProto, *descriptor.ServiceDescriptorProto) {
+	d.RLock()
+	defer d.RUnlock()
+	for _, fd := range d.files {
+		for _, s := range fd.Service {
+			for _, sm := range s.Method {
+				if sm == m {
+					return fd, s
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// goTypeName 返回消息生成的 Go 类型名，也就是 registry 中的类型名，如 .test.Outer.Inner 为 Outer_Inner
+// 没有加载的消息按全名的最后一段
+func (d *descriptorSet) goTypeName(name string) string {
+	if n, ok := d.goNames[name]; ok {
+		return n
+	}
+	return shortName(name)
+}
+
+func shortName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// protoSkeleton 按消息描述生成请求示例
+type protoSkeleton struct {
+	set *descriptorSet
+	// 当前路径上正在展开的消息和层数，避免自引用的消息无限展开
+	visiting map[string]bool
+	depth    int
+	// 枚举字段的可选值，如 "user.status: ACTIVE | INACTIVE"
+	hints  []string
+	values valueSource
+}
+
+func (d *descriptorSet) skeleton(name string, values valueSource) (interface{}, []string) {
+	d.RLock()
+	defer d.RUnlock()
+	if values == nil {
+		values = defaultValues()
+	}
+	p := &protoSkeleton{set: d, visiting: make(map[string]bool), values: values}
+	return p.message(name, ""), p.hints
+}
+
+func (p *protoSkeleton) message(name, path string) interface{} {
+	m, ok := p.set.messages[name]
+	if !ok {
+		return jsonObject{}
+	}
+	if p.visiting[name] {
+		return truncated("recursive", shortName(name))
+	}
+	if p.depth >= maxDepth {
+		return truncated("max depth", shortName(name))
+	}
+	p.visiting[name] = true
+	defer delete(p.visiting, name)
+	p.depth++
+	defer func() { p.depth-- }()
+
+	obj := make(jsonObject, 0, len(m.Field))
+	oneofs := make(map[int32]bool)
+	for _, f := range m.Field {
+		// oneof 只取第一个分支
+		if f.OneofIndex != nil {
+			if oneofs[f.GetOneofIndex()] {
+				continue
+			}
+			oneofs[f.GetOneofIndex()] = true
+		}
+		name := protoFieldName(f)
+		obj = append(obj, jsonField{
+			Key:   name,
+			Value: p.field(f, joinPath(path, name)),
+		})
+	}
+	return obj
+}
+
+func (p *protoSkeleton) field(f *descriptor.FieldDescriptorProto, path string) interface{} {
+	if f.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return p.single(f, path)
+	}
+
+	// map 字段在描述里是 repeated 的 XxxEntry 消息
+	if entry, ok := p.set.messages[f.GetTypeName()]; ok && entry.GetOptions().GetMapEntry() {
+		var key, val *descriptor.FieldDescriptorProto
+		for _, ef := range entry.Field {
+			switch ef.GetNumber() {
+			case 1:
+				key = ef
+			case 2:
+				val = ef
+			}
+		}
+		if key == nil || val == nil {
+			return jsonObject{}
+		}
+		return jsonObject{{
+			Key:   mapKey(p.values, key.GetName(), scalarTypes[key.GetType()]),
+			Value: p.single(val, path+"[]"),
+		}}
+	}
+
+	list := make([]interface{}, repeatedCount)
+	for i := range list {
+		list[i] = p.single(f, path+"[]")
+	}
+	return list
+}
+
+func (p *protoSkeleton) single(f *descriptor.FieldDescriptorProto, path string) interface{} {
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		if full, ok := wellKnownType(f.GetTypeName()); ok {
+			return wellKnownValue(p.values, f.GetName(), full)
+		}
+		return p.message(f.GetTypeName(), path)
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		e, ok := p.set.enums[f.GetTypeName()]
+		if !ok || len(e.Value) == 0 {
+			return 0
+		}
+		var names []string
+		for _, v := range e.Value {
+			names = append(names, v.GetName())
+		}
+		p.hints = append(p.hints, path+": "+strings.Join(names, " | "))
+		return names[0]
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return bytesValue(p.values, f.GetName())
+	}
+	v, _ := p.values(f.GetName(), scalarTypes[f.GetType()])
+	return v
+}
+
+// scalarTypes 把 proto 的基本类型对应到 DefaultMap 里的 Go 类型名
+var scalarTypes = map[descriptor.FieldDescriptorProto_Type]string{
+	descriptor.FieldDescriptorProto_TYPE_DOUBLE:   "float64",
+	descriptor.FieldDescriptorProto_TYPE_FLOAT:    "float32",
+	descriptor.FieldDescriptorProto_TYPE_INT64:    "int64",
+	descriptor.FieldDescriptorProto_TYPE_UINT64:   "uint64",
+	descriptor.FieldDescriptorProto_TYPE_INT32:    "int32",
+	descriptor.FieldDescriptorProto_TYPE_FIXED64:  "uint64",
+	descriptor.FieldDescriptorProto_TYPE_FIXED32:  "uint32",
+	descriptor.FieldDescriptorProto_TYPE_BOOL:     "bool",
+	descriptor.FieldDescriptorProto_TYPE_STRING:   "string",
+	descriptor.FieldDescriptorProto_TYPE_UINT32:   "uint32",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED32: "int32",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED64: "int64",
+	descriptor.FieldDescriptorProto_TYPE_SINT32:   "int32",
+	descriptor.FieldDescriptorProto_TYPE_SINT64:   "int64",
+}
+
+func joinPath(path, name string) string {
+	if len(path) == 0 {
+		return name
+	}
+	return path + "." + name
+}
+
+// formatRequest 生成 endpoint 的请求示例，有 proto 描述时以描述为准
+func formatRequest(c *typeCatalog, ep *registry.Endpoint) string {
+	return marshalIndent(requestSkeleton(c, ep, nil))
+}
+
+// formatResponse 生成 endpoint 的响应示例
+func formatResponse(c *typeCatalog, ep *registry.Endpoint) string {
+	if m := descriptors.method(ep); m != nil {
+		obj, _ := descriptors.skeleton(m.GetOutputType(), nil)
+		return marshalIndent(obj)
+	}
+	return format(c, ep.Response)
+}
+
+func requestSkeleton(c *typeCatalog, ep *registry.Endpoint, values valueSource) interface{} {
+	if m := descriptors.method(ep); m != nil {
+		obj, _ := descriptors.skeleton(m.GetInputType(), values)
+		return obj
+	}
+	return skeletonOf(c, ep.Request, values)
+}
+
+// enumHints 列出请求中枚举字段的可选值
+func enumHints(ep *registry.Endpoint) []string {
+	if m := descriptors.method(ep); m != nil {
+		_, hints := descriptors.skeleton(m.GetInputType(), nil)
+		return hints
+	}
+	return nil
+}
+
+// descriptorHandler 列出已加载的 proto 文件，POST 上传 FileDescriptorSet
+func descriptorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		var b []byte
+		var err error
+		if file, _, ferr := r.FormFile("file"); ferr == nil {
+			defer file.Close()
+			b, err = ioutil.ReadAll(file)
+		} else {
+			b, err = ioutil.ReadAll(r.Body)
+		}
+		if err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := LoadDescriptorSet(b); err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	b, err := json.Marshal(map[string]interface{}{
+		"files": descriptors.Files(),
+	})
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,99 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/micro/go-micro/registry"
+)
+
+// nestedFile 相当于
+//
+//	package nested;
+//	message Outer { message Inner { string id = 1; } }
+//	message Inner { string name = 1; }
+//	service Search { rpc Find(Outer.Inner) returns (Inner); }
+func nestedFile() *descriptor.FileDescriptorProto {
+	message := func(name, field string) *descriptor.DescriptorProto {
+		return &descriptor.DescriptorProto{
+			Name: proto.String(name),
+			Field: []*descriptor.FieldDescriptorProto{{
+				Name:     proto.String(field),
+				JsonName: proto.String(field),
+				Number:   proto.Int32(1),
+				Type:     descriptor.FieldDescriptorProto_TYPE_STRING.Enum(),
+				Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			}},
+		}
+	}
+	outer := &descriptor.DescriptorProto{
+		Name:       proto.String("Outer"),
+		NestedType: []*descriptor.DescriptorProto{message("Inner", "id")},
+	}
+	return &descriptor.FileDescriptorProto{
+		Name:        proto.String("nested.proto"),
+		Package:     proto.String("nested"),
+		MessageType: []*descriptor.DescriptorProto{outer, message("Inner", "name")},
+		Service: []*descriptor.ServiceDescriptorProto{{
+			Name: proto.String("Search"),
+			Method: []*descriptor.MethodDescriptorProto{{
+				Name:       proto.String("Find"),
+				InputType:  proto.String(".nested.Outer.Inner"),
+				OutputType: proto.String(".nested.Inner"),
+			}},
+		}},
+	}
+}
+
+func TestDescriptorMethod(t *testing.T) {
+	d := testDescriptors()
+	d.add(nestedFile())
+	endpoint := func(name, typ string) *registry.Endpoint {
+		ep := &registry.Endpoint{Name: name}
+		if len(typ) > 0 {
+			ep.Request = &registry.Value{Name: typ, Type: typ}
+		}
+		return ep
+	}
+	tests := []struct {
+		name     string
+		endpoint *registry.Endpoint
+		want     string
+	}{
+		{name: "top level", endpoint: endpoint("Users.Get", "User"), want: "Get"},
+		// protoc-gen-go 把嵌套的消息命名为 Outer_Inner
+		{name: "nested", endpoint: endpoint("Search.Find", "Outer_Inner"), want: "Find"},
+		{name: "same short name", endpoint: endpoint("Search.Find", "Inner")},
+		{name: "other request type", endpoint: endpoint("Users.Get", "Group")},
+		{name: "no request type", endpoint: endpoint("Search.Find", ""), want: "Find"},
+		{name: "unknown endpoint", endpoint: endpoint("Search.Get", "Outer_Inner")},
+	}
+	for _, tt := range tests {
+		m := d.method(tt.endpoint)
+		if got := m.GetName(); got != tt.want {
+			t.Errorf("%s: expected method %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestGoTypeName(t *testing.T) {
+	d := testDescriptors()
+	d.add(nestedFile())
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: ".test.User", want: "User"},
+		{name: ".nested.Outer.Inner", want: "Outer_Inner"},
+		{name: ".nested.Inner", want: "Inner"},
+		{name: ".test.User.ScoresEntry", want: "User_ScoresEntry"},
+		// 没有加载的文件中的消息
+		{name: ".google.protobuf.Empty", want: "Empty"},
+	}
+	for _, tt := range tests {
+		if got := d.goTypeName(tt.name); got != tt.want {
+			t.Errorf("goTypeName(%q) = %q, expected %q", tt.name, got, tt.want)
+		}
+	}
+}
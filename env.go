@@ -0,0 +1,480 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 保存环境变量的文件
+var environmentFile = "environments.json"
+
+// environmentSet 保存命名的变量集，如 dev、test、staging
+// 请求中的 {{env.tenant}} 按调用时选中的环境替换
+type environmentSet struct {
+	sync.RWMutex
+	file string
+	envs map[string]map[string]string
+}
+
+var environments = &environmentSet{envs: make(map[string]map[string]string)}
+
+// loadEnvironmentFile 加载保存的环境，文件不存在时在第一次保存时创建
+func loadEnvironmentFile(file string) error {
+	environments.Lock()
+	defer environments.Unlock()
+	environments.file = file
+	if len(file) == 0 {
+		return nil
+	}
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &environments.envs)
+}
+
+// get 返回环境的变量，环境不存在时 ok 为 false
+func (s *environmentSet) get(name string) (map[string]string, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	env, ok := s.envs[name]
+	if !ok {
+		return nil, false
+	}
+	vars := make(map[string]string)
+	for k, v := range env {
+		vars[k] = v
+	}
+	return vars, true
+}
+
+// set 替换环境的变量，vars 为 nil 时删除环境
+func (s *environmentSet) set(name string, vars map[string]string) error {
+	s.Lock()
+	defer s.Unlock()
+	if vars == nil {
+		delete(s.envs, name)
+	} else {
+		s.envs[name] = vars
+	}
+	if len(s.file) == 0 {
+		return nil
+	}
+	b, err := json.MarshalIndent(s.envs, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.file, b, 0600)
+}
+
+// names 返回排好序的环境名
+func (s *environmentSet) names() []string {
+	s.RLock()
+	defer s.RUnlock()
+	names := make([]string, 0, len(s.envs))
+	for name := range s.envs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// expander 替换请求中的 {{...}} 占位符：
+//
+//	{{env.tenant}}          选中环境中的变量
+//	{{uuid}}                随机的 UUID
+//	{{now}}                 当前时间，可以用 rfc3339、rfc3339nano、date、unix、unixms 格式化，如 {{now | unix}}
+//	{{random.int 1 100}}    1 到 100 之间的随机整数
+//	{{vars.user_id}}        场景中前面的步骤取出的值
+//	{{request.user.name}}   Mocks 收到的请求中的字段
+//
+// 另外 upper、lower 转换大小写，string 把值转成字符串
+// 要写 {{ 本身时写成 {{{{
+type expander struct {
+	env  string
+	vars map[string]string
+	// 场景的变量，值保持 JSON 中的类型
+	values map[string]interface{}
+	// Mocks 收到的请求
+	req  interface{}
+	fake *fakeData
+	// 同一个请求中的 {{now}} 取同一个时间
+	now time.Time
+	// 为 true 时保留占位符不替换，用于没有开启 expand 的 /rpc 请求和生成代码片段
+	keep bool
+	// keep 为 true 时字符串外的占位符写成字符串，只用于生成代码片段，其他时候报错
+	quote bool
+}
+
+// newExpander 按环境名创建 expander，env 为空时不能使用 {{env.xxx}}
+func newExpander(env string) (*expander, error) {
+	now := time.Now()
+	x := &expander{env: env, fake: newFakeData(now.UnixNano()), now: now}
+	if len(env) > 0 {
+		vars, ok := environments.get(env)
+		if !ok {
+			return nil, fmt.Errorf("unknown environment %q", env)
+		}
+		x.vars = vars
+	}
+	return x, nil
+}
+
+// eval 计算一个占位符，值是 string、int64、time.Time 或场景变量中 JSON 的值
+func (x *expander) eval(expr string) (interface{}, error) {
+	pipe := strings.Split(expr, "|")
+	args := strings.Fields(pipe[0])
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty placeholder {{%s}}", expr)
+	}
+
+	var v interface{}
+	switch name := args[0]; {
+	case strings.HasPrefix(name, "env.") && len(args) == 1:
+		val, ok := x.vars[name[len("env."):]]
+		if !ok && len(x.env) == 0 {
+			return nil, fmt.Errorf("{{%s}}: no environment selected", name)
+		}
+		if !ok {
+			return nil, fmt.Errorf("{{%s}}: not set in environment %q", name, x.env)
+		}
+		v = val
+	case strings.HasPrefix(name, "vars.") && len(args) == 1:
+		val, ok := x.values[name[len("vars."):]]
+		if !ok {
+			return nil, fmt.Errorf("{{%s}}: variable not set", name)
+		}
+		v = val
+	case (name == "request" || strings.HasPrefix(name, "request.") || strings.HasPrefix(name, "request[")) && len(args) == 1:
+		val, err := jsonPath(x.req, "$"+name[len("request"):])
+		if err != nil {
+			return nil, fmt.Errorf("{{%s}}: %v", name, err)
+		}
+		v = val
+	case name == "uuid" && len(args) == 1:
+		v = x.fake.uuid()
+	case name == "now" && len(args) == 1:
+		v = x.now
+	case name == "random.int" && len(args) == 3:
+		min, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("{{%s}}: invalid min %q", expr, args[1])
+		}
+		max, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("{{%s}}: invalid max %q", expr, args[2])
+		}
+		if max < min {
+			return nil, fmt.Errorf("{{%s}}: min %d is greater than max %d", expr, min, max)
+		}
+		// max-min+1 可能超出 int64，按 uint64 计算范围，为 0 时是整个 int64
+		span := uint64(max) - uint64(min) + 1
+		switch {
+		case span == 0:
+			v = int64(x.fake.rnd.Uint64())
+		case span > math.MaxInt64:
+			v = min + int64(x.fake.rnd.Uint64()%span)
+		default:
+			v = min + x.fake.rnd.Int63n(int64(span))
+		}
+	default:
+		return nil, fmt.Errorf("unknown placeholder {{%s}}", strings.TrimSpace(expr))
+	}
+
+	for _, f := range pipe[1:] {
+		var err error
+		if v, err = placeholderFilter(strings.TrimSpace(f), v); err != nil {
+			return nil, fmt.Errorf("{{%s}}: %v", strings.TrimSpace(expr), err)
+		}
+	}
+	return v, nil
+}
+
+func placeholderFilter(name string, v interface{}) (interface{}, error) {
+	t, isTime := v.(time.Time)
+	switch name {
+	case "string":
+		return placeholderString(v), nil
+	case "upper":
+		return strings.ToUpper(placeholderString(v)), nil
+	case "lower":
+		return strings.ToLower(placeholderString(v)), nil
+	case "rfc3339", "rfc3339nano", "date", "unix", "unixms":
+		if !isTime {
+			return nil, fmt.Errorf("%s expects a time", name)
+		}
+	default:
+		return nil, fmt.Errorf("unknown filter %q", name)
+	}
+	switch name {
+	case "rfc3339":
+		return t.Format(time.RFC3339), nil
+	case "rfc3339nano":
+		return t.Format(time.RFC3339Nano), nil
+	case "date":
+		return t.Format("2006-01-02"), nil
+	case "unix":
+		return t.Unix(), nil
+	default:
+		return t.UnixNano() / int64(time.Millisecond), nil
+	}
+}
+
+func placeholderString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case int64, json.Number, bool:
+		return fmt.Sprint(v)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// placeholderJSON 是值在 JSON 中的写法，数字不加引号
+func placeholderJSON(v interface{}) string {
+	switch v.(type) {
+	case string, time.Time:
+		b, _ := json.Marshal(placeholderString(v))
+		return string(b)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// expandString 替换字符串中所有的占位符，{{{{ 替换成 {{
+func (x *expander) expandString(s string) (string, error) {
+	if x.keep || !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "{{")
+		if start < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		if strings.HasPrefix(s[start:], "{{{{") {
+			b.WriteString(s[:start+2])
+			s = s[start+4:]
+			continue
+		}
+		end := strings.Index(s[start:], "}}")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated placeholder %q", s[start:])
+		}
+		v, err := x.eval(s[start+2 : start+end])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(s[:start])
+		b.WriteString(placeholderString(v))
+		s = s[start+end+2:]
+	}
+}
+
+// expandJSON 替换 JSON 文本中的占位符
+// 字符串外的占位符按值的类型写入，字符串中的占位符替换后再转义
+// 整个字符串只有一个占位符时按值的类型写入，"{{random.int 1 100}}" 得到数字
+// keep 为 true 时字符串外的占位符不能原样发送，quote 为 true 时写成字符串，生成的片段仍然是合法的 JSON，否则报错
+func (x *expander) expandJSON(s string) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				// 字符串没有结束，留给 JSON 解析报错
+				b.WriteString(s[i:])
+				return b.String(), nil
+			}
+			lit, err := x.expandLiteral(s[i : j+1])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(lit)
+			i = j + 1
+		case strings.HasPrefix(s[i:], "{{{{"):
+			if x.keep {
+				b.WriteString("{{{{")
+			} else {
+				b.WriteString("{{")
+			}
+			i += 4
+		case strings.HasPrefix(s[i:], "{{"):
+			end := strings.Index(s[i:], "}}")
+			if end < 0 && x.keep {
+				b.WriteString(s[i:])
+				return b.String(), nil
+			}
+			if end < 0 {
+				return "", fmt.Errorf("unterminated placeholder %q", s[i:])
+			}
+			if x.keep && !x.quote {
+				return "", fmt.Errorf("placeholder %s outside a string is only allowed with expand", s[i:i+end+2])
+			}
+			if x.keep {
+				b.WriteString(placeholderJSON(s[i : i+end+2]))
+				i += end + 2
+				continue
+			}
+			v, err := x.eval(s[i+2 : i+end])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(placeholderJSON(v))
+			i += end + 2
+		default:
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+	return b.String(), nil
+}
+
+func (x *expander) expandLiteral(lit string) (string, error) {
+	if x.keep || !strings.Contains(lit, "{{") {
+		return lit, nil
+	}
+	var s string
+	if err := json.Unmarshal([]byte(lit), &s); err != nil {
+		return lit, nil
+	}
+	if t := strings.TrimSpace(s); strings.HasPrefix(t, "{{") && strings.HasSuffix(t, "}}") && strings.Count(t, "{{") == 1 {
+		v, err := x.eval(t[2 : len(t)-2])
+		if err != nil {
+			return "", err
+		}
+		return placeholderJSON(v), nil
+	}
+	s, err := x.expandString(s)
+	if err != nil {
+		return "", err
+	}
+	b, _ := json.Marshal(s)
+	return string(b), nil
+}
+
+// request 替换请求中的占位符，字符串形式的请求替换后再按 JSON 解析
+func (x *expander) request(v interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok && x.keep {
+		return v, nil
+	}
+	if !ok {
+		b, err := json.Marshal(v)
+		if err != nil || !bytes.Contains(b, []byte("{{")) {
+			return v, nil
+		}
+		s = string(b)
+	}
+	s, err := x.expandJSON(s)
+	if err != nil {
+		return nil, err
+	}
+	var req interface{}
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+	if err := d.Decode(&req); err != nil {
+		return nil, fmt.Errorf("error decoding request string: %v", err)
+	}
+	return req, nil
+}
+
+// metadata 替换 metadata 值中的占位符，如 "Bearer {{env.token}}"
+func (x *expander) metadata(md map[string]string) error {
+	for k, v := range md {
+		s, err := x.expandString(v)
+		if err != nil {
+			return err
+		}
+		md[k] = s
+	}
+	return nil
+}
+
+// environmentHandler 显示和修改环境，JSON 请求时返回所有环境的变量
+// GET /environments?name=dev 返回一个环境的变量
+// POST /environments?name=dev {"tenant": "t1"}
+// DELETE /environments?name=dev
+func environmentHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "ParseForm err:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := r.Form.Get("name")
+
+	switch r.Method {
+	case "POST":
+		if len(name) == 0 {
+			http.Error(w, "Error occurred: name is required", http.StatusBadRequest)
+			return
+		}
+		vars := make(map[string]string)
+		if err := json.NewDecoder(r.Body).Decode(&vars); err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := environments.set(name, vars); err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	case "DELETE":
+		if err := environments.set(name, nil); err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var rsp interface{}
+	if len(name) > 0 {
+		vars, ok := environments.get(name)
+		if !ok {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		rsp = vars
+	} else {
+		if r.Header.Get("Content-Type") != "application/json" {
+			render(w, r, environmentTemplate, nil)
+			return
+		}
+		envs := make(map[string]map[string]string)
+		for _, name := range environments.names() {
+			envs[name], _ = environments.get(name)
+		}
+		rsp = map[string]interface{}{"environments": envs}
+	}
+
+	b, err := json.Marshal(rsp)
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,151 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testExpander(keep, quote bool) *expander {
+	now := time.Date(2019, 11, 11, 11, 11, 11, 0, time.UTC)
+	return &expander{
+		env:   "dev",
+		vars:  map[string]string{"tenant": "t1", "token": "secret"},
+		fake:  newFakeData(1),
+		now:   now,
+		keep:  keep,
+		quote: quote,
+	}
+}
+
+func TestExpandJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		keep  bool
+		quote bool
+		in    string
+		want  string
+		err   string
+	}{
+		{name: "no placeholders", in: `{"id": "1"}`, want: `{"id": "1"}`},
+		{name: "env in string", in: `{"tenant": "{{env.tenant}}"}`, want: `{"tenant": "t1"}`},
+		{name: "inside a longer string", in: `{"auth": "Bearer {{env.token}}"}`, want: `{"auth": "Bearer secret"}`},
+		{name: "whole string keeps the type", in: `{"n": "{{random.int 7 7}}"}`, want: `{"n": 7}`},
+		{name: "outside a string", in: `{"n": {{random.int 7 7}}}`, want: `{"n": 7}`},
+		{name: "time outside a string", in: `{"at": {{now}}}`, want: `{"at": "2019-11-11T11:11:11Z"}`},
+		{name: "filter", in: `{"at": "{{now | unix}}"}`, want: `{"at": 1573470671}`},
+		{name: "escaped braces", in: `{"tpl": "{{{{name}}"}`, want: `{"tpl": "{{name}}"}`},
+		{name: "escaped quote", in: `{"q": "say \"{{env.tenant}}\""}`, want: `{"q": "say \"t1\""}`},
+		{name: "unknown variable", in: `{"x": "{{env.missing}}"}`, err: `not set in environment "dev"`},
+		{name: "unknown placeholder", in: `{"x": "{{nope}}"}`, err: "unknown placeholder {{nope}}"},
+		{name: "unterminated", in: `{"x": {{now`, err: "unterminated placeholder"},
+		// 没有开启 expand 时原样发送，字符串外的占位符会被改成字符串，所以拒绝
+		{name: "keep", keep: true, in: `{"tenant": "{{env.tenant}}"}`, want: `{"tenant": "{{env.tenant}}"}`},
+		{name: "keep outside a string", keep: true, in: `{"n": {{random.int 1 100}}}`, err: "only allowed with expand"},
+		// 代码片段中写成字符串，仍然是合法的 JSON
+		{name: "quote outside a string", keep: true, quote: true, in: `{"n": {{random.int 1 100}}}`, want: `{"n": "{{random.int 1 100}}"}`},
+		{name: "quote escaped braces", keep: true, quote: true, in: `{"tpl": "{{{{name}}"}`, want: `{"tpl": "{{{{name}}"}`},
+	}
+	for _, tt := range tests {
+		got, err := testExpander(tt.keep, tt.quote).expandJSON(tt.in)
+		if len(tt.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestPlaceholderFilter(t *testing.T) {
+	now := time.Date(2019, 11, 11, 11, 11, 11, 500000000, time.UTC)
+	tests := []struct {
+		filter string
+		in     interface{}
+		want   interface{}
+		err    string
+	}{
+		{filter: "rfc3339", in: now, want: "2019-11-11T11:11:11Z"},
+		{filter: "rfc3339nano", in: now, want: "2019-11-11T11:11:11.5Z"},
+		{filter: "date", in: now, want: "2019-11-11"},
+		{filter: "unix", in: now, want: int64(1573470671)},
+		{filter: "unixms", in: now, want: int64(1573470671500)},
+		{filter: "string", in: int64(7), want: "7"},
+		{filter: "string", in: now, want: "2019-11-11T11:11:11Z"},
+		{filter: "string", in: map[string]interface{}{"a": "b"}, want: `{"a":"b"}`},
+		{filter: "upper", in: "abc", want: "ABC"},
+		{filter: "lower", in: "ABC", want: "abc"},
+		{filter: "unix", in: "abc", err: "unix expects a time"},
+		{filter: "reverse", in: "abc", err: `unknown filter "reverse"`},
+	}
+	for _, tt := range tests {
+		got, err := placeholderFilter(tt.filter, tt.in)
+		if len(tt.err) > 0 {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s(%v): expected error %q, got %v", tt.filter, tt.in, tt.err, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s(%v): expected %#v, got %#v, %v", tt.filter, tt.in, tt.want, got, err)
+		}
+	}
+}
+
+func TestExpanderRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		keep bool
+		in   interface{}
+		want string
+		err  string
+	}{
+		{name: "string request", in: `{"n": {{random.int 7 7}}, "t": "{{env.tenant}}"}`, want: `{"n":7,"t":"t1"}`},
+		{name: "object request", in: map[string]interface{}{"t": "{{env.tenant}}"}, want: `{"t":"t1"}`},
+		{name: "keep object", keep: true, in: map[string]interface{}{"t": "{{env.tenant}}"}, want: `{"t":"{{env.tenant}}"}`},
+		{name: "keep string", keep: true, in: `{"t": "{{env.tenant}}"}`, want: `{"t":"{{env.tenant}}"}`},
+		{name: "keep bare placeholder", keep: true, in: `{"n": {{random.int 1 100}}}`, err: "only allowed with expand"},
+	}
+	for _, tt := range tests {
+		got, err := testExpander(tt.keep, false).request(tt.in)
+		if len(tt.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if s := compactJSON(t, got); s != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, s)
+		}
+	}
+}
+
+// 没有开启 expand 的 /rpc 请求中字符串外的占位符返回 400，不会改成字符串发给服务
+func TestRPCBarePlaceholder(t *testing.T) {
+	form := url.Values{
+		"service":  {"go.micro.srv.users"},
+		"endpoint": {"Users.Get"},
+		"request":  {`{"n": {{random.int 1 100}}}`},
+	}
+	r := httptest.NewRequest("POST", "/rpc", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	rpc(w, r)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "only allowed with expand") {
+		t.Fatalf("expected 400, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,167 @@
+package web
+
+import (
+	"fmt"
+	"math/rand"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	fakeFirstNames = []string{"James", "Mary", "Wei", "Li", "Fatima", "Carlos", "Yuki", "Olga", "Ahmed", "Emma"}
+	fakeLastNames  = []string{"Smith", "Zhou", "Wang", "Garcia", "Tanaka", "Ivanova", "Hassan", "Müller", "Brown", "Chen"}
+	fakeStreets    = []string{"Baker Street", "Nanjing Road", "Main Street", "Queen's Road", "Rue de Rivoli", "Sunset Blvd"}
+	fakeCities     = []string{"London", "Shanghai", "Springfield", "Hong Kong", "Paris", "Los Angeles", "Shenzhen"}
+	fakeJobs       = []string{"Engineer", "Designer", "Accountant", "Teacher", "Nurse", "Product Manager"}
+	fakeWords      = []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel"}
+	fakeDomains    = []string{"example.com", "example.org", "example.net"}
+
+	// 生成时间的范围起点
+	fakeEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// fakeData 按字段名和类型生成看起来像真的数据，seed 相同时生成的数据相同
+type fakeData struct {
+	rnd *rand.Rand
+}
+
+func newFakeData(seed int64) *fakeData {
+	return &fakeData{rnd: rand.New(rand.NewSource(seed))}
+}
+
+// fakeRule 按字段名（小写）匹配生成规则，先匹配先用
+type fakeRule struct {
+	patterns []string
+	// 只用于这些类型，为空时只用于 string
+	types []string
+	gen   func(f *fakeData) interface{}
+}
+
+var fakeRules = []fakeRule{
+	{patterns: []string{"id", "*_id", "uuid", "*_uuid"}, gen: (*fakeData).uuid},
+	{patterns: []string{"*email*"}, gen: (*fakeData).email},
+	{patterns: []string{"*phone*", "*mobile*"}, gen: (*fakeData).phone},
+	{patterns: []string{"addr", "address", "*_addr", "*_address"}, gen: (*fakeData).address},
+	{patterns: []string{"city"}, gen: func(f *fakeData) interface{} { return f.pick(fakeCities) }},
+	{patterns: []string{"job", "title", "*_title"}, gen: func(f *fakeData) interface{} { return f.pick(fakeJobs) }},
+	{patterns: []string{"first_name"}, gen: func(f *fakeData) interface{} { return f.pick(fakeFirstNames) }},
+	{patterns: []string{"last_name"}, gen: func(f *fakeData) interface{} { return f.pick(fakeLastNames) }},
+	{patterns: []string{"name", "*_name", "user*name"}, gen: (*fakeData).fullName},
+	{patterns: []string{"url", "*_url", "link"}, gen: func(f *fakeData) interface{} {
+		return fmt.Sprintf("https://%s/%s", f.pick(fakeDomains), f.pick(fakeWords))
+	}},
+	{
+		patterns: []string{"*salary*", "*amount*", "*price*", "*cost*", "*balance*"},
+		types:    []string{"float32", "float64"},
+		gen:      (*fakeData).money,
+	},
+	{
+		patterns: []string{"*salary*", "*amount*", "*price*", "*cost*", "*balance*"},
+		types:    []string{"int32", "int64", "uint32", "uint64"},
+		gen:      func(f *fakeData) interface{} { return 100 * (10 + f.rnd.Intn(9990)) },
+	},
+	{patterns: []string{"age"}, types: []string{"int32", "int64", "uint32", "uint64"}, gen: func(f *fakeData) interface{} {
+		return 18 + f.rnd.Intn(50)
+	}},
+	{patterns: []string{"*_at", "*time*", "*date*", "created", "updated"}, gen: func(f *fakeData) interface{} {
+		return f.time().Format(time.RFC3339)
+	}},
+	{patterns: []string{"*_at", "*time*", "created", "updated"}, types: []string{"int64", "uint64"}, gen: func(f *fakeData) interface{} {
+		return f.time().Unix()
+	}},
+}
+
+// value 给出 name 字段的随机值，不认识的类型返回 false
+func (f *fakeData) value(name, typ string) (interface{}, bool) {
+	name = strings.ToLower(name)
+	for _, r := range fakeRules {
+		if !r.match(name, typ) {
+			continue
+		}
+		return r.gen(f), true
+	}
+
+	switch typ {
+	case "string":
+		return f.pick(fakeWords), true
+	case "bool":
+		return f.rnd.Intn(2) == 1, true
+	case "int32", "int64":
+		return f.rnd.Intn(1000) - 100, true
+	case "uint8":
+		return f.rnd.Intn(256), true
+	case "uint32", "uint64":
+		return f.rnd.Intn(1000), true
+	case "float32", "float64":
+		return float64(f.rnd.Intn(100000)) / 100, true
+	case "google.protobuf.Timestamp":
+		return f.time().Format(time.RFC3339), true
+	case "google.protobuf.Duration":
+		return fmt.Sprintf("%d.%03ds", f.rnd.Intn(60), f.rnd.Intn(1000)), true
+	}
+	return nil, false
+}
+
+func (r fakeRule) match(name, typ string) bool {
+	types := r.types
+	if len(types) == 0 {
+		types = []string{"string"}
+	}
+	found := false
+	for _, t := range types {
+		if t == typ {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+	for _, p := range r.patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeData) pick(list []string) string {
+	return list[f.rnd.Intn(len(list))]
+}
+
+func (f *fakeData) fullName() interface{} {
+	return f.pick(fakeFirstNames) + " " + f.pick(fakeLastNames)
+}
+
+func (f *fakeData) email() interface{} {
+	return fmt.Sprintf("%s.%s@%s",
+		strings.ToLower(f.pick(fakeFirstNames)),
+		strings.ToLower(f.pick(fakeLastNames)),
+		f.pick(fakeDomains))
+}
+
+func (f *fakeData) phone() interface{} {
+	return fmt.Sprintf("+1-202-555-%04d", f.rnd.Intn(10000))
+}
+
+func (f *fakeData) address() interface{} {
+	return fmt.Sprintf("%d %s, %s", 1+f.rnd.Intn(999), f.pick(fakeStreets), f.pick(fakeCities))
+}
+
+func (f *fakeData) money() interface{} {
+	return float64(100000+f.rnd.Intn(9900000)) / 100
+}
+
+func (f *fakeData) time() time.Time {
+	return fakeEpoch.Add(time.Duration(f.rnd.Int63n(int64(6 * 365 * 24 * time.Hour)))).Truncate(time.Second)
+}
+
+// uuid 生成 version 4 格式的 UUID
+func (f *fakeData) uuid() interface{} {
+	b := make([]byte, 16)
+	f.rnd.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
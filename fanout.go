@@ -0,0 +1,227 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/micro/go-micro/errors"
+	"github.com/micro/go-micro/registry"
+)
+
+// jsonChange 是两个 JSON 值之间的一处不同
+type jsonChange struct {
+	Path string `json:"path"`
+	// changed、added 或 removed
+	Kind string      `json:"kind"`
+	Want interface{} `json:"want,omitempty"`
+	Got  interface{} `json:"got,omitempty"`
+}
+
+var jsonPathIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// jsonDiff 逐个字段比较 want 和 got，路径的写法和 JSONPath 相同，如 $.users[1].name
+func jsonDiff(want, got interface{}, path string) []*jsonChange {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(w)+len(g))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		for k := range g {
+			if _, ok := w[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		var changes []*jsonChange
+		for _, k := range keys {
+			p := path + "['" + k + "']"
+			if jsonPathIdent.MatchString(k) {
+				p = path + "." + k
+			}
+			wv, inWant := w[k]
+			gv, inGot := g[k]
+			switch {
+			case !inGot:
+				changes = append(changes, &jsonChange{Path: p, Kind: "removed", Want: wv})
+			case !inWant:
+				changes = append(changes, &jsonChange{Path: p, Kind: "added", Got: gv})
+			default:
+				changes = append(changes, jsonDiff(wv, gv, p)...)
+			}
+		}
+		return changes
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok {
+			break
+		}
+		var changes []*jsonChange
+		for i := 0; i < len(w) || i < len(g); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(g):
+				changes = append(changes, &jsonChange{Path: p, Kind: "removed", Want: w[i]})
+			case i >= len(w):
+				changes = append(changes, &jsonChange{Path: p, Kind: "added", Got: g[i]})
+			default:
+				changes = append(changes, jsonDiff(w[i], g[i], p)...)
+			}
+		}
+		return changes
+	}
+	if jsonEqual(want, got) {
+		return nil
+	}
+	return []*jsonChange{{Path: path, Kind: "changed", Want: want, Got: got}}
+}
+
+// fanoutNode 是发往一个节点的调用结果
+type fanoutNode struct {
+	ID        string          `json:"id"`
+	Address   string          `json:"address"`
+	Version   string          `json:"version"`
+	LatencyMs float64         `json:"latency_ms"`
+	Response  json.RawMessage `json:"response,omitempty"`
+	Error     *errors.Error   `json:"error,omitempty"`
+	// 和多数节点的结果相同
+	Match bool          `json:"match"`
+	Diff  []*jsonChange `json:"diff,omitempty"`
+
+	// 用来分组的结果，响应按 JSON 的值比较，错误按 code 和 detail 比较
+	outcome string
+	value   interface{}
+}
+
+// fanoutResult 是同一个请求发往服务所有节点的结果
+type fanoutResult struct {
+	Service  string        `json:"service"`
+	Endpoint string        `json:"endpoint"`
+	Nodes    []*fanoutNode `json:"nodes"`
+	// 结果相同的节点最多的一组
+	Majority   int  `json:"majority"`
+	Consistent bool `json:"consistent"`
+}
+
+// fanout 把同一个请求发给服务在 registry 中的每个节点，并和多数节点的结果比较
+// 服务没有节点时返回 404 的 *errors.Error，其他错误是查询 registry 失败
+func (c *rpcCall) fanout(r *http.Request) (*fanoutResult, error) {
+	services, err := defaultRegistry().GetService(c.service)
+	if err == registry.ErrNotFound {
+		return nil, errors.NotFound("go.micro.rpc", "service %s has no nodes", c.service)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := &fanoutResult{Service: c.service, Endpoint: c.endpoint, Nodes: []*fanoutNode{}}
+	var wg sync.WaitGroup
+	for _, s := range services {
+		for _, node := range s.Nodes {
+			n := &fanoutNode{ID: node.Id, Address: node.Address, Version: s.Version}
+			result.Nodes = append(result.Nodes, n)
+			wg.Add(1)
+			go func(node *registry.Node) {
+				defer wg.Done()
+				nc := *c
+				nc.address = node.Address
+				res := nc.do(r.Context(), r)
+				n.record(res)
+				if res.err == nil {
+					n.Response = displayResult(&nc, res)
+				}
+			}(node)
+		}
+	}
+	if len(result.Nodes) == 0 {
+		return nil, errors.NotFound("go.micro.rpc", "service %s has no nodes", c.service)
+	}
+	wg.Wait()
+
+	// 找出结果最多的一组，数量相同时取先出现的
+	counts := make(map[string]int)
+	var majority *fanoutNode
+	for _, n := range result.Nodes {
+		counts[n.outcome]++
+		if majority == nil || counts[n.outcome] > counts[majority.outcome] {
+			majority = n
+		}
+	}
+	result.Majority = counts[majority.outcome]
+	result.Consistent = result.Majority == len(result.Nodes)
+	for _, n := range result.Nodes {
+		if n.Match = n.outcome == majority.outcome; !n.Match {
+			n.Diff = jsonDiff(majority.value, n.value, "$")
+		}
+	}
+	return result, nil
+}
+
+func (n *fanoutNode) record(res *rpcResult) {
+	n.LatencyMs = durationMs(res.latency)
+	n.value = resultValue(res.response, res.err)
+	if res.err != nil {
+		n.Error = res.err
+		n.outcome = fmt.Sprintf("error %d %s", res.err.Code, res.err.Detail)
+		return
+	}
+	n.Response = res.response
+	// 重新序列化后 map 的 key 有序，字段顺序不同的响应也相同
+	b, _ := json.Marshal(n.value)
+	n.outcome = "response " + string(b)
+}
+
+// resultValue 把响应或错误转成用来比较的 JSON 值，错误只比较 code 和 detail
+func resultValue(response []byte, err *errors.Error) interface{} {
+	if err != nil {
+		return map[string]interface{}{"error": map[string]interface{}{"code": err.Code, "detail": err.Detail}}
+	}
+	var v interface{}
+	if json.Unmarshal(response, &v) != nil {
+		return string(response)
+	}
+	return v
+}
+
+// fanoutHandler 把请求发给服务的所有节点，请求的写法和 /rpc 相同
+// POST /fanout {"service": "go.micro.srv.greeter", "endpoint": "Say.Hello", "request": {...}}
+func fanoutHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+
+	c, err := parseRPCRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(errors.BadRequest("go.micro.rpc", "%v", err).Error()))
+		return
+	}
+	if len(c.service) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(errors.BadRequest("go.micro.rpc", "fan-out needs a registered service").Error()))
+		return
+	}
+	result, err := c.fanout(r)
+	if e, ok := err.(*errors.Error); ok {
+		w.WriteHeader(int(e.Code))
+		w.Write([]byte(e.Error()))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(errors.InternalServerError("go.micro.rpc", "%v", err).Error()))
+		return
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
@@ -0,0 +1,137 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/micro/go-micro/config/cmd"
+	"github.com/micro/go-micro/registry"
+)
+
+func TestJSONDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		got  string
+		diff string
+	}{
+		{name: "equal", want: `{"a": 1, "b": [1, 2]}`, got: `{"b": [1, 2], "a": 1}`, diff: `null`},
+		{name: "changed", want: `{"user": {"name": "a"}}`, got: `{"user": {"name": "b"}}`, diff: `[{"path":"$.user.name","kind":"changed","want":"a","got":"b"}]`},
+		{name: "added and removed", want: `{"a": 1}`, got: `{"b": 2}`, diff: `[{"path":"$.a","kind":"removed","want":1},{"path":"$.b","kind":"added","got":2}]`},
+		{name: "array index", want: `{"users": [{"id": 1}, {"id": 2}]}`, got: `{"users": [{"id": 1}, {"id": 3}]}`, diff: `[{"path":"$.users[1].id","kind":"changed","want":2,"got":3}]`},
+		{name: "longer array", want: `[1]`, got: `[1, 2]`, diff: `[{"path":"$[1]","kind":"added","got":2}]`},
+		{name: "shorter array", want: `[1, 2]`, got: `[1]`, diff: `[{"path":"$[1]","kind":"removed","want":2}]`},
+		// 不是标识符的键用 ['...'] 的写法
+		{name: "quoted key", want: `{"x-id": 1}`, got: `{"x-id": 2}`, diff: `[{"path":"$['x-id']","kind":"changed","want":1,"got":2}]`},
+		{name: "different types", want: `{"a": {"b": 1}}`, got: `{"a": [1]}`, diff: `[{"path":"$.a","kind":"changed","want":{"b":1},"got":[1]}]`},
+		{name: "number and string", want: `{"id": 1}`, got: `{"id": "1"}`, diff: `[{"path":"$.id","kind":"changed","want":1,"got":"1"}]`},
+	}
+	for _, tt := range tests {
+		var want, got interface{}
+		if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(tt.got), &got); err != nil {
+			t.Fatal(err)
+		}
+		b, _ := json.Marshal(jsonDiff(want, got, "$"))
+		if string(b) != tt.diff {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.diff, b)
+		}
+	}
+}
+
+// fanoutRequest 调用 /fanout 并返回状态码和响应
+func fanoutRequest(t *testing.T, service string) (int, []byte) {
+	form := url.Values{
+		"service":  {service},
+		"endpoint": {"User.Get"},
+		"request":  {`{"id": "u-1"}`},
+	}
+	r := httptest.NewRequest("POST", "/fanout", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	fanoutHandler(w, r)
+	return w.Code, w.Body.Bytes()
+}
+
+// 两个节点指向同一个 mock，第三个节点连不上，多数节点的结果是 mock 的响应
+func TestFanoutMajority(t *testing.T) {
+	reg, _, reset := useMemoryRegistry()
+	defer reset()
+	m := startTestMock(t, testMock)
+	defer stopTestMock(m)
+
+	services, err := reg.GetService(m.Service)
+	if err != nil || len(services) != 1 || len(services[0].Nodes) != 1 {
+		t.Fatalf("expected one mock node, got %v, %v", services, err)
+	}
+	s := services[0]
+	extra := &registry.Service{
+		Name:    s.Name,
+		Version: s.Version,
+		Nodes: []*registry.Node{
+			{Id: "copy", Address: s.Nodes[0].Address, Metadata: s.Nodes[0].Metadata},
+			{Id: "down", Address: "127.0.0.1:1", Metadata: s.Nodes[0].Metadata},
+		},
+	}
+	if err := reg.Register(extra); err != nil {
+		t.Fatal(err)
+	}
+	defer reg.Deregister(extra)
+
+	code, b := fanoutRequest(t, m.Service)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", code, b)
+	}
+	var result fanoutResult
+	if err := json.Unmarshal(b, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Nodes) != 3 || result.Majority != 2 || result.Consistent {
+		t.Fatalf("expected 3 nodes with a majority of 2, got %s", b)
+	}
+	for _, n := range result.Nodes {
+		if n.Match != (n.ID != "down") {
+			t.Errorf("node %s: unexpected match %v", n.ID, n.Match)
+		}
+		if n.ID != "down" {
+			continue
+		}
+		if n.Error == nil || len(n.Diff) == 0 {
+			t.Errorf("expected an error and a diff for the node that is down, got %+v", n)
+		}
+	}
+}
+
+// failingRegistry 查询服务时总是出错
+type failingRegistry struct {
+	registry.Registry
+}
+
+func (failingRegistry) GetService(name string) ([]*registry.Service, error) {
+	return nil, fmt.Errorf("registry is unavailable")
+}
+
+// 只有服务没有节点时返回 404，registry 出错返回 500
+func TestFanoutStatus(t *testing.T) {
+	reg, _, reset := useMemoryRegistry()
+	defer reset()
+
+	code, b := fanoutRequest(t, "go.micro.srv.missing")
+	if code != http.StatusNotFound || !strings.Contains(string(b), "has no nodes") {
+		t.Fatalf("expected 404, got %d %s", code, b)
+	}
+
+	opts := cmd.DefaultOptions()
+	*opts.Registry = failingRegistry{reg}
+	code, b = fanoutRequest(t, "go.micro.srv.missing")
+	if code != http.StatusInternalServerError || !strings.Contains(string(b), "registry is unavailable") {
+		t.Fatalf("expected 500, got %d %s", code, b)
+	}
+}
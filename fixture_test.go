@@ -0,0 +1,258 @@
+package web
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/duration"
+	_struct "github.com/golang/protobuf/ptypes/struct"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/go-micro/server"
+)
+
+// 下面的结构和 protoc-gen-go 生成的一样，测试用的 endpoint 由 go-micro 的 extractor 从中提取，
+// 和服务注册到 registry 中的完全相同：类型名不带包名，map 字段没有类型名，最多展开三层
+
+type Status int32
+
+type User struct {
+	Id                   string               `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Avatar               []byte               `protobuf:"bytes,2,opt,name=avatar,proto3" json:"avatar,omitempty"`
+	Scores               map[string]int64     `protobuf:"bytes,3,rep,name=scores,proto3" json:"scores,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	CreatedAt            *timestamp.Timestamp `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Status               Status               `protobuf:"varint,5,opt,name=status,proto3,enum=test.Status" json:"status,omitempty"`
+	Groups               []*Group             `protobuf:"bytes,6,rep,name=groups,proto3" json:"groups,omitempty"`
+	Age                  *wrappers.Int64Value `protobuf:"bytes,7,opt,name=age,proto3" json:"age,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+type Group struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Members              []*User  `protobuf:"bytes,2,rep,name=members,proto3" json:"members,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+type UpdateRequest struct {
+	User                 *User              `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+	Group                *Group             `protobuf:"bytes,2,opt,name=group,proto3" json:"group,omitempty"`
+	Labels               map[string]string  `protobuf:"bytes,3,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Ttl                  *duration.Duration `protobuf:"bytes,4,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	Extra                *_struct.Struct    `protobuf:"bytes,5,opt,name=extra,proto3" json:"extra,omitempty"`
+	Detail               *any.Any           `protobuf:"bytes,6,opt,name=detail,proto3" json:"detail,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+type Users struct{}
+
+func (*Users) Get(ctx context.Context, req *User, rsp *Group) error            { return nil }
+func (*Users) Update(ctx context.Context, req *UpdateRequest, rsp *User) error { return nil }
+
+// Node 和 Edge 互相引用，Tree 引用自己
+type Node struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Edges                []*Edge  `protobuf:"bytes,2,rep,name=edges,proto3" json:"edges,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+type Edge struct {
+	Weight               int64    `protobuf:"varint,1,opt,name=weight,proto3" json:"weight,omitempty"`
+	To                   *Node    `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+type Tree struct {
+	Value                string   `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Children             []*Tree  `protobuf:"bytes,2,rep,name=children,proto3" json:"children,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+type WalkRequest struct {
+	Node                 *Node    `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	Edge                 *Edge    `protobuf:"bytes,2,opt,name=edge,proto3" json:"edge,omitempty"`
+	Tree                 *Tree    `protobuf:"bytes,3,opt,name=tree,proto3" json:"tree,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+type Graph struct{}
+
+func (*Graph) Walk(ctx context.Context, req *WalkRequest, rsp *Node) error { return nil }
+
+// Timestamp 和 Any 是和 well-known type 同名的业务消息
+type Timestamp struct {
+	Unix                 int64    `protobuf:"varint,1,opt,name=unix,proto3" json:"unix,omitempty"`
+	Zone                 string   `protobuf:"bytes,2,opt,name=zone,proto3" json:"zone,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+type Any struct {
+	Kind                 string   `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+type ScheduleRequest struct {
+	At                   *Timestamp `protobuf:"bytes,1,opt,name=at,proto3" json:"at,omitempty"`
+	Payload              *Any       `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
+	XXX_unrecognized     []byte     `json:"-"`
+	XXX_sizecache        int32      `json:"-"`
+}
+
+type Clock struct{}
+
+func (*Clock) Schedule(ctx context.Context, req *ScheduleRequest, rsp *Timestamp) error { return nil }
+
+// testService 用 extractor 从 handler 中提取 endpoint，组成 registry 中的服务
+func testService(name string, handler interface{}) *registry.Service {
+	return &registry.Service{
+		Name:      name,
+		Version:   "latest",
+		Endpoints: server.NewServer().NewHandler(handler).Endpoints(),
+		Nodes:     []*registry.Node{{Id: name + "-1", Address: "127.0.0.1:9090"}},
+	}
+}
+
+func testEndpoint(s *registry.Service, name string) *registry.Endpoint {
+	for _, ep := range s.Endpoints {
+		if ep.Name == name {
+			return ep
+		}
+	}
+	panic("no endpoint " + name)
+}
+
+// testFile 是 User 和 Group 的 proto 描述，相当于
+//
+//	package test;
+//	enum Status { ACTIVE = 0; INACTIVE = 1; }
+//	message User {
+//	  string id = 1;
+//	  bytes avatar = 2;
+//	  map<string, int64> scores = 3;
+//	  google.protobuf.Timestamp created_at = 4;
+//	  Status status = 5;
+//	  repeated Group groups = 6;
+//	  google.protobuf.Int64Value age = 7;
+//	}
+//	message Group { string name = 1; repeated User members = 2; }
+//	service Users { rpc Get(User) returns (Group); }
+func testFile() *descriptor.FileDescriptorProto {
+	field := func(name, jsonName string, number int32, typ descriptor.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptor.FieldDescriptorProto {
+		f := &descriptor.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(jsonName),
+			Number:   proto.Int32(number),
+			Type:     typ.Enum(),
+			Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		if len(typeName) > 0 {
+			f.TypeName = proto.String(typeName)
+		}
+		if repeated {
+			f.Label = descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		}
+		return f
+	}
+	return &descriptor.FileDescriptorProto{
+		Name:       proto.String("test.proto"),
+		Package:    proto.String("test"),
+		Dependency: []string{"google/protobuf/timestamp.proto", "google/protobuf/wrappers.proto"},
+		EnumType: []*descriptor.EnumDescriptorProto{{
+			Name: proto.String("Status"),
+			Value: []*descriptor.EnumValueDescriptorProto{
+				{Name: proto.String("ACTIVE"), Number: proto.Int32(0)},
+				{Name: proto.String("INACTIVE"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name: proto.String("User"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("id", "id", 1, descriptor.FieldDescriptorProto_TYPE_STRING, "", false),
+					field("avatar", "avatar", 2, descriptor.FieldDescriptorProto_TYPE_BYTES, "", false),
+					field("scores", "scores", 3, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".test.User.ScoresEntry", true),
+					field("created_at", "createdAt", 4, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Timestamp", false),
+					field("status", "status", 5, descriptor.FieldDescriptorProto_TYPE_ENUM, ".test.Status", false),
+					field("groups", "groups", 6, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".test.Group", true),
+					field("age", "age", 7, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Int64Value", false),
+				},
+				NestedType: []*descriptor.DescriptorProto{{
+					Name: proto.String("ScoresEntry"),
+					Field: []*descriptor.FieldDescriptorProto{
+						field("key", "key", 1, descriptor.FieldDescriptorProto_TYPE_STRING, "", false),
+						field("value", "value", 2, descriptor.FieldDescriptorProto_TYPE_INT64, "", false),
+					},
+					Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+				}},
+			},
+			{
+				Name: proto.String("Group"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("name", "name", 1, descriptor.FieldDescriptorProto_TYPE_STRING, "", false),
+					field("members", "members", 2, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".test.User", true),
+				},
+			},
+		},
+		Service: []*descriptor.ServiceDescriptorProto{{
+			Name: proto.String("Users"),
+			Method: []*descriptor.MethodDescriptorProto{{
+				Name:       proto.String("Get"),
+				InputType:  proto.String(".test.User"),
+				OutputType: proto.String(".test.Group"),
+			}},
+		}},
+	}
+}
+
+func testDescriptors() *descriptorSet {
+	d := newDescriptorSet()
+	d.add(testFile())
+	return d
+}
+
+// compactJSON 把示例或 schema 写成一行 JSON 用来比较
+func compactJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	b, err := marshalJSON(v, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// lookup 按 a.b.c 取出示例中的字段
+func lookup(v interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := v.(jsonObject)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj.get(key); !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
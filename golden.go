@@ -0,0 +1,528 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/micro/go-micro/errors"
+)
+
+// 保存 dashboard 中标记的 golden 调用的文件，也可以直接用 replay 命令运行
+var goldenFile = "golden.json"
+
+// goldenSuite 是一组记录下来的调用和当时的结果，重放时逐个字段比较新的结果
+type goldenSuite struct {
+	Name string `json:"name,omitempty"`
+	// 所有调用都忽略的字段，如时间戳
+	Ignore []string      `json:"ignore,omitempty"`
+	Calls  []*goldenCall `json:"calls"`
+}
+
+// goldenCall 是一条调用历史，ID 在套件中递增
+type goldenCall struct {
+	Name string `json:"name,omitempty"`
+	historyEntry
+	// 记录时字段名的写法，以前 gRPC 的响应按这个写法记录，现在的响应都是 proto 原名，不再填写
+	Naming string `json:"naming,omitempty"`
+	// 只对这个调用忽略的字段
+	Ignore []string `json:"ignore,omitempty"`
+}
+
+// goldenResult 是重放一个调用的结果，Diff 中不包括忽略的字段
+type goldenResult struct {
+	ID        uint64          `json:"id"`
+	Name      string          `json:"name"`
+	Service   string          `json:"service"`
+	Endpoint  string          `json:"endpoint"`
+	Status    string          `json:"status"`
+	Response  json.RawMessage `json:"response,omitempty"`
+	Error     *errors.Error   `json:"error,omitempty"`
+	Diff      []*jsonChange   `json:"diff,omitempty"`
+	Ignored   int             `json:"ignored,omitempty"`
+	Failures  []string        `json:"failures,omitempty"`
+	LatencyMs float64         `json:"latency_ms"`
+	Node      string          `json:"node,omitempty"`
+}
+
+// replayReport 是重放整个套件的结果
+type replayReport struct {
+	Name      string          `json:"name"`
+	Passed    bool            `json:"passed"`
+	Failed    int             `json:"failed"`
+	Calls     []*goldenResult `json:"calls"`
+	LatencyMs float64         `json:"latency_ms"`
+}
+
+// compileIgnore 把忽略规则转成匹配 jsonDiff 路径的正则，匹配的字段和它下面的字段都忽略
+// $.created_at 只忽略这个字段，$.items[*].id 中 [*] 是任意下标，.* 是任意字段，
+// $..updated_at 是任意层的 updated_at，不以 $ 开头的 updated_at 和它相同
+func compileIgnore(rule string) (*regexp.Regexp, error) {
+	rule = strings.TrimSpace(rule)
+	if len(rule) == 0 {
+		return nil, fmt.Errorf("empty ignore rule")
+	}
+	if !strings.HasPrefix(rule, "$") {
+		rule = "$.." + strings.TrimPrefix(rule, ".")
+	}
+	var b strings.Builder
+	b.WriteString(`^\$`)
+	p := rule[1:]
+	for len(p) > 0 {
+		switch {
+		case strings.HasPrefix(p, ".."):
+			// 留下一个 . 作为后面字段的开头
+			b.WriteString(`(\.[^.\[]+|\[[^\]]+\])*`)
+			p = p[1:]
+		case strings.HasPrefix(p, ".*"):
+			b.WriteString(`(\.[^.\[]+|\['[^']*'\])`)
+			p = p[2:]
+		case strings.HasPrefix(p, "[*]"):
+			b.WriteString(`\[[0-9]+\]`)
+			p = p[3:]
+		default:
+			b.WriteString(regexp.QuoteMeta(p[:1]))
+			p = p[1:]
+		}
+	}
+	b.WriteString(`($|[.\[])`)
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid ignore rule %q", rule)
+	}
+	return re, nil
+}
+
+func compileIgnores(rules []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, rule := range rules {
+		re, err := compileIgnore(rule)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// parseGoldenSuite 解析 JSON 或 YAML 格式的套件
+func parseGoldenSuite(b []byte) (*goldenSuite, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] != '{' {
+		var err error
+		if b, err = yaml.YAMLToJSON(b); err != nil {
+			return nil, err
+		}
+	}
+	s := new(goldenSuite)
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	if _, err := compileIgnores(s.Ignore); err != nil {
+		return nil, err
+	}
+	for i, g := range s.Calls {
+		if len(g.Name) == 0 {
+			g.Name = fmt.Sprintf("%s %s", g.Service, g.Endpoint)
+		}
+		if _, err := compileIgnores(g.Ignore); err != nil {
+			return nil, fmt.Errorf("%s: %v", g.Name, err)
+		}
+		if g.ID == 0 {
+			g.ID = uint64(i + 1)
+		}
+	}
+	return s, nil
+}
+
+// replay 按顺序重新发出调用，和记录的结果比较，r 为 nil 时（命令行中运行）不带 HTTP header
+// ignore 是额外忽略的字段
+func (s *goldenSuite) replay(r *http.Request, ignore []string) *replayReport {
+	start := time.Now()
+	rep := &replayReport{Name: s.Name, Passed: true, Calls: []*goldenResult{}}
+	// 规则在解析和修改时已经检查过
+	rules, _ := compileIgnores(append(append([]string{}, s.Ignore...), ignore...))
+	for _, g := range s.Calls {
+		res := g.replay(r, rules)
+		if res.Status != scenarioPass {
+			rep.Passed = false
+			rep.Failed++
+		}
+		rep.Calls = append(rep.Calls, res)
+	}
+	rep.LatencyMs = durationMs(time.Since(start))
+	return rep
+}
+
+// replay 重放一个调用，rules 是整个套件忽略的字段
+func (g *goldenCall) replay(r *http.Request, rules []*regexp.Regexp) *goldenResult {
+	gr := &goldenResult{ID: g.ID, Name: g.Name, Service: g.Service, Endpoint: g.Endpoint, Status: scenarioFail}
+	own, _ := compileIgnores(g.Ignore)
+	rules = append(own, rules...)
+	c, err := g.call()
+	if err != nil {
+		gr.Failures = []string{err.Error()}
+		return gr
+	}
+
+	res := c.do(requestContext(r), r)
+	history.add(newHistoryEntry(c, res))
+	gr.LatencyMs = durationMs(res.latency)
+	if res.node != nil {
+		gr.Node = res.node.Address
+	}
+	gr.Response = res.response
+	gr.Error = res.err
+
+	// 两边都改回 proto 原名再比较，记录和重放时字段名的写法可以不同
+	want, ok := protoResponse(g.Service, g.Endpoint, g.Response)
+	got, _ := protoResponse(g.Service, g.Endpoint, res.response)
+	if recorded := responseNaming(g.Transport, g.Naming); !ok && recorded != namingProto {
+		gr.Failures = []string{fmt.Sprintf("recorded with %s field naming, replayed with proto, and no schema to convert between them", recorded)}
+		return gr
+	}
+	for _, change := range jsonDiff(resultValue(want, g.Error), resultValue(got, res.err), "$") {
+		if ignored(rules, change.Path) {
+			gr.Ignored++
+			continue
+		}
+		gr.Diff = append(gr.Diff, change)
+		gr.Failures = append(gr.Failures, change.String())
+	}
+	if len(gr.Diff) == 0 {
+		gr.Status = scenarioPass
+	}
+	return gr
+}
+
+// responseNaming 返回记录的响应中字段名实际的写法，只有以前 gRPC 按 camel 记录的响应和 proto 原名不同
+func responseNaming(transport, naming string) string {
+	if transport == transportGRPC && naming == namingCamel {
+		return namingCamel
+	}
+	return namingProto
+}
+
+func ignored(rules []*regexp.Regexp, path string) bool {
+	for _, re := range rules {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *jsonChange) String() string {
+	switch c.Kind {
+	case "added":
+		return fmt.Sprintf("%s: unexpected %s", c.Path, jsonText(c.Got))
+	case "removed":
+		return fmt.Sprintf("%s: missing, expected %s", c.Path, jsonText(c.Want))
+	}
+	return fmt.Sprintf("%s: expected %s, got %s", c.Path, jsonText(c.Want), jsonText(c.Got))
+}
+
+// print 以文本格式输出结果
+func (rep *replayReport) print(w io.Writer) {
+	status := "PASS"
+	if !rep.Passed {
+		status = "FAIL"
+	}
+	fmt.Fprintf(w, "%s %s: %d call(s), %d failed (%.1fms)\n", status, rep.Name, len(rep.Calls), rep.Failed, rep.LatencyMs)
+	for _, c := range rep.Calls {
+		fmt.Fprintf(w, "  %-4s %s (%.1fms)", strings.ToUpper(c.Status), c.Name, c.LatencyMs)
+		if c.Ignored > 0 {
+			fmt.Fprintf(w, ", %d ignored", c.Ignored)
+		}
+		fmt.Fprintln(w)
+		for _, f := range c.Failures {
+			fmt.Fprintf(w, "       %s\n", f)
+		}
+	}
+}
+
+// runReplayFiles 在命令行中重放文件中的套件，有调用的结果不同时返回错误
+func runReplayFiles(files []string, ignore []string, asJSON bool, w io.Writer) error {
+	if len(files) == 0 {
+		return fmt.Errorf("no suite files")
+	}
+	if _, err := compileIgnores(ignore); err != nil {
+		return err
+	}
+	reports := []*replayReport{}
+	failed := 0
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		s, err := parseGoldenSuite(b)
+		if err != nil {
+			return fmt.Errorf("load %s: %v", file, err)
+		}
+		if len(s.Name) == 0 {
+			s.Name = file
+		}
+		rep := s.replay(nil, ignore)
+		failed += rep.Failed
+		if asJSON {
+			reports = append(reports, rep)
+		} else {
+			rep.print(w)
+		}
+	}
+	if asJSON {
+		b, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(b))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d call(s) differ from the recorded results", failed)
+	}
+	return nil
+}
+
+type goldenSet struct {
+	sync.RWMutex
+	file  string
+	suite *goldenSuite
+}
+
+var golden = &goldenSet{suite: &goldenSuite{Calls: []*goldenCall{}}}
+
+// loadGoldenFile 加载保存的套件，文件不存在时在第一次保存时创建
+func loadGoldenFile(file string) error {
+	golden.Lock()
+	defer golden.Unlock()
+	golden.file = file
+	if len(file) == 0 {
+		return nil
+	}
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s, err := parseGoldenSuite(b)
+	if err != nil {
+		return fmt.Errorf("load %s: %v", file, err)
+	}
+	golden.suite = s
+	return nil
+}
+
+// save 把套件写回文件，调用时需持有锁
+func (s *goldenSet) save() error {
+	if len(s.file) == 0 {
+		return nil
+	}
+	b, err := json.MarshalIndent(s.suite, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.file, b, 0600)
+}
+
+// get 返回套件的副本，id 不为 0 时只包括这个调用
+func (s *goldenSet) get(id uint64) *goldenSuite {
+	s.RLock()
+	defer s.RUnlock()
+	suite := *s.suite
+	suite.Calls = []*goldenCall{}
+	for _, g := range s.suite.Calls {
+		if id == 0 || g.ID == id {
+			suite.Calls = append(suite.Calls, g)
+		}
+	}
+	return &suite
+}
+
+// add 把一条调用历史标记为 golden
+func (s *goldenSet) add(e *historyEntry, name string, ignore []string) (*goldenCall, error) {
+	if _, err := compileIgnores(ignore); err != nil {
+		return nil, err
+	}
+	s.Lock()
+	defer s.Unlock()
+	g := &goldenCall{Name: name, historyEntry: *e, Ignore: ignore}
+	// 以前的调用历史中可能还有 token
+	g.Metadata = redactMetadata(g.Metadata)
+	if len(g.Name) == 0 {
+		g.Name = fmt.Sprintf("%s %s", g.Service, g.Endpoint)
+	}
+	g.ID = 1
+	if n := len(s.suite.Calls); n > 0 {
+		g.ID = s.suite.Calls[n-1].ID + 1
+	}
+	s.suite.Calls = append(s.suite.Calls, g)
+	return g, s.save()
+}
+
+// update 修改调用的名字和忽略的字段，id 为 0 时修改套件
+func (s *goldenSet) update(id uint64, name string, ignore []string) error {
+	if _, err := compileIgnores(ignore); err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	if id == 0 {
+		s.suite.Name = name
+		s.suite.Ignore = ignore
+		return s.save()
+	}
+	for _, g := range s.suite.Calls {
+		if g.ID == id {
+			if len(name) > 0 {
+				g.Name = name
+			}
+			g.Ignore = ignore
+			return s.save()
+		}
+	}
+	return fmt.Errorf("no golden call %d", id)
+}
+
+// delete 删除一个调用
+func (s *goldenSet) delete(id uint64) error {
+	s.Lock()
+	defer s.Unlock()
+	for i, g := range s.suite.Calls {
+		if g.ID == id {
+			s.suite.Calls = append(s.suite.Calls[:i], s.suite.Calls[i+1:]...)
+			return s.save()
+		}
+	}
+	return nil
+}
+
+// goldenRequest 是标记或修改 golden 调用的请求
+type goldenRequest struct {
+	// 标记为 golden 的调用历史
+	History uint64   `json:"history"`
+	Name    string   `json:"name"`
+	Ignore  []string `json:"ignore"`
+}
+
+// goldenHandler 显示 golden 调用，JSON 请求时返回套件
+// POST /golden {"history": 12, "name": "get user", "ignore": ["$.user.updated_at"]}
+// PUT /golden?id=3 {"name": "get user", "ignore": [...]}，不带 id 时修改套件的名字和忽略的字段
+// DELETE /golden?id=3
+// GET /golden?download=true 下载套件，可以用 replay 命令运行
+func goldenHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "ParseForm err:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var id uint64
+	if s := r.Form.Get("id"); len(s) > 0 {
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			http.Error(w, "Error occurred: invalid id", http.StatusBadRequest)
+			return
+		}
+		id = n
+	}
+
+	switch r.Method {
+	case "POST", "PUT":
+		req := new(goldenRequest)
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.Method == "PUT" {
+			if err := golden.update(id, req.Name, req.Ignore); err != nil {
+				http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+		e, err := history.get(req.History)
+		if err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if e == nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		g, err := golden.add(e, req.Name, req.Ignore)
+		if err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+			return
+		}
+		b, _ := json.Marshal(g)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+		return
+	case "DELETE":
+		if err := golden.delete(id); err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	download, _ := strconv.ParseBool(r.Form.Get("download"))
+	if !download && r.Header.Get("Content-Type") != "application/json" {
+		render(w, r, goldenTemplate, nil)
+		return
+	}
+	b, err := json.MarshalIndent(golden.get(id), "", "\t")
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if download {
+		name := filepath.Base(golden.file)
+		if len(golden.file) == 0 {
+			name = "golden.json"
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	}
+	w.Write(b)
+}
+
+// goldenReplayHandler 重放 golden 调用并返回和记录的结果的差异
+// POST /golden/replay?id=3，不带 id 时重放整个套件
+func goldenReplayHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "ParseForm err:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var id uint64
+	if s := r.Form.Get("id"); len(s) > 0 {
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			http.Error(w, "Error occurred: invalid id", http.StatusBadRequest)
+			return
+		}
+		id = n
+	}
+	suite := golden.get(id)
+	if id > 0 && len(suite.Calls) == 0 {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	b, err := json.Marshal(suite.replay(r, nil))
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
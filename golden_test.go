@@ -0,0 +1,105 @@
+package web
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompileIgnore(t *testing.T) {
+	tests := []struct {
+		rule  string
+		path  string
+		match bool
+	}{
+		{rule: "$.created_at", path: "$.created_at", match: true},
+		// 字段下面的字段也忽略
+		{rule: "$.user", path: "$.user.name", match: true},
+		{rule: "$.user", path: "$.users", match: false},
+		{rule: "$.user", path: "$.user[0]", match: true},
+		{rule: "$.created_at", path: "$.user.created_at", match: false},
+		{rule: "$.items[*].id", path: "$.items[3].id", match: true},
+		{rule: "$.items[*].id", path: "$.items[3].name", match: false},
+		{rule: "$.items[0].id", path: "$.items[1].id", match: false},
+		{rule: "$.*.id", path: "$.user.id", match: true},
+		{rule: "$.*.id", path: "$['x-user'].id", match: true},
+		{rule: "$.*.id", path: "$.a.b.id", match: false},
+		{rule: "$..updated_at", path: "$.updated_at", match: true},
+		{rule: "$..updated_at", path: "$.items[2].meta.updated_at", match: true},
+		{rule: "$..updated_at", path: "$.items[2].last_updated_at", match: false},
+		{rule: "updated_at", path: "$.user.updated_at", match: true},
+		{rule: ".updated_at", path: "$.user.updated_at", match: true},
+		{rule: "$['x-trace']", path: "$['x-trace']", match: true},
+		{rule: "$.error.detail", path: "$.error.detail", match: true},
+	}
+	for _, tt := range tests {
+		re, err := compileIgnore(tt.rule)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.rule, err)
+			continue
+		}
+		if got := re.MatchString(tt.path); got != tt.match {
+			t.Errorf("%s: expected match %v for %s, got %v", tt.rule, tt.match, tt.path, got)
+		}
+	}
+	if _, err := compileIgnore("  "); err == nil {
+		t.Error("expected an error for an empty rule")
+	}
+}
+
+// 重放时报告和记录的结果不同的字段，忽略的字段只计数
+func TestGoldenReplay(t *testing.T) {
+	_, _, reset := useMemoryRegistry()
+	defer reset()
+	m := startTestMock(t, testMock)
+	defer stopTestMock(m)
+
+	s, err := parseGoldenSuite([]byte(`
+name: users
+ignore: [$.tags]
+calls:
+  - service: go.micro.srv.mock
+    endpoint: User.Get
+    request: {"id": "u-1", "age": 30}
+    response: {"id": "u-1", "age": 30, "tags": ["c"]}
+  - name: changed
+    service: go.micro.srv.mock
+    endpoint: User.Get
+    request: {"id": "u-2", "age": 31}
+    response: {"id": "u-2", "age": 30}
+  - name: not found
+    service: go.micro.srv.mock
+    endpoint: User.Get
+    request: {"id": "missing"}
+    error: {"id": "go.micro.srv.mock", "code": 404, "detail": "user not found", "status": "Not Found"}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := s.replay(nil, nil)
+	if rep.Passed || rep.Failed != 1 {
+		t.Fatalf("expected one failed call, got %s", compactJSON(t, rep))
+	}
+	tests := []struct {
+		name     string
+		status   string
+		ignored  int
+		failures string
+	}{
+		// tags[0] 不同，tags[1] 多出来
+		{name: "go.micro.srv.mock User.Get", status: scenarioPass, ignored: 2},
+		{name: "changed", status: scenarioFail, ignored: 1, failures: "$.age: expected 30, got 31"},
+		{name: "not found", status: scenarioPass},
+	}
+	for i, tt := range tests {
+		res := rep.Calls[i]
+		if res.Name != tt.name || res.Status != tt.status || res.Ignored != tt.ignored || strings.Join(res.Failures, "; ") != tt.failures {
+			t.Errorf("%s: unexpected result %s", tt.name, compactJSON(t, res))
+		}
+	}
+
+	// 命令行中额外忽略的字段
+	rep = s.replay(nil, []string{"$.age", "tags"})
+	if !rep.Passed {
+		t.Errorf("expected the extra ignore rules to pass the suite, got %s", compactJSON(t, rep))
+	}
+}
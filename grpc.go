@@ -0,0 +1,629 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/micro/go-micro/errors"
+	"github.com/micro/go-micro/registry"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// /rpc 的两种调用方式
+const (
+	// 通过 go-micro client 调用，请求体按 JSON 编码
+	transportMicro = "micro"
+	// 直接发 gRPC 请求，按 proto 描述把 JSON 转成 protobuf，可以调用不是 go-micro 写的服务
+	transportGRPC = "grpc"
+)
+
+// 不转发给 gRPC 服务的 HTTP header，HTTP/2 中不允许出现或由 grpc 自己设置
+var grpcSkipHeaders = map[string]bool{
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"host":              true,
+	"te":                true,
+	"content-length":    true,
+	"content-type":      true,
+	"accept-encoding":   true,
+	"user-agent":        true,
+	"timeout":           true,
+}
+
+// 最多缓存的连接数，地址由页面随意填写，超过时关闭最久没用过的空闲连接
+const grpcMaxConns = 64
+
+// grpcConns 按地址缓存到 gRPC 服务的连接
+type grpcConns struct {
+	sync.Mutex
+	conns map[string]*grpcConn
+}
+
+// grpcConn 是缓存的连接，refs 是正在使用连接的调用和流数
+type grpcConn struct {
+	conn *grpc.ClientConn
+	refs int
+	used time.Time
+}
+
+var grpcClients = &grpcConns{conns: make(map[string]*grpcConn)}
+
+// get 取得到 address 的连接，用完后调用 release，之后连接才可能被关闭
+func (g *grpcConns) get(address string) (*grpc.ClientConn, func(), error) {
+	g.Lock()
+	defer g.Unlock()
+	c, ok := g.conns[address]
+	if !ok {
+		conn, err := grpc.Dial(address, grpc.WithInsecure())
+		if err != nil {
+			return nil, nil, err
+		}
+		g.evict()
+		c = &grpcConn{conn: conn}
+		g.conns[address] = c
+	}
+	c.refs++
+	c.used = time.Now()
+	var once sync.Once
+	return c.conn, func() {
+		once.Do(func() {
+			g.Lock()
+			c.refs--
+			c.used = time.Now()
+			g.Unlock()
+		})
+	}, nil
+}
+
+// evict 在缓存满时关闭最久没用过的空闲连接，连接都在用时暂时超过上限，调用时需持有锁
+func (g *grpcConns) evict() {
+	for len(g.conns) >= grpcMaxConns {
+		var oldest string
+		for address, c := range g.conns {
+			if c.refs == 0 && (len(oldest) == 0 || c.used.Before(g.conns[oldest].used)) {
+				oldest = address
+			}
+		}
+		if len(oldest) == 0 {
+			return
+		}
+		g.conns[oldest].conn.Close()
+		delete(g.conns, oldest)
+	}
+}
+
+// waitReady 等连接建好，连接失败或 ctx 结束时不再等，错误留给后面的请求返回
+func waitReady(ctx context.Context, conn *grpc.ClientConn) {
+	for {
+		s := conn.GetState()
+		if s != connectivity.Connecting {
+			return
+		}
+		if !conn.WaitForStateChange(ctx, s) {
+			return
+		}
+	}
+}
+
+// grpcContext 把 HTTP header、服务的默认 metadata 和页面上填写的 metadata 放进 gRPC 的 metadata
+func grpcContext(ctx context.Context, r *http.Request, service string, md map[string]string) context.Context {
+	out := metadata.MD{}
+	for k, v := range callMetadata(r, service, md) {
+		k = strings.ToLower(k)
+		if grpcSkipHeaders[k] || strings.HasPrefix(k, "grpc-") {
+			continue
+		}
+		out[k] = []string{v}
+	}
+	return metadata.NewOutgoingContext(ctx, out)
+}
+
+// grpcMethodName 把 endpoint 名拆成服务名和方法名
+// 支持 /pkg.Service/Method、pkg.Service/Method、pkg.Service.Method 和 go-micro 的 Service.Method
+func grpcMethodName(endpoint string) (service, method string) {
+	endpoint = strings.TrimPrefix(endpoint, "/")
+	i := strings.LastIndex(endpoint, "/")
+	if i < 0 {
+		i = strings.LastIndex(endpoint, ".")
+	}
+	if i < 0 {
+		return "", endpoint
+	}
+	return endpoint[:i], endpoint[i+1:]
+}
+
+// grpcPath 是 gRPC 请求的路径，如 /go.micro.srv.hello.Say/Hello
+func grpcPath(md protoreflect.MethodDescriptor) string {
+	return fmt.Sprintf("/%s/%s", md.Parent().FullName(), md.Name())
+}
+
+// protoFiles 把已加载的文件描述转成 protoregistry.Files，缓存到下次加载文件
+func (d *descriptorSet) protoFiles() *protoregistry.Files {
+	d.Lock()
+	defer d.Unlock()
+	if d.resolved != nil {
+		return d.resolved
+	}
+
+	files := new(protoregistry.Files)
+	done := make(map[string]bool)
+	var register func(name string) error
+	register = func(name string) error {
+		if done[name] {
+			return nil
+		}
+		done[name] = true
+		fd, ok := d.files[name]
+		if !ok {
+			// 上传的文件没有带依赖时用编译进来的，如 google/protobuf/timestamp.proto
+			f, err := protoregistry.GlobalFiles.FindFileByPath(name)
+			if err != nil {
+				return err
+			}
+			return files.RegisterFile(f)
+		}
+		for _, dep := range fd.Dependency {
+			if err := register(dep); err != nil {
+				return err
+			}
+		}
+		f, err := protodesc.NewFile(fd, files)
+		if err != nil {
+			return err
+		}
+		return files.RegisterFile(f)
+	}
+	// 有问题的文件跳过，不影响其他文件中的方法
+	for name := range d.files {
+		register(name)
+	}
+	d.resolved = files
+	return files
+}
+
+// findMethod 按服务名和方法名找方法描述，服务名可以不带包名
+func findMethod(files *protoregistry.Files, service, method string) protoreflect.MethodDescriptor {
+	if d, err := files.FindDescriptorByName(protoreflect.FullName(service)); err == nil {
+		if sd, ok := d.(protoreflect.ServiceDescriptor); ok {
+			return sd.Methods().ByName(protoreflect.Name(method))
+		}
+		return nil
+	}
+	var found protoreflect.MethodDescriptor
+	files.RangeFiles(func(f protoreflect.FileDescriptor) bool {
+		for i := 0; i < f.Services().Len(); i++ {
+			sd := f.Services().Get(i)
+			if string(sd.Name()) != service {
+				continue
+			}
+			if found = sd.Methods().ByName(protoreflect.Name(method)); found != nil {
+				return false
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// resolveMethod 先在已加载的描述中找方法，找不到时通过服务的 reflection 接口加载
+func resolveMethod(ctx context.Context, conn *grpc.ClientConn, endpoint string) (protoreflect.MethodDescriptor, error) {
+	service, method := grpcMethodName(endpoint)
+	if len(service) == 0 {
+		return nil, fmt.Errorf("invalid endpoint %s", endpoint)
+	}
+	if md := findMethod(descriptors.protoFiles(), service, method); md != nil {
+		return md, nil
+	}
+	if err := reflectService(ctx, conn, service); err != nil {
+		return nil, err
+	}
+	if md := findMethod(descriptors.protoFiles(), service, method); md != nil {
+		return md, nil
+	}
+	return nil, fmt.Errorf("unknown method %s", endpoint)
+}
+
+// grpcReflection 通过 grpc.reflection.v1alpha.ServerReflection 读取服务的 proto 描述
+type grpcReflection struct {
+	stream rpb.ServerReflection_ServerReflectionInfoClient
+	// 已经取到的文件
+	files map[string]*descriptor.FileDescriptorProto
+}
+
+func newGRPCReflection(ctx context.Context, conn *grpc.ClientConn) (*grpcReflection, error) {
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &grpcReflection{stream: stream, files: make(map[string]*descriptor.FileDescriptorProto)}, nil
+}
+
+func (g *grpcReflection) ask(req *rpb.ServerReflectionRequest) (*rpb.ServerReflectionResponse, error) {
+	if err := g.stream.Send(req); err != nil {
+		return nil, err
+	}
+	rsp, err := g.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if e := rsp.GetErrorResponse(); e != nil {
+		return nil, status.Error(codes.Code(e.GetErrorCode()), e.GetErrorMessage())
+	}
+	return rsp, nil
+}
+
+// services 列出服务提供的 gRPC 服务，不含 reflection 服务本身
+func (g *grpcReflection) services() ([]string, error) {
+	rsp, err := g.ask(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{ListServices: "*"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, s := range rsp.GetListServicesResponse().GetService() {
+		if !strings.HasPrefix(s.GetName(), "grpc.reflection.") {
+			names = append(names, s.GetName())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// load 取到定义 symbol 的文件和它依赖的文件
+func (g *grpcReflection) load(symbol string) error {
+	rsp, err := g.ask(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: symbol},
+	})
+	if err != nil {
+		return err
+	}
+	if err := g.add(rsp); err != nil {
+		return err
+	}
+	// 服务一般会把依赖一起返回，没有返回的再逐个取
+	for {
+		missing := g.missing()
+		if len(missing) == 0 {
+			return nil
+		}
+		for _, name := range missing {
+			rsp, err := g.ask(&rpb.ServerReflectionRequest{
+				MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: name},
+			})
+			if err != nil {
+				return err
+			}
+			if err := g.add(rsp); err != nil {
+				return err
+			}
+			if _, ok := g.files[name]; !ok {
+				return fmt.Errorf("proto file %s not found", name)
+			}
+		}
+	}
+}
+
+func (g *grpcReflection) add(rsp *rpb.ServerReflectionResponse) error {
+	for _, b := range rsp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+		fd := new(descriptor.FileDescriptorProto)
+		if err := proto.Unmarshal(b, fd); err != nil {
+			return err
+		}
+		g.files[fd.GetName()] = fd
+	}
+	return nil
+}
+
+// missing 列出还没取到、也没有编译进来的依赖
+func (g *grpcReflection) missing() []string {
+	var names []string
+	for _, fd := range g.files {
+		for _, dep := range fd.Dependency {
+			if _, ok := g.files[dep]; ok {
+				continue
+			}
+			if _, err := protoregistry.GlobalFiles.FindFileByPath(dep); err == nil {
+				continue
+			}
+			names = append(names, dep)
+		}
+	}
+	return names
+}
+
+// reflectService 通过 reflection 加载服务的 proto 描述，服务名不带包名时按名字匹配
+func reflectService(ctx context.Context, conn *grpc.ClientConn, service string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	g, err := newGRPCReflection(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer g.stream.CloseSend()
+
+	if !strings.Contains(service, ".") {
+		names, err := g.services()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if strings.HasSuffix(name, "."+service) {
+				service = name
+				break
+			}
+		}
+	}
+	if err := g.load(service); err != nil {
+		return err
+	}
+	for _, fd := range g.files {
+		descriptors.add(fd)
+	}
+	return nil
+}
+
+// grpcInvoke 把 JSON 请求转成 protobuf 调用 gRPC 方法，返回 JSON 格式的响应
+// t 不为 nil 时记下 protobuf 编码后请求和响应的大小
+func grpcInvoke(ctx context.Context, conn *grpc.ClientConn, md protoreflect.MethodDescriptor, request interface{}, t *rpcTiming) ([]byte, error) {
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return nil, fmt.Errorf("%s is a streaming method", grpcPath(md))
+	}
+
+	b, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	in := dynamicpb.NewMessage(md.Input())
+	if err := protojson.Unmarshal(b, in); err != nil {
+		return nil, err
+	}
+
+	out := dynamicpb.NewMessage(md.Output())
+	if t != nil {
+		t.requestBytes = protov2.Size(in)
+	}
+	if err := conn.Invoke(ctx, grpcPath(md), in, out); err != nil {
+		return nil, err
+	}
+	if t != nil {
+		t.responseBytes = protov2.Size(out)
+	}
+
+	return protojson.MarshalOptions{
+		UseProtoNames:   true,
+		EmitUnpopulated: true,
+	}.Marshal(out)
+}
+
+// grpcError 把 gRPC 的 status 转成和 go-micro 一样的错误格式
+// go-micro 的 grpc server 把 go-micro 的错误以 JSON 放在 status 的 message 中，这时直接用原来的错误
+func grpcError(id string, err error) *errors.Error {
+	st := status.Convert(err)
+	if e := errors.Parse(st.Message()); e.Code > 0 {
+		return e
+	}
+	code := http.StatusInternalServerError
+	switch st.Code() {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		code = http.StatusBadRequest
+	case codes.Unauthenticated:
+		code = http.StatusUnauthorized
+	case codes.PermissionDenied:
+		code = http.StatusForbidden
+	case codes.NotFound:
+		code = http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		code = http.StatusConflict
+	case codes.ResourceExhausted:
+		code = http.StatusTooManyRequests
+	case codes.DeadlineExceeded:
+		code = http.StatusRequestTimeout
+	case codes.Unimplemented:
+		code = http.StatusNotImplemented
+	case codes.Unavailable:
+		code = http.StatusServiceUnavailable
+	}
+	return &errors.Error{
+		Id:     id,
+		Code:   int32(code),
+		Detail: st.Message(),
+		Status: st.Code().String(),
+	}
+}
+
+// grpcDo 以 gRPC 方式发出 /rpc 调用，服务不可用时按 retries 重试
+func grpcDo(ctx context.Context, r *http.Request, c *rpcCall) *rpcResult {
+	start := time.Now()
+	address, err := grpcAddress(c.service, c.address)
+	if err != nil {
+		return &rpcResult{err: badRequestError(err.Error())}
+	}
+	result := &rpcResult{node: &registry.Node{Id: address, Address: address}}
+	result.timing.selector = time.Since(start)
+
+	start = time.Now()
+	conn, release, err := grpcClients.get(address)
+	if err != nil {
+		result.err = callError(err)
+		return result
+	}
+	defer release()
+
+	ctx = grpcContext(ctx, r, c.service, c.metadata)
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	waitReady(ctx, conn)
+	result.timing.connect = time.Since(start)
+
+	method, err := resolveMethod(ctx, conn, c.endpoint)
+	if err != nil {
+		result.err = grpcError("go.micro.rpc", err)
+		if st, ok := status.FromError(err); ok {
+			result.grpcCode = st.Code().String()
+		}
+		return result
+	}
+	result.output = "." + string(method.Output().FullName())
+	if c.strict {
+		schema := descriptors.messageSchema("."+string(method.Input().FullName()), fieldNaming)
+		if errs := validateRequest(schema, c.request); len(errs) > 0 {
+			result.err = badRequestError(strings.Join(errs, "; "))
+			return result
+		}
+	}
+
+	retries := c.retries
+	if retries < 0 {
+		retries = 0
+	}
+	for {
+		result.attempts++
+		attempt := time.Now()
+		b, err := grpcAttempt(ctx, conn, method, c, &result.timing)
+		result.timing.server = time.Since(attempt)
+		if err == nil {
+			result.response = b
+			return result
+		}
+		if status.Code(err) != codes.Unavailable || result.attempts > retries {
+			result.err = grpcError(string(method.Parent().FullName()), err)
+			result.grpcCode = status.Code(err).String()
+			return result
+		}
+	}
+}
+
+// grpcAttempt 发出一次请求，request_timeout 只限制这一次
+func grpcAttempt(ctx context.Context, conn *grpc.ClientConn, method protoreflect.MethodDescriptor, c *rpcCall, t *rpcTiming) ([]byte, error) {
+	if c.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+		defer cancel()
+	}
+	return grpcInvoke(ctx, conn, method, c.request, t)
+}
+
+// grpcAddress 没有指定地址时取 registry 中服务的第一个节点
+func grpcAddress(service, address string) (string, error) {
+	if len(address) > 0 {
+		return address, nil
+	}
+	if len(service) == 0 {
+		return "", fmt.Errorf("service or address is required")
+	}
+	s, err := defaultRegistry().GetService(service)
+	if err != nil {
+		return "", err
+	}
+	for _, svc := range s {
+		for _, node := range svc.Nodes {
+			return node.Address, nil
+		}
+	}
+	return "", fmt.Errorf("no nodes found for service %s", service)
+}
+
+// grpcHandler 通过 reflection 列出服务的方法和请求示例
+// GET /grpc?address=127.0.0.1:9090&profile=fake
+func grpcHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "ParseForm err:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	address, err := grpcAddress(r.Form.Get("service"), r.Form.Get("address"))
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	profile := r.Form.Get("profile")
+	if len(profile) == 0 {
+		profile = defaultProfile
+	}
+
+	conn, release, err := grpcClients.get(address)
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer release()
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	g, err := newGRPCReflection(ctx, conn)
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer g.stream.CloseSend()
+	names, err := g.services()
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusBadGateway)
+		return
+	}
+	for _, name := range names {
+		if err := g.load(name); err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+	for _, fd := range g.files {
+		descriptors.add(fd)
+	}
+
+	files := descriptors.protoFiles()
+	values := profiles.get(profile, r.Form.Get("service")).values(0)
+	var methods []interface{}
+	for _, name := range names {
+		d, err := files.FindDescriptorByName(protoreflect.FullName(name))
+		if err != nil {
+			continue
+		}
+		sd, ok := d.(protoreflect.ServiceDescriptor)
+		if !ok {
+			continue
+		}
+		for i := 0; i < sd.Methods().Len(); i++ {
+			md := sd.Methods().Get(i)
+			request, hints := descriptors.skeleton("."+string(md.Input().FullName()), values)
+			methods = append(methods, jsonObject{
+				{"name", strings.TrimPrefix(grpcPath(md), "/")},
+				{"request", request},
+				{"hints", hints},
+				{"streaming", md.IsStreamingClient() || md.IsStreamingServer()},
+			})
+		}
+	}
+
+	b, err := marshalJSON(jsonObject{{"address", address}, {"methods", methods}}, "")
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,90 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/micro/go-micro/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCConnsEvict(t *testing.T) {
+	g := &grpcConns{conns: make(map[string]*grpcConn)}
+	// 第一个连接一直在用，不会被关闭
+	busy, _, err := g.get("127.0.0.1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 2; i <= grpcMaxConns+10; i++ {
+		_, release, err := g.get(fmt.Sprintf("127.0.0.1:%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+		// 多次调用不会重复减少
+		release()
+	}
+	if len(g.conns) != grpcMaxConns {
+		t.Fatalf("expected %d cached connections, got %d", grpcMaxConns, len(g.conns))
+	}
+	if c, ok := g.conns["127.0.0.1:1"]; !ok || c.refs != 1 || busy.GetState() == connectivity.Shutdown {
+		t.Fatal("expected the connection in use to stay open")
+	}
+	// 最早的空闲连接被关闭
+	if _, ok := g.conns["127.0.0.1:2"]; ok {
+		t.Fatal("expected the oldest idle connection to be evicted")
+	}
+	if _, ok := g.conns[fmt.Sprintf("127.0.0.1:%d", grpcMaxConns+10)]; !ok {
+		t.Fatal("expected the newest connection to be cached")
+	}
+	for _, c := range g.conns {
+		c.conn.Close()
+	}
+}
+
+func TestGRPCError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want errors.Error
+	}{
+		{name: "not found", err: status.Error(codes.NotFound, "no user"), want: errors.Error{Id: "test.Users", Code: http.StatusNotFound, Detail: "no user", Status: "NotFound"}},
+		{name: "invalid argument", err: status.Error(codes.InvalidArgument, "bad id"), want: errors.Error{Id: "test.Users", Code: http.StatusBadRequest, Detail: "bad id", Status: "InvalidArgument"}},
+		{name: "unavailable", err: status.Error(codes.Unavailable, "down"), want: errors.Error{Id: "test.Users", Code: http.StatusServiceUnavailable, Detail: "down", Status: "Unavailable"}},
+		{name: "unknown", err: status.Error(codes.Unknown, "boom"), want: errors.Error{Id: "test.Users", Code: http.StatusInternalServerError, Detail: "boom", Status: "Unknown"}},
+		// go-micro 的 grpc server 把错误以 JSON 放在 message 中
+		{name: "go-micro error", err: status.Error(codes.Unknown, errors.Forbidden("go.micro.srv.users", "no access").Error()), want: errors.Error{Id: "go.micro.srv.users", Code: http.StatusForbidden, Detail: "no access", Status: "Forbidden"}},
+	}
+	for _, tt := range tests {
+		if got := grpcError("test.Users", tt.err); *got != tt.want {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, *got)
+		}
+	}
+}
+
+// gRPC 的响应按 proto 原名输出，显示时按 proto 描述改写成 fieldNaming 的写法
+func TestGRPCDisplayResult(t *testing.T) {
+	descriptors.add(testFile())
+	defer func(naming string) { fieldNaming = naming }(fieldNaming)
+
+	response := `{"id":"u-1","created_at":"2019-11-11T11:11:11Z","groups":[{"name":"admins","members":[{"id":"u-2","created_at":null}]}]}`
+	tests := []struct {
+		naming string
+		want   string
+	}{
+		{naming: namingProto, want: response},
+		{naming: namingCamel, want: `{"id":"u-1","createdAt":"2019-11-11T11:11:11Z","groups":[{"name":"admins","members":[{"id":"u-2","createdAt":null}]}]}`},
+		{naming: namingSnake, want: `{"id":"u-1","created_at":"2019-11-11T11:11:11Z","groups":[{"name":"admins","members":[{"id":"u-2","created_at":null}]}]}`},
+	}
+	c := &rpcCall{transport: transportGRPC, address: "127.0.0.1:9090", endpoint: "test.Users.Get"}
+	for _, tt := range tests {
+		fieldNaming = tt.naming
+		res := &rpcResult{response: []byte(response), output: ".test.User"}
+		if got := string(displayResult(c, res)); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.naming, tt.want, got)
+		}
+	}
+}
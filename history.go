@@ -0,0 +1,499 @@
+package web
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/micro/go-micro/errors"
+	"github.com/micro/go-micro/util/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+var historyBucket = []byte("calls")
+
+var (
+	// 调用历史保存的文件，为空时不记录
+	historyFile = "history.db"
+	// 最多保存的条数，超过时删掉最早的
+	historySize = 1000
+)
+
+// historyEntry 是一次 /rpc 调用的记录
+type historyEntry struct {
+	ID        uint64            `json:"id"`
+	Time      time.Time         `json:"time"`
+	Service   string            `json:"service"`
+	Endpoint  string            `json:"endpoint"`
+	Transport string            `json:"transport,omitempty"`
+	Address   string            `json:"address,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	// 请求和 metadata 是替换占位符之前的，Expand 为 true 时重放前按 Env 和 Vars 替换
+	Env     string                 `json:"env,omitempty"`
+	Expand  bool                   `json:"expand,omitempty"`
+	Vars    map[string]interface{} `json:"vars,omitempty"`
+	Request json.RawMessage        `json:"request"`
+	// 调用选项，重放时使用
+	Timeout        string `json:"timeout,omitempty"`
+	RequestTimeout string `json:"request_timeout,omitempty"`
+	Retries        int    `json:"retries"`
+
+	Status    string          `json:"status"`
+	Code      int             `json:"code"`
+	Response  json.RawMessage `json:"response,omitempty"`
+	Error     *errors.Error   `json:"error,omitempty"`
+	LatencyMs float64         `json:"latency_ms"`
+	Node      string          `json:"node,omitempty"`
+}
+
+// 写进文件时代替敏感 metadata 的值，调用时带这个值的 metadata 会被去掉
+const redactedValue = "<redacted>"
+
+// sensitiveKeys 是不写进文件的 metadata，键名中包含其中之一即可，不区分大小写
+var sensitiveKeys = []string{"authorization", "cookie", "token", "secret", "password", "api-key", "apikey", "api_key", "credential"}
+
+func sensitiveKey(k string) bool {
+	k = strings.ToLower(k)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(k, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactMetadata 返回去掉敏感值的副本，用于调用历史、请求集合和 golden
+// {{env.token}} 这样的占位符不是真的值，原样保留
+func redactMetadata(md map[string]string) map[string]string {
+	if len(md) == 0 {
+		return md
+	}
+	out := make(map[string]string, len(md))
+	for k, v := range md {
+		if sensitiveKey(k) && !strings.Contains(v, "{{") {
+			v = redactedValue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// newHistoryEntry 记录调用和结果，只保存页面上填写的 metadata，不保存浏览器的 header
+// 请求和 metadata 保存替换占位符之前的写法，token 之类的 metadata 不保存，重放时由服务的默认 metadata 补上
+func newHistoryEntry(c *rpcCall, res *rpcResult) *historyEntry {
+	e := &historyEntry{
+		Time:      time.Now(),
+		Service:   c.service,
+		Endpoint:  c.endpoint,
+		Transport: c.transport,
+		Address:   c.address,
+		Metadata:  redactMetadata(c.rawMetadata),
+		Env:       c.env,
+		Expand:    c.expand,
+		Retries:   c.retries,
+		LatencyMs: float64(res.latency) / float64(time.Millisecond),
+	}
+	if c.expand {
+		e.Vars = c.vars
+	}
+	// 字符串形式的请求是合法的 JSON 时按 JSON 保存，字符串外有占位符时保存字符串
+	if s, ok := c.rawRequest.(string); ok && json.Valid([]byte(s)) {
+		e.Request = json.RawMessage(s)
+	} else {
+		e.Request, _ = json.Marshal(c.rawRequest)
+	}
+	if c.timeout > 0 {
+		e.Timeout = c.timeout.String()
+	}
+	if c.requestTimeout > 0 {
+		e.RequestTimeout = c.requestTimeout.String()
+	}
+	if res.node != nil {
+		e.Node = res.node.Address
+	}
+	if res.err != nil {
+		e.Status = "error"
+		e.Code = int(res.err.Code)
+		e.Error = res.err
+	} else {
+		e.Status = "ok"
+		e.Code = http.StatusOK
+		e.Response = res.response
+	}
+	return e
+}
+
+// call 按记录重新构造调用，Expand 为 true 时重新替换占位符
+func (e *historyEntry) call() (*rpcCall, error) {
+	req := &rpcRequest{
+		Service:        e.Service,
+		Endpoint:       e.Endpoint,
+		Address:        e.Address,
+		Transport:      e.Transport,
+		Metadata:       e.Metadata,
+		Timeout:        e.Timeout,
+		RequestTimeout: e.RequestTimeout,
+		Retries:        &e.Retries,
+		Expand:         e.Expand,
+	}
+	d := json.NewDecoder(bytes.NewReader(e.Request))
+	d.UseNumber()
+	if err := d.Decode(&req.Request); err != nil {
+		return nil, err
+	}
+	// 不替换占位符时不需要环境，环境已经删掉也可以重放
+	var env string
+	if e.Expand {
+		env = e.Env
+	}
+	x, err := newExpander(env)
+	if err != nil {
+		return nil, err
+	}
+	x.keep = !e.Expand
+	x.values = e.Vars
+	return req.newCall(x)
+}
+
+// historyFilter 按服务、endpoint 和结果筛选记录
+type historyFilter struct {
+	service  string
+	endpoint string
+	// ok、error 或 HTTP 状态码
+	status string
+	limit  int
+}
+
+func (f historyFilter) match(e *historyEntry) bool {
+	if len(f.service) > 0 && !strings.Contains(e.Service, f.service) {
+		return false
+	}
+	if len(f.endpoint) > 0 && !strings.Contains(e.Endpoint, f.endpoint) {
+		return false
+	}
+	switch f.status {
+	case "":
+	case "ok", "error":
+		return e.Status == f.status
+	default:
+		return strconv.Itoa(e.Code) == f.status
+	}
+	return true
+}
+
+// 等待写入文件的记录数，写不过来时 add 会等待
+const historyQueueSize = 1024
+
+// historyStore 把调用记录保存在 bbolt 文件中
+// bbolt 每次写入都要 fsync，所以由后台的 goroutine 成批写入，/rpc 不用等写完
+type historyStore struct {
+	db   *bolt.DB
+	size int
+	// 最后分配的 ID，打开时取 bucket 的 sequence
+	seq   uint64
+	queue chan historyWrite
+}
+
+// historyWrite 是一条等待写入的记录，done 不为 nil 时只用来等前面的记录写完
+type historyWrite struct {
+	id    uint64
+	value []byte
+	done  chan struct{}
+}
+
+// 没有打开文件时为 nil，不记录
+var history *historyStore
+
+// openHistory 打开调用历史文件
+func openHistory(file string, size int) error {
+	if len(file) == 0 {
+		return nil
+	}
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	var seq uint64
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(historyBucket)
+		if err != nil {
+			return err
+		}
+		seq = b.Sequence()
+		return nil
+	}); err != nil {
+		db.Close()
+		return err
+	}
+	history = &historyStore{db: db, size: size, seq: seq, queue: make(chan historyWrite, historyQueueSize)}
+	go history.write()
+	return nil
+}
+
+func historyKey(id uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, id)
+	return k
+}
+
+// add 分配 ID 后把记录排进写入的队列，返回记录的 ID
+func (h *historyStore) add(e *historyEntry) (uint64, error) {
+	if h == nil {
+		return 0, nil
+	}
+	e.ID = atomic.AddUint64(&h.seq, 1)
+	v, err := json.Marshal(e)
+	if err != nil {
+		return 0, err
+	}
+	h.queue <- historyWrite{id: e.ID, value: v}
+	return e.ID, nil
+}
+
+// write 把队列中的记录成批写入文件
+func (h *historyStore) write() {
+	for w := range h.queue {
+		batch := []historyWrite{w}
+	more:
+		for {
+			select {
+			case w := <-h.queue:
+				batch = append(batch, w)
+			default:
+				break more
+			}
+		}
+		if err := h.put(batch); err != nil {
+			log.Logf("error recording call history: %v", err)
+		}
+		for _, w := range batch {
+			if w.done != nil {
+				close(w.done)
+			}
+		}
+	}
+}
+
+// put 在一个事务中写入记录，删掉最近 size 条以前的记录
+func (h *historyStore) put(batch []historyWrite) error {
+	return h.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(historyBucket)
+		last := b.Sequence()
+		for _, w := range batch {
+			if w.done != nil {
+				continue
+			}
+			if err := b.Put(historyKey(w.id), w.value); err != nil {
+				return err
+			}
+			if w.id > last {
+				last = w.id
+			}
+		}
+		if err := b.SetSequence(last); err != nil {
+			return err
+		}
+		// ID 连续递增
+		if h.size <= 0 || last <= uint64(h.size) {
+			return nil
+		}
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k) <= last-uint64(h.size); k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// flush 等队列中已有的记录写入文件
+func (h *historyStore) flush() {
+	done := make(chan struct{})
+	h.queue <- historyWrite{done: done}
+	<-done
+}
+
+// close 写完队列中的记录后关闭文件
+func (h *historyStore) close() error {
+	if h == nil {
+		return nil
+	}
+	h.flush()
+	return h.db.Close()
+}
+
+// get 按 ID 取记录，没有时返回 nil
+func (h *historyStore) get(id uint64) (*historyEntry, error) {
+	if h == nil {
+		return nil, nil
+	}
+	h.flush()
+	var e *historyEntry
+	err := h.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(historyBucket).Get(historyKey(id))
+		if v == nil {
+			return nil
+		}
+		e = new(historyEntry)
+		return json.Unmarshal(v, e)
+	})
+	return e, err
+}
+
+// list 按时间倒序列出符合条件的记录
+func (h *historyStore) list(f historyFilter) ([]*historyEntry, error) {
+	entries := []*historyEntry{}
+	if h == nil {
+		return entries, nil
+	}
+	h.flush()
+	err := h.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(historyBucket).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			e := new(historyEntry)
+			if err := json.Unmarshal(v, e); err != nil {
+				continue
+			}
+			if !f.match(e) {
+				continue
+			}
+			entries = append(entries, e)
+			if f.limit > 0 && len(entries) >= f.limit {
+				break
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// clear 删除所有记录
+func (h *historyStore) clear() error {
+	if h == nil {
+		return nil
+	}
+	h.flush()
+	return h.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(historyBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(historyBucket)
+		return err
+	})
+}
+
+// historyHandler 显示调用历史，JSON 请求时返回记录
+// GET /history?service=go.micro.srv.greeter&endpoint=Say.Hello&status=error&limit=50
+// GET /history?id=12
+// DELETE /history
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "ParseForm err:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == "DELETE" {
+		if err := history.clear(); err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var rsp interface{}
+	if id := r.Form.Get("id"); len(id) > 0 {
+		n, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			http.Error(w, "Error occurred: invalid id", http.StatusBadRequest)
+			return
+		}
+		e, err := history.get(n)
+		if err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if e == nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		rsp = e
+	} else {
+		if r.Header.Get("Content-Type") != "application/json" {
+			render(w, r, historyTemplate, nil)
+			return
+		}
+		f := historyFilter{
+			service:  r.Form.Get("service"),
+			endpoint: r.Form.Get("endpoint"),
+			status:   r.Form.Get("status"),
+			limit:    100,
+		}
+		if n, err := strconv.Atoi(r.Form.Get("limit")); err == nil {
+			f.limit = n
+		}
+		entries, err := history.list(f)
+		if err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		rsp = map[string]interface{}{"history": entries}
+	}
+
+	b, err := json.Marshal(rsp)
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// replayHandler 按记录重新发出调用，结果也记入历史，返回和 /rpc 的 envelope 相同的格式
+// POST /history/replay?id=12
+func replayHandler(w http.ResponseWriter, r *http.Request) {
+	// 重放会真的发出调用，不接受 GET，链接或预取不会触发
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Error occurred: replay requires POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "ParseForm err:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	n, err := strconv.ParseUint(r.Form.Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Error occurred: invalid id", http.StatusBadRequest)
+		return
+	}
+	e, err := history.get(n)
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if e == nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	c, err := e.call()
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res := c.do(r.Context(), r)
+	id, _ := history.add(newHistoryEntry(c, res))
+	res.response = displayResult(c, res)
+
+	b, _ := marshalJSON(append(res.envelope(), jsonField{"history", id}), "")
+	w.Header().Set("Content-Type", "application/json")
+	if res.err != nil {
+		w.WriteHeader(int(res.err.Code))
+	}
+	w.Write(b)
+}
@@ -0,0 +1,176 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTestHistory 在临时目录中打开调用历史，返回的函数关闭文件并恢复原来的 history
+func openTestHistory(t *testing.T, size int) (string, func()) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := history
+	file := filepath.Join(dir, "history.db")
+	if err := openHistory(file, size); err != nil {
+		t.Fatal(err)
+	}
+	return file, func() {
+		history.close()
+		history = old
+		os.RemoveAll(dir)
+	}
+}
+
+func TestRedactMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		in   map[string]string
+		want map[string]string
+	}{
+		{name: "empty"},
+		{name: "plain", in: map[string]string{"Tenant": "t1"}, want: map[string]string{"Tenant": "t1"}},
+		{name: "authorization", in: map[string]string{"Authorization": "Bearer abc"}, want: map[string]string{"Authorization": redactedValue}},
+		{name: "case and substring", in: map[string]string{"X-Api-Key": "k", "x-session-token": "s"}, want: map[string]string{"X-Api-Key": redactedValue, "x-session-token": redactedValue}},
+		// 占位符不是真的值，原样保留
+		{name: "placeholder", in: map[string]string{"Authorization": "Bearer {{env.token}}"}, want: map[string]string{"Authorization": "Bearer {{env.token}}"}},
+	}
+	for _, tt := range tests {
+		if got := redactMetadata(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestHistoryEntryKeepsPlaceholders(t *testing.T) {
+	environments.set("history-test", map[string]string{"token": "secret", "tenant": "t1"})
+	defer environments.set("history-test", nil)
+
+	tests := []struct {
+		name     string
+		request  interface{}
+		expand   bool
+		stored   string
+		replayed string
+	}{
+		{name: "expanded", request: `{"tenant": "{{env.tenant}}", "n": {{random.int 7 7}}}`, expand: true,
+			stored: `"{\"tenant\": \"{{env.tenant}}\", \"n\": {{random.int 7 7}}}"`, replayed: `{"n":7,"tenant":"t1"}`},
+		{name: "json string", request: `{"tenant": "{{env.tenant}}"}`, expand: true,
+			stored: `{"tenant": "{{env.tenant}}"}`, replayed: `{"tenant":"t1"}`},
+		{name: "object", request: map[string]interface{}{"tenant": "{{env.tenant}}"}, expand: true,
+			stored: `{"tenant":"{{env.tenant}}"}`, replayed: `{"tenant":"t1"}`},
+		// 没有开启 expand 时原样发送，重放时也一样
+		{name: "not expanded", request: `{"tenant": "{{env.tenant}}"}`,
+			stored: `{"tenant": "{{env.tenant}}"}`, replayed: `{"tenant":"{{env.tenant}}"}`},
+	}
+	for _, tt := range tests {
+		req := &rpcRequest{
+			Service:  "go.micro.srv.users",
+			Endpoint: "Users.Get",
+			Request:  tt.request,
+			Metadata: map[string]string{"Authorization": "Bearer {{env.token}}"},
+			Env:      "history-test",
+			Expand:   tt.expand,
+		}
+		x, _ := newExpander(req.Env)
+		x.keep = !req.Expand
+		c, err := req.newCall(x)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		e := newHistoryEntry(c, &rpcResult{response: []byte(`{}`)})
+		if string(e.Request) != tt.stored {
+			t.Errorf("%s: expected stored request %s, got %s", tt.name, tt.stored, e.Request)
+		}
+		if md := e.Metadata["Authorization"]; md != "Bearer {{env.token}}" {
+			t.Errorf("%s: expected the metadata placeholder to be stored, got %q", tt.name, md)
+		}
+
+		replay, err := e.call()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := compactJSON(t, replay.request); got != tt.replayed {
+			t.Errorf("%s: expected replayed request %s, got %s", tt.name, tt.replayed, got)
+		}
+		want := "Bearer {{env.token}}"
+		if tt.expand {
+			want = "Bearer secret"
+		}
+		if md := replay.metadata["Authorization"]; md != want {
+			t.Errorf("%s: expected replayed metadata %q, got %q", tt.name, want, md)
+		}
+	}
+}
+
+func TestHistoryStore(t *testing.T) {
+	file, closeHistory := openTestHistory(t, 3)
+	defer closeHistory()
+	for i, endpoint := range []string{"Users.Get", "Users.Update", "Users.Get", "Users.Get"} {
+		e := &historyEntry{Time: time.Now(), Service: "go.micro.srv.users", Endpoint: endpoint, Status: "ok", Code: http.StatusOK}
+		if i == 2 {
+			e.Status, e.Code = "error", http.StatusNotFound
+		}
+		id, err := history.add(e)
+		if err != nil || id != uint64(i+1) {
+			t.Fatalf("expected id %d, got %d, %v", i+1, id, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter historyFilter
+		want   []uint64
+	}{
+		// 只保留最近 3 条
+		{name: "all", want: []uint64{4, 3, 2}},
+		{name: "endpoint", filter: historyFilter{endpoint: "Update"}, want: []uint64{2}},
+		{name: "error", filter: historyFilter{status: "error"}, want: []uint64{3}},
+		{name: "code", filter: historyFilter{status: "200"}, want: []uint64{4, 2}},
+		{name: "limit", filter: historyFilter{limit: 1}, want: []uint64{4}},
+	}
+	for _, tt := range tests {
+		entries, err := history.list(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []uint64
+		for _, e := range entries {
+			ids = append(ids, e.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, ids)
+		}
+	}
+	if e, _ := history.get(1); e != nil {
+		t.Errorf("expected entry 1 to be trimmed, got %+v", e)
+	}
+	if e, _ := history.get(3); e == nil || e.Code != http.StatusNotFound {
+		t.Errorf("expected entry 3 with code 404, got %+v", e)
+	}
+
+	// 重新打开后 ID 接着递增
+	history.close()
+	if err := openHistory(file, 3); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := history.add(&historyEntry{Service: "go.micro.srv.users"}); id != 5 {
+		t.Fatalf("expected id 5 after reopening, got %d", id)
+	}
+}
+
+func TestReplayRequiresPost(t *testing.T) {
+	w := httptest.NewRecorder()
+	replayHandler(w, httptest.NewRequest("GET", "/history/replay?id=1", nil))
+	if w.Code != http.StatusMethodNotAllowed || !strings.Contains(w.Body.String(), "requires POST") {
+		t.Fatalf("expected 405, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,422 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 导入的格式
+const (
+	importNative  = "native"
+	importPostman = "postman"
+	importGRPCurl = "grpcurl"
+)
+
+// micro api 按路径找服务时用的命名空间，如 /greeter/say/hello 对应 go.micro.api.greeter 的 Say.Hello
+var apiNamespace = "go.micro.api"
+
+// importCollections 解析要导入的内容，format 为空时按内容判断
+// collection 是 grpcurl 命令导入到的集合名，Postman 集合使用自己的名字
+func importCollections(format, collection string, b []byte) ([]*Collection, []string, error) {
+	b = bytes.TrimSpace(b)
+	if len(format) == 0 {
+		format = detectImportFormat(b)
+	}
+	switch format {
+	case importNative:
+		export, err := parseCollectionExport(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		return export.Collections, nil, nil
+	case importPostman:
+		return importPostmanCollection(b)
+	case importGRPCurl:
+		if len(collection) == 0 {
+			collection = importGRPCurl
+		}
+		c, skipped := importGRPCurlCommands(collection, string(b))
+		return []*Collection{c}, skipped, nil
+	}
+	return nil, nil, fmt.Errorf("unknown import format %q", format)
+}
+
+func detectImportFormat(b []byte) string {
+	if len(b) == 0 || b[0] != '{' {
+		return importGRPCurl
+	}
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(b, &probe); err != nil {
+		return importNative
+	}
+	if _, ok := probe["info"]; ok {
+		return importPostman
+	}
+	return importNative
+}
+
+// postmanCollection 是 Postman v2.1 集合中用到的部分
+type postmanCollection struct {
+	Info struct {
+		Name   string `json:"name"`
+		Schema string `json:"schema"`
+	} `json:"info"`
+	Item []*postmanItem `json:"item"`
+}
+
+type postmanItem struct {
+	Name string `json:"name"`
+	// 有 Item 时是文件夹
+	Item    []*postmanItem  `json:"item"`
+	Request *postmanRequest `json:"request"`
+}
+
+type postmanRequest struct {
+	Method string `json:"method"`
+	Header []struct {
+		Key      string `json:"key"`
+		Value    string `json:"value"`
+		Disabled bool   `json:"disabled"`
+	} `json:"header"`
+	Body *struct {
+		Mode string `json:"mode"`
+		Raw  string `json:"raw"`
+	} `json:"body"`
+	// 字符串或 {"raw": "..."}
+	URL json.RawMessage `json:"url"`
+}
+
+// importPostmanCollection 导入 Postman v2.1 集合，文件夹展开成 "文件夹 / 名字"
+// 请求体是 /rpc 格式的按其中的 service 和 endpoint 调用，否则按 micro api 的路径规则找服务
+func importPostmanCollection(b []byte) ([]*Collection, []string, error) {
+	pc := new(postmanCollection)
+	if err := json.Unmarshal(b, pc); err != nil {
+		return nil, nil, err
+	}
+	if len(pc.Info.Schema) > 0 && !strings.Contains(pc.Info.Schema, "v2.1") {
+		return nil, nil, fmt.Errorf("unsupported postman schema %s, export the collection as v2.1", pc.Info.Schema)
+	}
+	name := pc.Info.Name
+	if len(name) == 0 {
+		name = importPostman
+	}
+	c := &Collection{Name: name}
+	var skipped []string
+
+	var walk func(prefix string, items []*postmanItem)
+	walk = func(prefix string, items []*postmanItem) {
+		for _, item := range items {
+			itemName := item.Name
+			if len(prefix) > 0 {
+				itemName = prefix + " / " + item.Name
+			}
+			if len(item.Item) > 0 {
+				walk(itemName, item.Item)
+				continue
+			}
+			if item.Request == nil {
+				continue
+			}
+			req, err := postmanSavedRequest(item.Request)
+			if err != nil {
+				skipped = append(skipped, itemName+": "+err.Error())
+				continue
+			}
+			req.Name = itemName
+			c.Requests = append(c.Requests, req)
+		}
+	}
+	walk("", pc.Item)
+	return []*Collection{c}, skipped, nil
+}
+
+func postmanSavedRequest(pr *postmanRequest) (*SavedRequest, error) {
+	req := &SavedRequest{Metadata: make(map[string]string)}
+	for _, h := range pr.Header {
+		if h.Disabled || strings.EqualFold(h.Key, "Content-Type") {
+			continue
+		}
+		req.Metadata[h.Key] = h.Value
+	}
+
+	body := "{}"
+	if pr.Body != nil && len(strings.TrimSpace(pr.Body.Raw)) > 0 {
+		if pr.Body.Mode != "raw" {
+			return nil, fmt.Errorf("unsupported body mode %s", pr.Body.Mode)
+		}
+		body = pr.Body.Raw
+	}
+	if !json.Valid([]byte(body)) {
+		return nil, fmt.Errorf("request body is not valid JSON")
+	}
+
+	// 请求体是 {"service": ..., "endpoint": ..., "request": ...}
+	var rpcReq rpcRequest
+	if err := json.Unmarshal([]byte(body), &rpcReq); err == nil && len(rpcReq.Service) > 0 {
+		req.Service = rpcReq.Service
+		req.Endpoint = rpcReq.Endpoint
+		if len(req.Endpoint) == 0 {
+			req.Endpoint = rpcReq.Method
+		}
+		req.Address = rpcReq.Address
+		req.Transport = rpcReq.Transport
+		for k, v := range rpcReq.Metadata {
+			req.Metadata[k] = v
+		}
+		switch v := rpcReq.Request.(type) {
+		case string:
+			if !json.Valid([]byte(v)) {
+				return nil, fmt.Errorf("request is not valid JSON")
+			}
+			req.Request = json.RawMessage(v)
+		case nil:
+			req.Request = json.RawMessage("{}")
+		default:
+			req.Request, _ = json.Marshal(v)
+		}
+		return req, nil
+	}
+
+	path, err := postmanPath(pr.URL)
+	if err != nil {
+		return nil, err
+	}
+	service, endpoint, ok := apiRoute(path)
+	if !ok {
+		return nil, fmt.Errorf("cannot map %s to a service endpoint", path)
+	}
+	req.Service = service
+	req.Endpoint = endpoint
+	req.Request = json.RawMessage(body)
+	return req, nil
+}
+
+func postmanPath(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		var u struct {
+			Raw  string   `json:"raw"`
+			Path []string `json:"path"`
+		}
+		if err := json.Unmarshal(raw, &u); err != nil {
+			return "", fmt.Errorf("invalid url")
+		}
+		if len(u.Path) > 0 {
+			return "/" + strings.Join(u.Path, "/"), nil
+		}
+		s = u.Raw
+	}
+	// Postman 的变量如 {{host}} 不是合法的 URL，只取路径部分
+	if i := strings.Index(s, "://"); i >= 0 {
+		s = s[i+3:]
+	}
+	if i := strings.Index(s, "/"); i >= 0 {
+		s = s[i:]
+	} else {
+		s = "/"
+	}
+	if i := strings.IndexAny(s, "?#"); i >= 0 {
+		s = s[:i]
+	}
+	if p, err := url.PathUnescape(s); err == nil {
+		s = p
+	}
+	return s, nil
+}
+
+// apiRoute 按 micro api 的 rpc 路径规则把路径对应到服务和 endpoint
+// /greeter/say/hello => go.micro.api.greeter Say.Hello
+// /greeter/hello => go.micro.api.greeter Greeter.Hello
+func apiRoute(path string) (service, endpoint string, ok bool) {
+	var parts []string
+	for _, p := range strings.Split(path, "/") {
+		if len(p) > 0 {
+			parts = append(parts, p)
+		}
+	}
+	switch {
+	case len(parts) == 2:
+		return apiNamespace + "." + parts[0], strings.Title(parts[0]) + "." + strings.Title(parts[1]), true
+	case len(parts) >= 3:
+		n := len(parts)
+		return apiNamespace + "." + strings.Join(parts[:n-2], "."), strings.Title(parts[n-2]) + "." + strings.Title(parts[n-1]), true
+	}
+	return "", "", false
+}
+
+// grpcurl 中带参数的选项，其余的选项都是开关
+var grpcurlArgFlags = map[string]bool{
+	"d": true, "data": true, "H": true, "rpc-header": true, "reflect-header": true,
+	"max-time": true, "connect-timeout": true, "keepalive-time": true, "import-path": true,
+	"proto": true, "protoset": true, "authority": true, "cert": true, "key": true,
+	"cacert": true, "servername": true, "user-agent": true, "format": true,
+	"max-msg-sz": true, "protoset-out": true,
+}
+
+// importGRPCurlCommands 导入一行一条的 grpcurl 命令，行尾的 \ 表示命令没有结束
+func importGRPCurlCommands(collection, text string) (*Collection, []string) {
+	c := &Collection{Name: collection}
+	var skipped []string
+	text = strings.Replace(text, "\\\r\n", " ", -1)
+	text = strings.Replace(text, "\\\n", " ", -1)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		req, err := parseGRPCurl(line)
+		if err != nil {
+			skipped = append(skipped, line+": "+err.Error())
+			continue
+		}
+		req.Name = req.Endpoint
+		// 同一个方法导入多次时加上序号
+		for i := 2; collectionHas(c, req.Name); i++ {
+			req.Name = req.Endpoint + " #" + strconv.Itoa(i)
+		}
+		c.Requests = append(c.Requests, req)
+	}
+	return c, skipped
+}
+
+func collectionHas(c *Collection, name string) bool {
+	for _, req := range c.Requests {
+		if req.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseGRPCurl 解析一条 grpcurl 命令，如
+// grpcurl -plaintext -H 'tenant: a' -d '{"name": "john"}' localhost:9090 go.micro.srv.greeter.Say/Hello
+func parseGRPCurl(line string) (*SavedRequest, error) {
+	args, err := splitShell(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 || !strings.HasSuffix(args[0], "grpcurl") {
+		return nil, fmt.Errorf("not a grpcurl command")
+	}
+
+	req := &SavedRequest{
+		Transport: transportGRPC,
+		Metadata:  make(map[string]string),
+		Request:   json.RawMessage("{}"),
+	}
+	var positional []string
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			positional = append(positional, arg)
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		value, hasValue := "", false
+		if j := strings.Index(name, "="); j >= 0 {
+			name, value, hasValue = name[:j], name[j+1:], true
+		}
+		if !hasValue && grpcurlArgFlags[name] {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("missing value for -%s", name)
+			}
+			i++
+			value = args[i]
+		}
+		switch name {
+		case "d", "data":
+			if value == "@" {
+				return nil, fmt.Errorf("request data from stdin is not supported")
+			}
+			req.Request = json.RawMessage(value)
+		case "H", "rpc-header":
+			j := strings.Index(value, ":")
+			if j <= 0 {
+				return nil, fmt.Errorf("invalid header %q", value)
+			}
+			req.Metadata[strings.TrimSpace(value[:j])] = strings.TrimSpace(value[j+1:])
+		case "max-time":
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				req.Timeout = time.Duration(f * float64(time.Second)).String()
+			}
+		}
+	}
+
+	switch len(positional) {
+	case 2:
+		req.Address = positional[0]
+		req.Endpoint = positional[1]
+	default:
+		return nil, fmt.Errorf("expected address and method, e.g. localhost:9090 pkg.Service/Method")
+	}
+	if !json.Valid(req.Request) {
+		return nil, fmt.Errorf("request data is not valid JSON")
+	}
+	req.Service, _ = grpcMethodName(req.Endpoint)
+	if len(req.Metadata) == 0 {
+		req.Metadata = nil
+	}
+	return req, nil
+}
+
+// splitShell 按 shell 的规则拆分参数，支持单引号、双引号和反斜杠转义
+func splitShell(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			// 双引号中的反斜杠只转义 $ ` " \
+			if quote == '"' && !strings.ContainsRune("$`\"\\", r) {
+				cur.WriteRune('\\')
+			}
+			cur.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				cur.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+			inArg = true
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
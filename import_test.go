@@ -0,0 +1,161 @@
+package web
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSplitShell(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+		err  bool
+	}{
+		{line: `grpcurl -plaintext localhost:9090 list`, want: `["grpcurl","-plaintext","localhost:9090","list"]`},
+		{line: `grpcurl  -d   '{"name": "john"}'`, want: `["grpcurl","-d","{\"name\": \"john\"}"]`},
+		{line: `grpcurl -H "tenant: a" -H 'x: "b"'`, want: `["grpcurl","-H","tenant: a","-H","x: \"b\""]`},
+		// 单引号中的反斜杠原样保留，双引号中只转义 $ ` " \
+		{line: `a 'b\n' "c\n\"\$" d\ e`, want: `["a","b\\n","c\\n\"$","d e"]`},
+		{line: `a '' ""`, want: `["a","",""]`},
+		{line: `a 'b`, err: true},
+		{line: `a "b`, err: true},
+	}
+	for _, tt := range tests {
+		args, err := splitShell(tt.line)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %q", tt.line, args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.line, err)
+			continue
+		}
+		if got := compactJSON(t, args); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.line, tt.want, got)
+		}
+	}
+}
+
+func TestParseGRPCurl(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+		err  string
+	}{
+		{
+			line: `grpcurl -plaintext -H 'tenant: a' -d '{"name": "john"}' localhost:9090 go.micro.srv.greeter.Say/Hello`,
+			want: `{"name":"","service":"go.micro.srv.greeter.Say","endpoint":"go.micro.srv.greeter.Say/Hello","transport":"grpc","address":"localhost:9090","metadata":{"tenant":"a"},"request":{"name":"john"}}`,
+		},
+		{
+			line: `/usr/local/bin/grpcurl --data={} -rpc-header=x-id:1 -max-time 1.5 10.0.0.1:9090 users.Users.Get`,
+			want: `{"name":"","service":"users.Users","endpoint":"users.Users.Get","transport":"grpc","address":"10.0.0.1:9090","metadata":{"x-id":"1"},"request":{},"timeout":"1.5s"}`,
+		},
+		{line: `curl localhost:9090`, err: "not a grpcurl command"},
+		{line: `grpcurl -plaintext localhost:9090`, err: "expected address and method"},
+		{line: `grpcurl -d @ localhost:9090 a.B/C`, err: "stdin is not supported"},
+		{line: `grpcurl -H tenant localhost:9090 a.B/C`, err: "invalid header"},
+		{line: `grpcurl -d '{"name":' localhost:9090 a.B/C`, err: "not valid JSON"},
+		{line: `grpcurl localhost:9090 a.B/C -d`, err: "missing value for -d"},
+	}
+	for _, tt := range tests {
+		req, err := parseGRPCurl(tt.line)
+		if len(tt.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: expected error %q, got %v", tt.line, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.line, err)
+			continue
+		}
+		if got := compactJSON(t, req); got != tt.want {
+			t.Errorf("%s: expected\n%s\ngot\n%s", tt.line, tt.want, got)
+		}
+	}
+}
+
+func TestImportGRPCurlCommands(t *testing.T) {
+	text := "# users\n" +
+		"grpcurl -plaintext \\\n  -d '{\"id\": \"u-1\"}' localhost:9090 users.Users/Get\n" +
+		"grpcurl -d '{\"id\": \"u-2\"}' localhost:9090 users.Users/Get\n" +
+		"grpcurl localhost:9090\n"
+	c, skipped := importGRPCurlCommands("imported", text)
+	var names []string
+	for _, req := range c.Requests {
+		names = append(names, req.Name+" "+string(req.Request))
+	}
+	if got := strings.Join(names, ", "); got != `users.Users/Get {"id": "u-1"}, users.Users/Get #2 {"id": "u-2"}` {
+		t.Errorf("unexpected requests %s", got)
+	}
+	if len(skipped) != 1 || !strings.HasPrefix(skipped[0], "grpcurl localhost:9090: ") {
+		t.Errorf("expected the incomplete command to be skipped, got %q", skipped)
+	}
+}
+
+func TestAPIRoute(t *testing.T) {
+	tests := []struct {
+		path     string
+		service  string
+		endpoint string
+	}{
+		{path: "/greeter/say/hello", service: "go.micro.api.greeter", endpoint: "Say.Hello"},
+		{path: "/greeter/hello", service: "go.micro.api.greeter", endpoint: "Greeter.Hello"},
+		{path: "/v1/greeter/say/hello/", service: "go.micro.api.v1.greeter", endpoint: "Say.Hello"},
+		{path: "/greeter"},
+		{path: "/"},
+	}
+	for _, tt := range tests {
+		service, endpoint, ok := apiRoute(tt.path)
+		if ok != (len(tt.service) > 0) || service != tt.service || endpoint != tt.endpoint {
+			t.Errorf("apiRoute(%q) = %q, %q, %v, expected %q, %q", tt.path, service, endpoint, ok, tt.service, tt.endpoint)
+		}
+	}
+}
+
+func TestImportPostmanCollection(t *testing.T) {
+	b := []byte(`{
+		"info": {"name": "users", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+		"item": [
+			{"name": "rpc", "item": [
+				{"name": "get", "request": {
+					"method": "POST",
+					"header": [{"key": "Content-Type", "value": "application/json"}, {"key": "tenant", "value": "a"}, {"key": "debug", "value": "1", "disabled": true}],
+					"body": {"mode": "raw", "raw": "{\"service\": \"go.micro.srv.users\", \"method\": \"Users.Get\", \"request\": \"{\\\"id\\\": \\\"u-1\\\"}\", \"metadata\": {\"x-id\": \"1\"}}"},
+					"url": "{{host}}/rpc"
+				}}
+			]},
+			{"name": "api", "request": {
+				"method": "POST",
+				"body": {"mode": "raw", "raw": "{\"name\": \"john\"}"},
+				"url": {"raw": "{{host}}/greeter/say/hello?debug=1", "path": ["greeter", "say", "hello"]}
+			}},
+			{"name": "form", "request": {"method": "POST", "body": {"mode": "formdata", "raw": "a=1"}, "url": "{{host}}/greeter/say/hello"}},
+			{"name": "root", "request": {"method": "GET", "url": "{{host}}/"}}
+		]
+	}`)
+	if format := detectImportFormat(b); format != importPostman {
+		t.Fatalf("expected the postman format, got %s", format)
+	}
+	collections, skipped, err := importPostmanCollection(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"name":"users","requests":[` +
+		`{"name":"rpc / get","service":"go.micro.srv.users","endpoint":"Users.Get","metadata":{"tenant":"a","x-id":"1"},"request":{"id":"u-1"}},` +
+		`{"name":"api","service":"go.micro.api.greeter","endpoint":"Say.Hello","request":{"name":"john"}}]}]`
+	got, _ := json.Marshal(collections)
+	if string(got) != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+	if got := strings.Join(skipped, "; "); got != "form: unsupported body mode formdata; root: cannot map / to a service endpoint" {
+		t.Errorf("unexpected skipped requests %s", got)
+	}
+
+	if _, _, err := importPostmanCollection([]byte(`{"info": {"name": "old", "schema": "https://schema.getpostman.com/json/collection/v2.0.0/collection.json"}}`)); err == nil {
+		t.Error("expected postman v2.0 collections to be refused")
+	}
+}
@@ -0,0 +1,442 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/micro/go-micro/errors"
+)
+
+// 没有指定时长和请求数时压测的时长
+var loadDefaultDuration = 10 * time.Second
+
+// 页面和 API 发起的压测在 dashboard 进程中运行，限制并发数和时长，按请求数压测时也不超过最长时长
+// 命令行中的压测不受限制
+var (
+	loadMaxConcurrency = 100
+	loadMaxDuration    = 5 * time.Minute
+)
+
+// 计算延迟分位数时最多保留的样本数，超过后按蓄水池抽样替换，min、max 和 mean 仍按所有请求计算
+const loadSamples = 10000
+
+// loadSpec 是压测的参数，请求的写法和 /rpc 相同，每次请求都重新替换占位符
+type loadSpec struct {
+	rpcRequest
+	// 同时发出请求的 goroutine 数
+	Concurrency int `json:"concurrency"`
+	// 每秒最多发出的请求数，为 0 时不限制
+	QPS float64 `json:"qps"`
+	// 压测的时长，秒数或 "30s" 这样的写法
+	Duration interface{} `json:"duration"`
+	// 总请求数，为 0 时按时长
+	Requests int `json:"requests"`
+}
+
+// loadLatency 是延迟的分布，单位毫秒
+type loadLatency struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// loadError 是同一种错误的次数
+type loadError struct {
+	Code   int32  `json:"code"`
+	Detail string `json:"detail"`
+	Count  int    `json:"count"`
+}
+
+// loadReport 是压测的结果，压测中也按同样的格式报告进度
+type loadReport struct {
+	Service     string  `json:"service"`
+	Endpoint    string  `json:"endpoint"`
+	Transport   string  `json:"transport,omitempty"`
+	Env         string  `json:"env,omitempty"`
+	Concurrency int     `json:"concurrency"`
+	QPS         float64 `json:"qps,omitempty"`
+	DurationMs  float64 `json:"duration_ms,omitempty"`
+	Requests    int     `json:"requests,omitempty"`
+
+	Start      time.Time    `json:"start"`
+	ElapsedMs  float64      `json:"elapsed_ms"`
+	Done       bool         `json:"done"`
+	Sent       int          `json:"sent"`
+	Failed     int          `json:"failed"`
+	Throughput float64      `json:"throughput"`
+	Latency    loadLatency  `json:"latency_ms"`
+	Errors     []*loadError `json:"errors"`
+}
+
+// loadTest 是一次压测
+type loadTest struct {
+	spec     *loadSpec
+	duration time.Duration
+	// 按请求数压测时最长的运行时间，为 0 时不限制
+	limit time.Duration
+	start time.Time
+
+	sync.Mutex
+	// 延迟的样本，最多 loadSamples 个
+	samples []time.Duration
+	rnd     *rand.Rand
+	sent    int
+	total   time.Duration
+	min     time.Duration
+	max     time.Duration
+	failed  int
+	errors  map[string]*loadError
+}
+
+// newLoadTest 检查参数，先构造一次调用，请求有错时不开始压测
+func newLoadTest(spec *loadSpec) (*loadTest, error) {
+	if spec.Concurrency <= 0 {
+		spec.Concurrency = 1
+	}
+	if spec.QPS < 0 || spec.Requests < 0 {
+		return nil, fmt.Errorf("qps and requests must not be negative")
+	}
+	duration, err := parseTimeout(spec.Duration)
+	if err != nil {
+		return nil, fmt.Errorf("invalid duration %v", spec.Duration)
+	}
+	if duration == 0 && spec.Requests == 0 {
+		duration = loadDefaultDuration
+	}
+	x, err := newExpander(spec.Env)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := spec.newCall(x); err != nil {
+		return nil, err
+	}
+	return &loadTest{
+		spec:     spec,
+		duration: duration,
+		rnd:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		errors:   make(map[string]*loadError),
+	}, nil
+}
+
+// limited 检查页面和 API 发起的压测是否超过 loadMaxConcurrency 和 loadMaxDuration
+func (t *loadTest) limited() error {
+	if t.spec.Concurrency > loadMaxConcurrency {
+		return fmt.Errorf("concurrency %d exceeds the limit of %d", t.spec.Concurrency, loadMaxConcurrency)
+	}
+	if t.duration > loadMaxDuration {
+		return fmt.Errorf("duration %v exceeds the limit of %v", t.duration, loadMaxDuration)
+	}
+	t.limit = loadMaxDuration
+	return nil
+}
+
+// run 发出请求直到时长用完、请求数发完或者 ctx 被取消，每秒调用一次 progress 报告进度
+// r 为 nil 时（命令行中运行）不带 HTTP header
+func (t *loadTest) run(ctx context.Context, r *http.Request, progress func(*loadReport)) *loadReport {
+	t.start = time.Now()
+	timeout := t.duration
+	if timeout == 0 {
+		timeout = t.limit
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// 限制 QPS 时每个请求先拿到一个 tick
+	var ticks <-chan time.Time
+	if t.spec.QPS > 0 {
+		ticker := time.NewTicker(time.Duration(math.Max(1, float64(time.Second)/t.spec.QPS)))
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	var sent int64
+	var wg sync.WaitGroup
+	for i := 0; i < t.spec.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if ticks != nil {
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticks:
+					}
+				} else if ctx.Err() != nil {
+					return
+				}
+				if t.spec.Requests > 0 && atomic.AddInt64(&sent, 1) > int64(t.spec.Requests) {
+					return
+				}
+				t.once(ctx, r)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	if progress != nil {
+		go func() {
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					progress(t.report(false))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+	return t.report(true)
+}
+
+// once 发出一次请求，每次都重新替换占位符，如 {{uuid}}
+// ctx 是整个压测的 context，结束或取消时正在进行的请求也结束，不计入结果
+func (t *loadTest) once(ctx context.Context, r *http.Request) {
+	res := &rpcResult{}
+	x, err := newExpander(t.spec.Env)
+	if err == nil {
+		var c *rpcCall
+		if c, err = t.spec.newCall(x); err == nil {
+			res = c.do(ctx, r)
+		}
+	}
+	if err != nil {
+		res.err = badRequestError(err.Error())
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	t.record(res.latency)
+	if res.err == nil {
+		return
+	}
+	t.failed++
+	key := fmt.Sprintf("%d %s", res.err.Code, res.err.Detail)
+	if e, ok := t.errors[key]; ok {
+		e.Count++
+		return
+	}
+	t.errors[key] = &loadError{Code: res.err.Code, Detail: res.err.Detail, Count: 1}
+}
+
+// record 记下一次请求的延迟，调用时需持有锁
+func (t *loadTest) record(l time.Duration) {
+	t.sent++
+	t.total += l
+	if t.sent == 1 || l < t.min {
+		t.min = l
+	}
+	if l > t.max {
+		t.max = l
+	}
+	if len(t.samples) < loadSamples {
+		t.samples = append(t.samples, l)
+		return
+	}
+	if i := t.rnd.Intn(t.sent); i < loadSamples {
+		t.samples[i] = l
+	}
+}
+
+// report 统计到目前为止的结果
+func (t *loadTest) report(done bool) *loadReport {
+	t.Lock()
+	latencies := make([]time.Duration, len(t.samples))
+	copy(latencies, t.samples)
+	errs := make([]*loadError, 0, len(t.errors))
+	for _, e := range t.errors {
+		c := *e
+		errs = append(errs, &c)
+	}
+	sent, total, min, max := t.sent, t.total, t.min, t.max
+	failed := t.failed
+	t.Unlock()
+
+	elapsed := time.Since(t.start)
+	rep := &loadReport{
+		Service:     t.spec.Service,
+		Endpoint:    t.spec.Endpoint,
+		Transport:   t.spec.Transport,
+		Env:         t.spec.Env,
+		Concurrency: t.spec.Concurrency,
+		QPS:         t.spec.QPS,
+		DurationMs:  durationMs(t.duration),
+		Requests:    t.spec.Requests,
+		Start:       t.start,
+		ElapsedMs:   durationMs(elapsed),
+		Done:        done,
+		Sent:        sent,
+		Failed:      failed,
+		Errors:      errs,
+	}
+	if elapsed > 0 {
+		rep.Throughput = float64(sent) / elapsed.Seconds()
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Count > errs[j].Count
+	})
+
+	if len(latencies) == 0 {
+		return rep
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	percentile := func(p float64) float64 {
+		i := int(math.Ceil(p/100*float64(len(latencies)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return durationMs(latencies[i])
+	}
+	rep.Latency = loadLatency{
+		Min:  durationMs(min),
+		Mean: durationMs(total / time.Duration(sent)),
+		P50:  percentile(50),
+		P95:  percentile(95),
+		P99:  percentile(99),
+		Max:  durationMs(max),
+	}
+	return rep
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// print 以文本格式输出结果
+func (rep *loadReport) print(w io.Writer) {
+	fmt.Fprintf(w, "%s %s: %d requests, %d failed in %.1fs, %.1f req/s\n",
+		rep.Service, rep.Endpoint, rep.Sent, rep.Failed, rep.ElapsedMs/1000, rep.Throughput)
+	l := rep.Latency
+	fmt.Fprintf(w, "latency ms: min %.1f, mean %.1f, p50 %.1f, p95 %.1f, p99 %.1f, max %.1f\n",
+		l.Min, l.Mean, l.P50, l.P95, l.P99, l.Max)
+	for _, e := range rep.Errors {
+		fmt.Fprintf(w, "  %6d  %d %s\n", e.Count, e.Code, e.Detail)
+	}
+}
+
+// runLoadTest 在命令行中压测，进度写到 w，out 不为空时把结果以 JSON 格式写入文件
+func runLoadTest(spec *loadSpec, out string, w io.Writer) error {
+	t, err := newLoadTest(spec)
+	if err != nil {
+		return err
+	}
+	rep := t.run(context.Background(), nil, func(rep *loadReport) {
+		fmt.Fprintf(w, "%5.0fs  %d sent, %d failed, %.1f req/s, p50 %.1fms, p99 %.1fms\n",
+			rep.ElapsedMs/1000, rep.Sent, rep.Failed, rep.Throughput, rep.Latency.P50, rep.Latency.P99)
+	})
+	rep.print(w)
+	if len(out) == 0 {
+		return nil
+	}
+	b, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(out, b, 0644)
+}
+
+// loadHandler 显示压测页面，POST 压测后返回结果
+// 通过 WebSocket 连接时页面先发压测参数，每秒推送一次 {"type": "progress"}，结束时推送 {"type": "report"}
+// 页面发 {"stop": true} 或断开连接时提前结束
+func loadHandler(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		loadStream(w, r)
+		return
+	}
+	if r.Method != "POST" {
+		render(w, r, loadTemplate, nil)
+		return
+	}
+
+	spec := new(loadSpec)
+	d := json.NewDecoder(r.Body)
+	d.UseNumber()
+	if err := d.Decode(spec); err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	t, err := newLoadTest(spec)
+	if err == nil {
+		err = t.limited()
+	}
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	b, err := json.Marshal(t.run(r.Context(), r, nil))
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+func loadStream(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	out := &streamWriter{conn: conn}
+
+	_, b, err := conn.ReadMessage()
+	if err != nil {
+		return
+	}
+	spec := new(loadSpec)
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(spec); err != nil {
+		out.write("error", errors.BadRequest("go.micro.rpc", "invalid load test: %v", err))
+		return
+	}
+	t, err := newLoadTest(spec)
+	if err == nil {
+		err = t.limited()
+	}
+	if err != nil {
+		out.write("error", errors.BadRequest("go.micro.rpc", "%v", err))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// 收到任何消息或连接断开时停止
+	go func() {
+		conn.ReadMessage()
+		cancel()
+	}()
+
+	rep := t.run(ctx, r, func(rep *loadReport) {
+		out.write("progress", rep)
+	})
+	out.write("report", rep)
+}
@@ -0,0 +1,108 @@
+package web
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func newTestLoad() *loadTest {
+	return &loadTest{
+		spec:   &loadSpec{},
+		start:  time.Now(),
+		rnd:    rand.New(rand.NewSource(1)),
+		errors: make(map[string]*loadError),
+	}
+}
+
+func TestLoadPercentiles(t *testing.T) {
+	ms := func(list ...int) []time.Duration {
+		var d []time.Duration
+		for _, n := range list {
+			d = append(d, time.Duration(n)*time.Millisecond)
+		}
+		return d
+	}
+	hundred := make([]int, 100)
+	for i := range hundred {
+		hundred[i] = 100 - i
+	}
+	tests := []struct {
+		name      string
+		latencies []time.Duration
+		want      loadLatency
+	}{
+		{name: "none"},
+		{name: "one", latencies: ms(7), want: loadLatency{Min: 7, Mean: 7, P50: 7, P95: 7, P99: 7, Max: 7}},
+		{name: "two", latencies: ms(10, 2), want: loadLatency{Min: 2, Mean: 6, P50: 2, P95: 10, P99: 10, Max: 10}},
+		// 按排好序的第 ceil(p*n) 个取值
+		{name: "hundred", latencies: ms(hundred...), want: loadLatency{Min: 1, Mean: 50.5, P50: 50, P95: 95, P99: 99, Max: 100}},
+	}
+	for _, tt := range tests {
+		l := newTestLoad()
+		for _, d := range tt.latencies {
+			l.record(d)
+		}
+		rep := l.report(true)
+		if rep.Sent != len(tt.latencies) || rep.Latency != tt.want {
+			t.Errorf("%s: expected %d sent and %+v, got %d and %+v", tt.name, len(tt.latencies), tt.want, rep.Sent, rep.Latency)
+		}
+	}
+}
+
+// 样本数不超过 loadSamples，替换后仍然是所有请求的均匀抽样，min、max 和 mean 按所有请求计算
+func TestLoadReservoir(t *testing.T) {
+	l := newTestLoad()
+	for i := 0; i < loadSamples; i++ {
+		l.record(time.Millisecond)
+	}
+	for i := 0; i < 3*loadSamples; i++ {
+		l.record(2 * time.Millisecond)
+	}
+	l.record(time.Second)
+
+	if len(l.samples) != loadSamples {
+		t.Fatalf("expected %d samples, got %d", loadSamples, len(l.samples))
+	}
+	var slow int
+	for _, d := range l.samples {
+		if d > time.Millisecond {
+			slow++
+		}
+	}
+	// 后来的请求占 3/4
+	if share := float64(slow) / loadSamples; share < 0.72 || share > 0.78 {
+		t.Errorf("expected about 75%% of the samples from later requests, got %.1f%%", share*100)
+	}
+	rep := l.report(true)
+	if rep.Sent != 4*loadSamples+1 || rep.Latency.Min != 1 || rep.Latency.Max != 1000 {
+		t.Errorf("expected %d sent, min 1 and max 1000, got %d, %v and %v", 4*loadSamples+1, rep.Sent, rep.Latency.Min, rep.Latency.Max)
+	}
+}
+
+// 压测结束时正在进行的请求随 ctx 一起结束，不计入结果
+func TestLoadCancel(t *testing.T) {
+	_, _, reset := useMemoryRegistry()
+	defer reset()
+	m := startTestMock(t, `{
+		"service": "go.micro.srv.slow",
+		"endpoints": [{"name": "Slow.Call", "rules": [{"delay": "2s", "response": {}}]}]
+	}`)
+	defer stopTestMock(m)
+
+	spec := &loadSpec{rpcRequest: rpcRequest{Service: "go.micro.srv.slow", Endpoint: "Slow.Call", Request: map[string]interface{}{}}, Concurrency: 2}
+	spec.Duration = "200ms"
+	l, err := newLoadTest(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	rep := l.run(context.Background(), nil, nil)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the run to stop after 200ms, took %v", elapsed)
+	}
+	if rep.Sent != 0 || rep.Failed != 0 {
+		t.Errorf("expected cancelled requests not to be counted, got %d sent and %d failed", rep.Sent, rep.Failed)
+	}
+}
@@ -0,0 +1,118 @@
+package web
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// headerSet 保存每个服务默认带上的 metadata，如 token、租户 ID
+// 指定了文件时保存到文件，重启后仍然有效
+type headerSet struct {
+	sync.RWMutex
+	file    string
+	headers map[string]map[string]string
+}
+
+var headers = &headerSet{headers: make(map[string]map[string]string)}
+
+// loadHeaderFile 加载保存的默认 metadata，文件不存在时在第一次保存时创建
+func loadHeaderFile(file string) error {
+	headers.Lock()
+	defer headers.Unlock()
+	headers.file = file
+	if len(file) == 0 {
+		return nil
+	}
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &headers.headers)
+}
+
+// get 返回服务的默认 metadata
+func (h *headerSet) get(service string) map[string]string {
+	h.RLock()
+	defer h.RUnlock()
+	md := make(map[string]string)
+	for k, v := range h.headers[service] {
+		md[k] = v
+	}
+	return md
+}
+
+// set 替换服务的默认 metadata，md 为空时删除
+func (h *headerSet) set(service string, md map[string]string) error {
+	h.Lock()
+	defer h.Unlock()
+	if len(md) == 0 {
+		delete(h.headers, service)
+	} else {
+		h.headers[service] = md
+	}
+	if len(h.file) == 0 {
+		return nil
+	}
+	b, err := json.MarshalIndent(h.headers, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(h.file, b, 0600)
+}
+
+// callMetadata 合并调用时带上的 metadata，后面的覆盖前面的：
+// HTTP header、服务的默认 metadata、页面上填写的 metadata
+func callMetadata(r *http.Request, service string, md map[string]string) map[string]string {
+	out := make(map[string]string)
+	for k, v := range requestMetadata(r) {
+		out[k] = v
+	}
+	for k, v := range headers.get(service) {
+		out[k] = v
+	}
+	for k, v := range md {
+		out[k] = v
+	}
+	return out
+}
+
+// headerHandler 读取或保存服务的默认 metadata
+// GET /headers?service=go.micro.srv.greeter
+// POST /headers?service=go.micro.srv.greeter {"Authorization": "Bearer xxx"}
+func headerHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "ParseForm err:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	svc := r.Form.Get("service")
+	if len(svc) == 0 {
+		http.Error(w, "Error occurred: service is required", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == "POST" {
+		md := make(map[string]string)
+		if err := json.NewDecoder(r.Body).Decode(&md); err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := headers.set(svc, md); err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	b, err := json.Marshal(headers.get(svc))
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 后面的覆盖前面的：HTTP header、服务的默认 metadata、页面上填写的 metadata
+func TestCallMetadata(t *testing.T) {
+	if err := headers.set("go.micro.srv.users", map[string]string{"Tenant": "default", "Token": "service"}); err != nil {
+		t.Fatal(err)
+	}
+	defer headers.set("go.micro.srv.users", nil)
+
+	r := httptest.NewRequest("POST", "/rpc", nil)
+	r.Header.Set("Tenant", "header")
+	r.Header.Set("Trace", "t-1")
+	r.Header.Add("Accept", "a")
+	r.Header.Add("Accept", "b")
+
+	tests := []struct {
+		name    string
+		service string
+		md      map[string]string
+		nilReq  bool
+		want    string
+	}{
+		{name: "service over header", service: "go.micro.srv.users", want: `{"Accept":"a,b","Tenant":"default","Token":"service","Trace":"t-1"}`},
+		{name: "call over service", service: "go.micro.srv.users", md: map[string]string{"Token": "call"}, want: `{"Accept":"a,b","Tenant":"default","Token":"call","Trace":"t-1"}`},
+		{name: "other service", service: "go.micro.srv.groups", md: map[string]string{"Tenant": "call"}, want: `{"Accept":"a,b","Tenant":"call","Trace":"t-1"}`},
+		{name: "command line", service: "go.micro.srv.users", nilReq: true, want: `{"Tenant":"default","Token":"service"}`},
+	}
+	for _, tt := range tests {
+		req := r
+		if tt.nilReq {
+			req = nil
+		}
+		if got := compactJSON(t, callMetadata(req, tt.service, tt.md)); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestHeaderFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "headers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer loadHeaderFile("")
+
+	file := filepath.Join(dir, "headers.json")
+	if err := loadHeaderFile(file); err != nil {
+		t.Fatalf("expected a missing file to be created on save, got %v", err)
+	}
+	if err := headers.set("go.micro.srv.users", map[string]string{"Tenant": "a"}); err != nil {
+		t.Fatal(err)
+	}
+	defer headers.set("go.micro.srv.users", nil)
+	fi, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v", fi.Mode().Perm())
+	}
+
+	headers.Lock()
+	headers.headers = make(map[string]map[string]string)
+	headers.Unlock()
+	if err := loadHeaderFile(file); err != nil {
+		t.Fatal(err)
+	}
+	if got := compactJSON(t, headers.get("go.micro.srv.users")); got != `{"Tenant":"a"}` {
+		t.Errorf("expected the saved metadata after reloading, got %s", got)
+	}
+}
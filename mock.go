@@ -0,0 +1,528 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/micro/go-micro/codec"
+	"github.com/micro/go-micro/errors"
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/go-micro/server"
+	grpcserver "github.com/micro/go-micro/server/grpc"
+	"github.com/micro/go-micro/util/log"
+)
+
+// 保存 mock 定义的文件
+var mockFile = "mocks.json"
+
+// 规则中 delay 的上限，避免写错单位的规则长时间占住 server 的 goroutine
+const mockMaxDelay = time.Minute
+
+// mock 是一个假的服务，由 dashboard 注册到 registry 中，和真的服务一样可以调用
+type mock struct {
+	Service   string          `json:"service"`
+	Version   string          `json:"version,omitempty"`
+	Endpoints []*mockEndpoint `json:"endpoints"`
+	// 服务已经有真的节点时默认拒绝注册，为 true 时和真的节点一起注册，请求会被分到 mock 上
+	Override bool `json:"override,omitempty"`
+}
+
+// mockEndpoint 按顺序找第一条匹配请求的规则作为响应
+type mockEndpoint struct {
+	Name string `json:"name"`
+	// 请求示例，用来在 registry 中登记请求的字段，Call 页面据此生成请求
+	Request json.RawMessage `json:"request,omitempty"`
+	Rules   []*mockRule     `json:"rules"`
+}
+
+// mockRule 是一条响应规则，when 中的条件都满足时使用，没有条件时总是匹配
+type mockRule struct {
+	When []*scenarioAssert `json:"when,omitempty"`
+	// 响应可以使用占位符，{{request.name}} 取请求中的字段
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    *mockError      `json:"error,omitempty"`
+	// 返回前等待的时间，秒数或 "200ms" 这样的写法，最长 mockMaxDelay
+	Delay interface{} `json:"delay,omitempty"`
+}
+
+// mockError 是规则返回的 go-micro 错误
+type mockError struct {
+	Code   int32  `json:"code"`
+	Detail string `json:"detail"`
+}
+
+// mockStatus 是 mock 和它正在运行的 server 的地址
+type mockStatus struct {
+	*mock
+	Address string `json:"address,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type mockSet struct {
+	sync.RWMutex
+	file    string
+	mocks   map[string]*mock
+	servers map[string]server.Server
+	// 启动失败的原因
+	errors map[string]string
+}
+
+var mocks = &mockSet{
+	mocks:   make(map[string]*mock),
+	servers: make(map[string]server.Server),
+	errors:  make(map[string]string),
+}
+
+// loadMockFile 加载保存的 mock 并注册到 registry，文件不存在时在第一次保存时创建
+func loadMockFile(file string) error {
+	mocks.Lock()
+	defer mocks.Unlock()
+	mocks.file = file
+	if len(file) == 0 {
+		return nil
+	}
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var list []*mock
+	if err := json.Unmarshal(b, &list); err != nil {
+		return fmt.Errorf("load %s: %v", file, err)
+	}
+	for _, m := range list {
+		mocks.mocks[m.Service] = m
+		if err := mocks.start(m); err != nil {
+			log.Logf("error starting mock %s: %v", m.Service, err)
+		}
+	}
+	return nil
+}
+
+// parseMock 读取 YAML 或 JSON 格式的 mock 定义
+func parseMock(b []byte) (*mock, error) {
+	j, err := yaml.YAMLToJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	m := new(mock)
+	d := json.NewDecoder(bytes.NewReader(j))
+	d.UseNumber()
+	if err := d.Decode(m); err != nil {
+		return nil, err
+	}
+	if len(m.Service) == 0 {
+		return nil, fmt.Errorf("service is required")
+	}
+	if len(m.Endpoints) == 0 {
+		return nil, fmt.Errorf("at least one endpoint is required")
+	}
+	for _, ep := range m.Endpoints {
+		if !strings.Contains(ep.Name, ".") {
+			return nil, fmt.Errorf("invalid endpoint %q, expected Service.Method", ep.Name)
+		}
+		for _, rule := range ep.Rules {
+			if _, err := parseTimeout(rule.Delay); err != nil {
+				return nil, fmt.Errorf("%s: invalid delay %v", ep.Name, rule.Delay)
+			}
+		}
+	}
+	return m, nil
+}
+
+// save 把 mock 写回文件，调用时需持有锁
+func (s *mockSet) save() error {
+	if len(s.file) == 0 {
+		return nil
+	}
+	b, err := json.MarshalIndent(s.sorted(), "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.file, b, 0600)
+}
+
+func (s *mockSet) sorted() []*mock {
+	list := make([]*mock, 0, len(s.mocks))
+	for _, m := range s.mocks {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Service < list[j].Service
+	})
+	return list
+}
+
+// list 返回所有 mock 和它们的地址
+func (s *mockSet) list() []*mockStatus {
+	s.RLock()
+	defer s.RUnlock()
+	var list []*mockStatus
+	for _, m := range s.sorted() {
+		st := &mockStatus{mock: m, Error: s.errors[m.Service]}
+		if srv, ok := s.servers[m.Service]; ok {
+			st.Address = srv.Options().Address
+		}
+		list = append(list, st)
+	}
+	return list
+}
+
+// put 保存 mock，同名的服务先从 registry 中移除再重新注册
+func (s *mockSet) put(m *mock) error {
+	s.Lock()
+	defer s.Unlock()
+	s.stop(m.Service)
+	s.mocks[m.Service] = m
+	if err := s.save(); err != nil {
+		return err
+	}
+	return s.start(m)
+}
+
+// delete 删除 mock 并从 registry 中移除
+func (s *mockSet) delete(service string) error {
+	s.Lock()
+	defer s.Unlock()
+	s.stop(service)
+	delete(s.mocks, service)
+	return s.save()
+}
+
+// stopAll 在 dashboard 退出时把所有 mock 从 registry 中移除，定义留在文件中下次启动时再注册
+func (s *mockSet) stopAll() {
+	s.Lock()
+	defer s.Unlock()
+	for service := range s.servers {
+		s.stop(service)
+	}
+}
+
+// checkNodes 检查 registry 中是否已经有不是 mock 的节点，没有设置 override 时不和真的服务混在一起
+func (m *mock) checkNodes() error {
+	if m.Override {
+		return nil
+	}
+	services, err := defaultRegistry().GetService(m.Service)
+	if err != nil && err != registry.ErrNotFound {
+		return err
+	}
+	for _, svc := range services {
+		for _, n := range svc.Nodes {
+			if n.Metadata["mock"] != "true" {
+				return fmt.Errorf("service %s has a registered node %s that is not a mock, set override to register the mock anyway", m.Service, n.Address)
+			}
+		}
+	}
+	return nil
+}
+
+// start 启动 mock 的 server 并注册到 registry，调用时需持有锁
+func (s *mockSet) start(m *mock) error {
+	delete(s.errors, m.Service)
+	if err := m.checkNodes(); err != nil {
+		s.errors[m.Service] = err.Error()
+		return err
+	}
+	opts := []server.Option{
+		server.Name(m.Service),
+		server.Address(":0"),
+		server.Registry(defaultRegistry()),
+		server.Metadata(map[string]string{"mock": "true"}),
+		server.WithRouter(&mockRouter{mock: m}),
+	}
+	if len(m.Version) > 0 {
+		opts = append(opts, server.Version(m.Version))
+	}
+	// 和 dashboard 的 client 用同一种协议，Call 页面才能调用
+	var srv server.Server
+	if defaultClient().String() == "grpc" {
+		srv = grpcserver.NewServer(opts...)
+	} else {
+		srv = server.NewServer(opts...)
+	}
+	err := srv.Handle(&mockHandler{endpoints: m.registryEndpoints()})
+	if err == nil {
+		err = srv.Start()
+	}
+	if err != nil {
+		s.errors[m.Service] = err.Error()
+		return err
+	}
+	s.servers[m.Service] = srv
+	return nil
+}
+
+// stop 停止 mock 的 server，go-micro 在停止时从 registry 中注销，调用时需持有锁
+func (s *mockSet) stop(service string) {
+	srv, ok := s.servers[service]
+	if !ok {
+		return
+	}
+	delete(s.servers, service)
+	if err := srv.Stop(); err != nil {
+		log.Logf("error stopping mock %s: %v", service, err)
+	}
+}
+
+// registryEndpoints 按请求示例和第一条规则的响应生成 registry 中的 endpoint
+func (m *mock) registryEndpoints() []*registry.Endpoint {
+	var eps []*registry.Endpoint
+	for _, ep := range m.Endpoints {
+		method := ep.Name[strings.LastIndex(ep.Name, ".")+1:]
+		e := &registry.Endpoint{
+			Name:     ep.Name,
+			Request:  exampleValue(method+"Request", exampleJSON(ep.Request)),
+			Response: &registry.Value{Name: method + "Response", Type: method + "Response"},
+			Metadata: map[string]string{"mock": "true"},
+		}
+		for _, rule := range ep.Rules {
+			if len(rule.Response) > 0 {
+				e.Response = exampleValue(method+"Response", exampleJSON(rule.Response))
+				break
+			}
+		}
+		eps = append(eps, e)
+	}
+	return eps
+}
+
+func exampleJSON(b json.RawMessage) interface{} {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil
+	}
+	return v
+}
+
+// exampleValue 按 JSON 示例推出 registry 中的类型，嵌套对象的类型名由字段名得到，如 user 得到 User
+func exampleValue(name string, v interface{}) *registry.Value {
+	val := &registry.Value{Name: name, Type: exampleType(name, v)}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		if list, ok := v.([]interface{}); ok && len(list) > 0 {
+			val.Values = exampleValue(name, list[0]).Values
+		}
+		return val
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		val.Values = append(val.Values, exampleValue(k, obj[k]))
+	}
+	return val
+}
+
+func exampleType(name string, v interface{}) string {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		return goName(name)
+	case []interface{}:
+		if len(x) == 0 {
+			return "[]string"
+		}
+		return "[]" + exampleType(name, x[0])
+	case json.Number:
+		if _, err := x.Int64(); err == nil {
+			return "int64"
+		}
+		return "float64"
+	case bool:
+		return "bool"
+	}
+	return "string"
+}
+
+// mockRouter 处理 mock 收到的所有请求
+type mockRouter struct {
+	mock *mock
+}
+
+func (m *mockRouter) ServeRequest(ctx context.Context, req server.Request, rsp server.Response) error {
+	var ep *mockEndpoint
+	for _, e := range m.mock.Endpoints {
+		if e.Name == req.Endpoint() {
+			ep = e
+		}
+	}
+	if ep == nil {
+		return errors.NotFound(m.mock.Service, "unknown endpoint %s", req.Endpoint())
+	}
+	// 和 go-micro 的 router 一样经过 codec 读写，jsonrpc 的请求从 params 中取出，响应放进 result
+	var msg codec.Message
+	if err := req.Codec().ReadHeader(&msg, codec.Request); err != nil {
+		return errors.BadRequest(m.mock.Service, "%v", err)
+	}
+	var body json.RawMessage
+	if err := req.Codec().ReadBody(&body); err != nil {
+		return errors.BadRequest(m.mock.Service, "mock only accepts JSON requests: %v", err)
+	}
+	b, err := ep.respond(ctx, m.mock.Service, body)
+	if err != nil {
+		return err
+	}
+	// json.RawMessage 原样编码，直接写 []byte 时 jsonrpc 会编码成 base64
+	return rsp.Codec().Write(&codec.Message{
+		Id:       msg.Id,
+		Target:   req.Service(),
+		Method:   req.Method(),
+		Endpoint: req.Endpoint(),
+		Type:     codec.Response,
+	}, json.RawMessage(b))
+}
+
+// ProcessMessage 处理 broker 的消息，mock 不订阅事件
+func (m *mockRouter) ProcessMessage(ctx context.Context, msg server.Message) error {
+	return errors.BadRequest(m.mock.Service, "mock does not handle messages on %s", msg.Topic())
+}
+
+// respond 按第一条匹配的规则给出响应，请求只支持 JSON 编码
+// 等待 delay 时调用方取消或超时就不再等
+func (ep *mockEndpoint) respond(ctx context.Context, service string, body []byte) ([]byte, error) {
+	var doc interface{}
+	if len(bytes.TrimSpace(body)) > 0 {
+		d := json.NewDecoder(bytes.NewReader(body))
+		d.UseNumber()
+		if err := d.Decode(&doc); err != nil {
+			return nil, errors.BadRequest(service, "mock only accepts JSON requests: %v", err)
+		}
+	}
+	x, err := newExpander("")
+	if err != nil {
+		return nil, err
+	}
+	x.req = doc
+
+	for _, rule := range ep.Rules {
+		matched := true
+		for _, a := range rule.When {
+			if a.check(x, doc) != nil {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if delay, _ := parseTimeout(rule.Delay); delay > 0 {
+			if delay > mockMaxDelay {
+				delay = mockMaxDelay
+			}
+			t := time.NewTimer(delay)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return nil, errors.Timeout(service, "mock delay interrupted: %v", ctx.Err())
+			}
+		}
+		if rule.Error != nil {
+			return nil, errors.New(service, rule.Error.Detail, rule.Error.Code)
+		}
+		if len(rule.Response) == 0 {
+			return []byte("{}"), nil
+		}
+		s, err := x.expandJSON(string(rule.Response))
+		if err != nil {
+			return nil, errors.InternalServerError(service, "mock response: %v", err)
+		}
+		return []byte(s), nil
+	}
+	return nil, errors.NotFound(service, "no mock rule matches the request to %s", ep.Name)
+}
+
+// Mock 只用来向 go-micro 的 server 登记 handler，server 要求 handler 至少有一个这样的方法
+// 设置了 router 后请求都交给 mockRouter，不会调用到这里
+type Mock struct{}
+
+func (*Mock) Ping(ctx context.Context, req *json.RawMessage, rsp *json.RawMessage) error {
+	return nil
+}
+
+// mockHandler 把 mock 的 endpoint 登记到 registry 中
+type mockHandler struct {
+	endpoints []*registry.Endpoint
+}
+
+func (h *mockHandler) Name() string {
+	return "Mock"
+}
+
+func (h *mockHandler) Handler() interface{} {
+	return new(Mock)
+}
+
+func (h *mockHandler) Endpoints() []*registry.Endpoint {
+	return h.endpoints
+}
+
+func (h *mockHandler) Options() server.HandlerOptions {
+	return server.HandlerOptions{}
+}
+
+// mocksHandler 显示 mock 页面，JSON 请求时返回所有 mock
+// POST /mocks 以 YAML 或 JSON 定义 mock，同名的服务会被替换
+// DELETE /mocks?service=go.micro.srv.user
+func mocksHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "ParseForm err:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+			return
+		}
+		m, err := parseMock(b)
+		if err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+			return
+		}
+		// 先检查节点，被拒绝的 mock 不保存到文件
+		if err := m.checkNodes(); err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusConflict)
+			return
+		}
+		if err := mocks.put(m); err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	case "DELETE":
+		if err := mocks.delete(r.Form.Get("service")); err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if r.Header.Get("Content-Type") != "application/json" {
+		render(w, r, mockTemplate, nil)
+		return
+	}
+	b, err := json.Marshal(map[string]interface{}{"mocks": mocks.list()})
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
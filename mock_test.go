@@ -0,0 +1,165 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/config/cmd"
+	"github.com/micro/go-micro/errors"
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/go-micro/registry/memory"
+)
+
+// useMemoryRegistry 让 dashboard 使用内存中的 registry，返回的函数恢复原来的 registry 和 client
+func useMemoryRegistry() (registry.Registry, client.Client, func()) {
+	opts := cmd.DefaultOptions()
+	oldRegistry, oldClient := *opts.Registry, *opts.Client
+	r := memory.NewRegistry()
+	c := client.NewClient(client.Registry(r))
+	*opts.Registry, *opts.Client = r, c
+	return r, c, func() {
+		*opts.Registry, *opts.Client = oldRegistry, oldClient
+	}
+}
+
+const testMock = `{
+	"service": "go.micro.srv.mock",
+	"endpoints": [{
+		"name": "User.Get",
+		"request": {"id": "u-1"},
+		"rules": [
+			{"when": [{"path": "$.id", "equals": "missing"}], "error": {"code": 404, "detail": "user not found"}},
+			{"response": {"id": "{{request.id}}", "age": "{{request.age}}", "tags": ["a", "b"]}}
+		]
+	}]
+}`
+
+func startTestMock(t *testing.T, def string) *mock {
+	m, err := parseMock([]byte(def))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mocks.Lock()
+	defer mocks.Unlock()
+	if err := mocks.start(m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func stopTestMock(m *mock) {
+	mocks.Lock()
+	defer mocks.Unlock()
+	mocks.stop(m.Service)
+}
+
+func TestMockCall(t *testing.T) {
+	_, c, reset := useMemoryRegistry()
+	defer reset()
+	m := startTestMock(t, testMock)
+	defer stopTestMock(m)
+
+	tests := []struct {
+		name     string
+		endpoint string
+		request  string
+		response string
+		code     int32
+	}{
+		{name: "template", endpoint: "User.Get", request: `{"id": "u-2", "age": 30}`, response: `{"id": "u-2", "age": 30, "tags": ["a", "b"]}`},
+		{name: "error rule", endpoint: "User.Get", request: `{"id": "missing"}`, code: 404},
+		{name: "unknown endpoint", endpoint: "User.Delete", request: `{"id": "u-3"}`, code: 404},
+	}
+	// jsonrpc 的响应放在 result 中，直接写 []byte 时会变成 base64
+	for _, ct := range []string{"application/json", "application/json-rpc"} {
+		for _, tt := range tests {
+			t.Run(ct+"/"+tt.name, func(t *testing.T) {
+				req := c.NewRequest(m.Service, tt.endpoint, json.RawMessage(tt.request), client.WithContentType(ct))
+				var rsp json.RawMessage
+				err := c.Call(context.Background(), req, &rsp)
+				if tt.code != 0 {
+					if err == nil {
+						t.Fatalf("expected error %d, got %s", tt.code, rsp)
+					}
+					if e := errors.Parse(err.Error()); e.Code != tt.code {
+						t.Fatalf("expected error %d, got %v", tt.code, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got, want interface{}
+				if err := json.Unmarshal(rsp, &got); err != nil {
+					t.Fatalf("response %s is not JSON: %v", rsp, err)
+				}
+				json.Unmarshal([]byte(tt.response), &want)
+				if !jsonEqual(got, want) {
+					t.Fatalf("expected %s, got %s", tt.response, rsp)
+				}
+			})
+		}
+	}
+}
+
+func TestMockRefusesRealNodes(t *testing.T) {
+	r, _, reset := useMemoryRegistry()
+	defer reset()
+	real := &registry.Service{
+		Name:  "go.micro.srv.mock",
+		Nodes: []*registry.Node{{Id: "real-1", Address: "10.0.0.1:9090"}},
+	}
+	if err := r.Register(real); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := parseMock([]byte(testMock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mocks.Lock()
+	err = mocks.start(m)
+	mocks.Unlock()
+	if err == nil {
+		stopTestMock(m)
+		t.Fatal("expected the mock to be refused next to a real node")
+	}
+
+	m.Override = true
+	mocks.Lock()
+	err = mocks.start(m)
+	mocks.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopTestMock(m)
+}
+
+// 调用方取消后不再等 delay，delay 也不会超过 mockMaxDelay
+func TestMockDelay(t *testing.T) {
+	m, err := parseMock([]byte(`{"service": "go.micro.srv.mock", "endpoints": [{"name": "User.Get", "rules": [{"delay": "1h", "response": {"id": "u-1"}}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep := m.Endpoints[0]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = ep.respond(ctx, m.Service, []byte(`{}`))
+	if e, ok := err.(*errors.Error); !ok || e.Code != 408 {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected respond to return when the context is done, took %s", elapsed)
+	}
+
+	ep.Rules[0].Delay = "10ms"
+	b, err := ep.respond(context.Background(), m.Service, []byte(`{}`))
+	if err != nil || string(b) != `{"id":"u-1"}` {
+		t.Fatalf("expected the response after the delay, got %s, %v", b, err)
+	}
+}
@@ -0,0 +1,241 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// 字段名的写法
+const (
+	// proto 文件中的原名，也是 protoc-gen-go 生成的 json tag
+	namingProto = "proto"
+	// protojson 默认的 lowerCamel 写法，有 json_name 选项时以选项为准
+	namingCamel = "camel"
+	// snake_case，用 proto 文件中的字段名，按 proto 的风格指南就是 snake_case
+	// 不从 Go 名转换，转换会把 UserID 这样的缩写拆开
+	namingSnake = "snake"
+)
+
+// 请求示例、JSON Schema 和响应中字段名的写法
+var fieldNaming = namingProto
+
+// checkFieldNaming 检查命令行中指定的写法
+func checkFieldNaming(naming string) error {
+	switch naming {
+	case namingProto, namingCamel, namingSnake:
+		return nil
+	}
+	return fmt.Errorf("invalid field naming %q, expected proto, camel or snake", naming)
+}
+
+// fieldName 给出 registry 中字段的键名，registry 中的名字取自 json tag，即 proto 原名
+func fieldName(name string) string {
+	return namedField(fieldNaming, name)
+}
+
+// namedField 按指定的写法给出 registry 中字段的键名
+func namedField(naming, name string) string {
+	// snake 写法和 proto 原名相同
+	if naming == namingCamel {
+		return lowerCamel(name)
+	}
+	return name
+}
+
+// protoFieldName 给出 proto 描述中字段的键名
+func protoFieldName(f *descriptor.FieldDescriptorProto) string {
+	return namedProtoField(fieldNaming, f)
+}
+
+// namedProtoField 按指定的写法给出 proto 描述中字段的键名
+func namedProtoField(naming string, f *descriptor.FieldDescriptorProto) string {
+	if naming == namingCamel {
+		if len(f.GetJsonName()) > 0 {
+			return f.GetJsonName()
+		}
+		return lowerCamel(f.GetName())
+	}
+	return f.GetName()
+}
+
+// lowerCamel 和 protoc 生成 json_name 的规则一致：去掉下划线，下划线后的字母大写
+func lowerCamel(name string) string {
+	var b strings.Builder
+	upper := false
+	for i, r := range name {
+		switch {
+		case r == '_':
+			upper = true
+		case upper && 'a' <= r && r <= 'z':
+			b.WriteRune(r - 'a' + 'A')
+			upper = false
+		case i == 0 && 'A' <= r && r <= 'Z':
+			b.WriteRune(r - 'A' + 'a')
+		default:
+			b.WriteRune(r)
+			upper = false
+		}
+	}
+	return b.String()
+}
+
+// normalizeName 去掉写法上的差别，user_id、userId、UserID 都得到 userid
+func normalizeName(name string) string {
+	return strings.ToLower(strings.Replace(name, "_", "", -1))
+}
+
+// findProperty 按字段名找 schema 中的属性，写法不同也能找到
+func findProperty(properties jsonObject, key string) (string, jsonObject, bool) {
+	if p, ok := properties.get(key); ok {
+		return key, p.(jsonObject), true
+	}
+	n := normalizeName(key)
+	for _, f := range properties {
+		if normalizeName(f.Key) == n {
+			return f.Key, f.Value.(jsonObject), true
+		}
+	}
+	return "", nil, false
+}
+
+// displayResponse 按 fieldNaming 改写要显示的响应，proto 写法时不改动
+// 调用得到的响应保持服务返回的原样，只在显示时改写，不算在调用的耗时中
+func displayResponse(service, endpoint string, b []byte) []byte {
+	if fieldNaming == namingProto || len(b) == 0 {
+		return b
+	}
+	schema := responseSchema(service, endpoint, fieldNaming)
+	if schema == nil {
+		return b
+	}
+	return renameResponse(schema, b)
+}
+
+// displayResult 按 fieldNaming 改写调用结果中要显示的响应
+// gRPC 方式的响应按 proto 原名输出，按 proto 描述中的响应类型改写，服务不需要在 registry 中
+func displayResult(c *rpcCall, res *rpcResult) []byte {
+	if len(res.output) == 0 {
+		return displayResponse(c.service, c.endpoint, res.response)
+	}
+	return displayOutput(res.output, res.response)
+}
+
+// displayOutput 把 gRPC 按 proto 原名返回的 output 类型的消息改成 fieldNaming 的写法
+func displayOutput(output string, b []byte) []byte {
+	if fieldNaming == namingProto || len(b) == 0 {
+		return b
+	}
+	return renameResponse(descriptors.messageSchema(output, fieldNaming), b)
+}
+
+// protoResponse 把响应的字段名改回 proto 原名，找不到 schema 时返回 false
+// 不同写法下记录的响应这样才能比较
+func protoResponse(service, endpoint string, b []byte) ([]byte, bool) {
+	schema := responseSchema(service, endpoint, namingProto)
+	if schema == nil {
+		return b, false
+	}
+	if len(b) == 0 {
+		return b, true
+	}
+	return renameResponse(schema, b), true
+}
+
+// renameResponse 按响应的 schema 把服务返回的字段名改成 schema 中的写法，字段顺序与 schema 一致
+func renameResponse(schema jsonObject, b []byte) []byte {
+	var v interface{}
+	d := json.NewDecoder(strings.NewReader(string(b)))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return b
+	}
+	r := &renamer{}
+	if defs, ok := schema.get("$defs"); ok {
+		r.defs, _ = defs.(map[string]interface{})
+	}
+	out, err := marshalJSON(r.rename(schema, v), "")
+	if err != nil {
+		return b
+	}
+	return out
+}
+
+type renamer struct {
+	defs map[string]interface{}
+}
+
+func (r *renamer) rename(schema jsonObject, v interface{}) interface{} {
+	if ref, ok := schema.get("$ref"); ok {
+		schema, _ = r.defs[strings.TrimPrefix(ref.(string), "#/$defs/")].(jsonObject)
+	}
+
+	switch val := v.(type) {
+	case []interface{}:
+		items, _ := schema.get("items")
+		itemSchema, _ := items.(jsonObject)
+		for i := range val {
+			val[i] = r.rename(itemSchema, val[i])
+		}
+		return val
+	case map[string]interface{}:
+		props, _ := schema.get("properties")
+		properties, _ := props.(jsonObject)
+		extra, _ := schema.get("additionalProperties")
+		extraSchema, isMap := extra.(jsonObject)
+
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		// 每个属性只对应一个键，写法完全相同的先对应，其余的再按 normalizeName 对应
+		// 这样同时有 user_id 和 userId 时不会得到两个同名的键
+		matched := make(map[string]string)
+		found := make(map[string]bool)
+		for _, p := range properties {
+			if _, ok := val[p.Key]; ok {
+				matched[p.Key] = p.Key
+				found[p.Key] = true
+			}
+		}
+		for _, p := range properties {
+			if _, ok := matched[p.Key]; ok {
+				continue
+			}
+			n := normalizeName(p.Key)
+			for _, k := range keys {
+				if !found[k] && normalizeName(k) == n {
+					matched[p.Key] = k
+					found[k] = true
+					break
+				}
+			}
+		}
+
+		// 先按 schema 中的顺序放已知字段，其余的按字母序放在后面
+		obj := make(jsonObject, 0, len(val))
+		for _, p := range properties {
+			if k, ok := matched[p.Key]; ok {
+				ps, _ := p.Value.(jsonObject)
+				obj = append(obj, jsonField{p.Key, r.rename(ps, val[k])})
+			}
+		}
+		for _, k := range keys {
+			if found[k] {
+				continue
+			}
+			if isMap {
+				obj = append(obj, jsonField{k, r.rename(extraSchema, val[k])})
+			} else {
+				obj = append(obj, jsonField{k, val[k]})
+			}
+		}
+		return obj
+	}
+	return v
+}
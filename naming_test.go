@@ -0,0 +1,83 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func TestNamedField(t *testing.T) {
+	tests := []struct {
+		naming string
+		name   string
+		want   string
+	}{
+		{naming: namingProto, name: "created_at", want: "created_at"},
+		{naming: namingCamel, name: "created_at", want: "createdAt"},
+		{naming: namingCamel, name: "user_id_2", want: "userId2"},
+		{naming: namingSnake, name: "created_at", want: "created_at"},
+		// 缩写不拆开
+		{naming: namingSnake, name: "userID", want: "userID"},
+		{naming: namingSnake, name: "HTTPStatus", want: "HTTPStatus"},
+	}
+	for _, tt := range tests {
+		if got := namedField(tt.naming, tt.name); got != tt.want {
+			t.Errorf("namedField(%s, %q) = %q, expected %q", tt.naming, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNamedProtoField(t *testing.T) {
+	field := func(name, jsonName string) *descriptor.FieldDescriptorProto {
+		f := &descriptor.FieldDescriptorProto{Name: proto.String(name)}
+		if len(jsonName) > 0 {
+			f.JsonName = proto.String(jsonName)
+		}
+		return f
+	}
+	tests := []struct {
+		naming string
+		field  *descriptor.FieldDescriptorProto
+		want   string
+	}{
+		{naming: namingProto, field: field("created_at", "createdAt"), want: "created_at"},
+		{naming: namingCamel, field: field("created_at", "createdAt"), want: "createdAt"},
+		// json_name 选项优先
+		{naming: namingCamel, field: field("created_at", "ctime"), want: "ctime"},
+		{naming: namingCamel, field: field("created_at", ""), want: "createdAt"},
+		{naming: namingSnake, field: field("created_at", "createdAt"), want: "created_at"},
+		{naming: namingSnake, field: field("userID", "userID"), want: "userID"},
+	}
+	for _, tt := range tests {
+		if got := namedProtoField(tt.naming, tt.field); got != tt.want {
+			t.Errorf("namedProtoField(%s, %s) = %q, expected %q", tt.naming, tt.field.GetName(), got, tt.want)
+		}
+	}
+}
+
+func TestRenameResponse(t *testing.T) {
+	d := testDescriptors()
+	tests := []struct {
+		name     string
+		naming   string
+		response string
+		want     string
+	}{
+		{name: "camel", naming: namingCamel, response: `{"id":"u-1","created_at":"2019-11-11T11:11:11Z"}`, want: `{"id":"u-1","createdAt":"2019-11-11T11:11:11Z"}`},
+		{name: "nested", naming: namingCamel, response: `{"groups":[{"name":"admins","members":[{"created_at":null}]}]}`, want: `{"groups":[{"name":"admins","members":[{"createdAt":null}]}]}`},
+		// 写法相同的键先对应，另一个键原样放在后面，不会有两个 createdAt
+		{name: "exact first", naming: namingCamel, response: `{"created_at":"a","createdAt":"b"}`, want: `{"createdAt":"b","created_at":"a"}`},
+		{name: "exact first snake", naming: namingSnake, response: `{"createdAt":"b","created_at":"a"}`, want: `{"created_at":"a","createdAt":"b"}`},
+		{name: "schema order", naming: namingCamel, response: `{"status":"ACTIVE","id":"u-1"}`, want: `{"id":"u-1","status":"ACTIVE"}`},
+		{name: "unknown fields", naming: namingCamel, response: `{"id":"u-1","zone":"cn","extra":1}`, want: `{"id":"u-1","extra":1,"zone":"cn"}`},
+		{name: "map keys", naming: namingCamel, response: `{"scores":{"user_id":1}}`, want: `{"scores":{"user_id":1}}`},
+		{name: "not json", naming: namingCamel, response: `not json`, want: `not json`},
+	}
+	for _, tt := range tests {
+		got := string(renameResponse(d.messageSchema(".test.User", tt.naming), []byte(tt.response)))
+		if got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
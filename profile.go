@@ -0,0 +1,222 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ghodss/yaml"
+)
+
+// 默认使用的 profile，生成零值，不带团队约定的示例数据
+var defaultProfile = "zero"
+
+// Profile 是一套请求示例的取值规则，可以从 YAML/JSON 文件加载
+//
+//	name: team
+//	services: [go.micro.srv.greeter]
+//	types:
+//	  string: "hello"
+//	fields:
+//	  - pattern: "*_id"
+//	    value: "u-10001"
+type Profile struct {
+	Name string `json:"name"`
+	// 只对这些服务生效，为空时对所有服务生效
+	Services []string `json:"services,omitempty"`
+	// 按类型名给值，如 string、int64、float64
+	Types map[string]interface{} `json:"types,omitempty"`
+	// 按字段名给值，先匹配先用，优先于 Types
+	Fields []FieldRule `json:"fields,omitempty"`
+	// 为 true 时按字段名生成随机数据，Fields 没有覆盖的字段才会用到
+	Generate bool `json:"generate,omitempty"`
+}
+
+// FieldRule 按字段名匹配示例值，Pattern 使用 path.Match 的语法
+type FieldRule struct {
+	Pattern string `json:"pattern"`
+	// 只匹配这个类型的字段，为空时不限
+	Type  string      `json:"type,omitempty"`
+	Value interface{} `json:"value"`
+}
+
+// valueSource 给出 name 字段的示例值，找不到时返回 false
+type valueSource func(name, typ string) (interface{}, bool)
+
+// values 返回一次生成使用的取值函数，seed 相同时生成的数据相同
+func (p *Profile) values(seed int64) valueSource {
+	var fake *fakeData
+	if p.Generate {
+		fake = newFakeData(seed)
+	}
+	return func(name, typ string) (interface{}, bool) {
+		if v, ok := p.field(name, typ); ok {
+			return v, true
+		}
+		if fake != nil {
+			if v, ok := fake.value(name, typ); ok {
+				return v, true
+			}
+		}
+		if v, ok := p.Types[typ]; ok {
+			return v, true
+		}
+		v, ok := DefaultMap[typ]
+		return v, ok
+	}
+}
+
+func (p *Profile) field(name, typ string) (interface{}, bool) {
+	name = strings.ToLower(name)
+	for _, f := range p.Fields {
+		if len(f.Type) > 0 && f.Type != typ {
+			continue
+		}
+		// 字段名和 pattern 都按小写比较，*_ID 也能匹配 user_id
+		if ok, _ := path.Match(strings.ToLower(f.Pattern), name); ok {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+func (p *Profile) appliesTo(service string) bool {
+	if len(p.Services) == 0 {
+		return true
+	}
+	for _, s := range p.Services {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
+
+type profileSet struct {
+	sync.RWMutex
+	profiles []*Profile
+}
+
+var profiles = &profileSet{
+	profiles: []*Profile{
+		{
+			Name: "zero",
+			Types: map[string]interface{}{
+				"string":  "",
+				"bool":    false,
+				"int32":   0,
+				"int64":   0,
+				"uint8":   0,
+				"uint32":  0,
+				"uint64":  0,
+				"float32": 0,
+				"float64": 0,
+
+				"google.protobuf.Timestamp": "1970-01-01T00:00:00Z",
+				"google.protobuf.Duration":  "0s",
+				"google.protobuf.Struct":    jsonObject{},
+				"google.protobuf.Value":     nil,
+				"google.protobuf.ListValue": []interface{}{},
+				"google.protobuf.FieldMask": "",
+				"google.protobuf.Any":       jsonObject{},
+				"google.protobuf.Empty":     jsonObject{},
+			},
+		},
+		{
+			Name:  "team",
+			Types: DefaultMap,
+		},
+		{
+			Name:     "fake",
+			Generate: true,
+		},
+	},
+}
+
+// get 按名字找 profile，有针对服务的 profile 时优先使用
+func (s *profileSet) get(name, service string) *Profile {
+	s.RLock()
+	defer s.RUnlock()
+	var found *Profile
+	for _, p := range s.profiles {
+		if p.Name != name || !p.appliesTo(service) {
+			continue
+		}
+		if len(p.Services) > 0 {
+			return p
+		}
+		found = p
+	}
+	if found != nil {
+		return found
+	}
+	if name != defaultProfile {
+		for _, p := range s.profiles {
+			if p.Name == defaultProfile && len(p.Services) == 0 {
+				return p
+			}
+		}
+	}
+	// 连默认的 profile 都没有时只用 DefaultMap
+	return &Profile{Name: name}
+}
+
+// add 加入一个 profile，同名且生效范围相同的会被替换
+func (s *profileSet) add(p *Profile) {
+	s.Lock()
+	defer s.Unlock()
+	key := strings.Join(p.Services, ",")
+	for i, old := range s.profiles {
+		if old.Name == p.Name && strings.Join(old.Services, ",") == key {
+			s.profiles[i] = p
+			return
+		}
+	}
+	s.profiles = append(s.profiles, p)
+}
+
+// Names 返回所有 profile 的名字
+func (s *profileSet) Names() []string {
+	s.RLock()
+	defer s.RUnlock()
+	seen := make(map[string]bool)
+	var names []string
+	for _, p := range s.profiles {
+		if !seen[p.Name] {
+			seen[p.Name] = true
+			names = append(names, p.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadProfileFiles 加载启动参数指定的 profile 文件，按扩展名区分 YAML 和 JSON
+func loadProfileFiles(paths []string) error {
+	for _, file := range paths {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		switch filepath.Ext(file) {
+		case ".yaml", ".yml":
+			if b, err = yaml.YAMLToJSON(b); err != nil {
+				return fmt.Errorf("load %s: %v", file, err)
+			}
+		}
+		p := new(Profile)
+		if err := json.Unmarshal(b, p); err != nil {
+			return fmt.Errorf("load %s: %v", file, err)
+		}
+		if len(p.Name) == 0 {
+			p.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		}
+		profiles.add(p)
+	}
+	return nil
+}
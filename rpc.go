@@ -0,0 +1,603 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/errors"
+	"github.com/micro/go-micro/metadata"
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/go-micro/util/log"
+)
+
+// 为 true 时所有 /rpc 请求都先按 endpoint 的类型校验
+var strictMode bool
+
+type rpcRequest struct {
+	Service  string
+	Endpoint string
+	Method   string
+	Address  string
+	Request  interface{}
+	// 为 true 时先按 endpoint 的类型校验请求
+	Strict bool
+	// 调用方式，micro 或 grpc，默认 micro
+	Transport string
+	// 随请求发给服务的 metadata
+	Metadata map[string]string
+	// 整个调用（包括重试）的超时，秒数或 "500ms" 这样的写法
+	Timeout interface{}
+	// 每次请求的超时
+	RequestTimeout interface{} `json:"request_timeout"`
+	// 失败后重试的次数，不填时用 client 的默认值
+	Retries *int
+	// 为 true 时响应放在 {"node": ..., "attempts": ..., "response": ...} 中返回
+	Envelope bool
+	// 替换请求中 {{env.xxx}} 用的环境
+	Env string
+	// 为 true 时替换请求和 metadata 中的 {{...}} 占位符，默认原样发送
+	Expand bool
+}
+
+// rpcCall 是一次 /rpc 调用
+type rpcCall struct {
+	service   string
+	endpoint  string
+	address   string
+	transport string
+	request   interface{}
+	metadata  map[string]string
+	strict    bool
+	envelope  bool
+	env       string
+	// 为 true 时请求中的占位符按环境替换，代码片段中保留占位符，由 /rpc 替换
+	expand bool
+
+	// 替换占位符之前的请求和 metadata，调用历史中保存这些，环境中 token 之类的值不会写进文件
+	rawRequest  interface{}
+	rawMetadata map[string]string
+	// 场景中前面的步骤取出的变量，重放时替换 {{vars.xxx}}
+	vars map[string]interface{}
+
+	timeout        time.Duration
+	requestTimeout time.Duration
+	// 小于 0 时用 client 的默认值
+	retries int
+}
+
+// rpcResult 是调用的结果，err 不为 nil 时 response 为空
+type rpcResult struct {
+	response []byte
+	err      *errors.Error
+	// 最后一次请求发往的节点
+	node     *registry.Node
+	attempts int
+	latency  time.Duration
+	// gRPC 方式调用出错时的 status code，如 NotFound
+	grpcCode string
+	timing   rpcTiming
+	// gRPC 方式调用时响应的消息类型，如 .hello.Response，显示时按它的 proto 描述改写字段名
+	output string
+}
+
+// rpcTiming 是一次调用各阶段的耗时和请求、响应的大小
+type rpcTiming struct {
+	// 从 registry 查到服务并选出节点
+	selector time.Duration
+	// 建立到节点的连接，小于 0 时测不到：go-micro 方式由 client 的连接池在请求中建立，算在 server 中
+	connect time.Duration
+	// 最后一次请求从发出到收到响应，包括网络和服务端的处理
+	server time.Duration
+	// go-micro 方式是 JSON 的大小，gRPC 方式是 protobuf 编码后的大小
+	requestBytes  int
+	responseBytes int
+}
+
+// requestMetadata 把 HTTP header 转成 metadata，r 为 nil 时（命令行中调用）没有 header
+func requestMetadata(r *http.Request) map[string]string {
+	md := make(map[string]string)
+	if r == nil {
+		return md
+	}
+	for k, v := range r.Header {
+		md[k] = strings.Join(v, ",")
+	}
+	return md
+}
+
+// requestToContext 把 HTTP header、服务的默认 metadata 和页面上填写的 metadata 放进 context
+func requestToContext(ctx context.Context, r *http.Request, service string, md map[string]string) context.Context {
+	return metadata.NewContext(ctx, metadata.Metadata(callMetadata(r, service, md)))
+}
+
+// requestContext 是 HTTP 请求的 context，连接断开时取消调用，命令行中调用时没有 HTTP 请求
+func requestContext(r *http.Request) context.Context {
+	if r == nil {
+		return context.Background()
+	}
+	return r.Context()
+}
+
+// parseMetadata 解析表单中 JSON 格式的 metadata
+func parseMetadata(s string) (map[string]string, error) {
+	md := make(map[string]string)
+	if len(s) == 0 {
+		return md, nil
+	}
+	if err := json.Unmarshal([]byte(s), &md); err != nil {
+		return nil, err
+	}
+	return md, nil
+}
+
+// parseTimeout 解析超时，数字按秒计，也可以写成 "1m30s"
+func parseTimeout(v interface{}) (time.Duration, error) {
+	if v == nil {
+		return 0, nil
+	}
+	s := strings.TrimSpace(fmt.Sprint(v))
+	if len(s) == 0 {
+		return 0, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(f * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout %q", s)
+	}
+	return d, nil
+}
+
+// parseRPCRequest 解析 JSON 或表单格式的 /rpc 请求，expand 为 true 时才替换占位符
+func parseRPCRequest(r *http.Request) (*rpcCall, error) {
+	rpcReq, err := decodeRPCRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	x, err := newExpander(rpcReq.Env)
+	if err != nil {
+		return nil, err
+	}
+	x.keep = !rpcReq.Expand
+	c, err := rpcReq.newCall(x)
+	if err != nil {
+		return nil, err
+	}
+	// 兼容以前的 Timeout header，单位是秒，go-micro 方式下设置了 timeout 时不生效
+	if c.requestTimeout == 0 && (c.timeout == 0 || c.transport == transportGRPC) {
+		if timeout, _ := strconv.Atoi(r.Header.Get("Timeout")); timeout > 0 {
+			c.requestTimeout = time.Duration(timeout) * time.Second
+		}
+	}
+	return c, nil
+}
+
+// decodeRPCRequest 按 Content-Type 解码 JSON 或表单格式的请求
+func decodeRPCRequest(r *http.Request) (*rpcRequest, error) {
+	var rpcReq rpcRequest
+
+	ct := r.Header.Get("Content-Type")
+
+	// Strip charset from Content-Type (like `application/json; charset=UTF-8`)
+	if idx := strings.IndexRune(ct, ';'); idx >= 0 {
+		ct = ct[:idx]
+	}
+
+	switch ct {
+	case "application/json":
+		d := json.NewDecoder(r.Body)
+		d.UseNumber()
+
+		if err := d.Decode(&rpcReq); err != nil {
+			return nil, err
+		}
+	default:
+		r.ParseForm()
+		rpcReq.Service = r.Form.Get("service")
+		rpcReq.Endpoint = r.Form.Get("endpoint")
+		rpcReq.Method = r.Form.Get("method")
+		rpcReq.Address = r.Form.Get("address")
+		rpcReq.Transport = r.Form.Get("transport")
+		rpcReq.Env = r.Form.Get("env")
+		rpcReq.Request = r.Form.Get("request")
+		md, err := parseMetadata(r.Form.Get("metadata"))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding metadata: %v", err)
+		}
+		rpcReq.Metadata = md
+		rpcReq.Strict, _ = strconv.ParseBool(r.Form.Get("strict"))
+		rpcReq.Envelope, _ = strconv.ParseBool(r.Form.Get("envelope"))
+		rpcReq.Expand, _ = strconv.ParseBool(r.Form.Get("expand"))
+		rpcReq.Timeout = r.Form.Get("timeout")
+		rpcReq.RequestTimeout = r.Form.Get("request_timeout")
+		if len(r.Form.Get("retries")) > 0 {
+			retries, err := strconv.Atoi(r.Form.Get("retries"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid retries %q", r.Form.Get("retries"))
+			}
+			rpcReq.Retries = &retries
+		}
+	}
+	return &rpcReq, nil
+}
+
+// newCall 按请求构造调用，先替换请求和 metadata 中的占位符，JSON as string 替换后再解析
+func (req *rpcRequest) newCall(x *expander) (*rpcCall, error) {
+	c := &rpcCall{
+		service:    req.Service,
+		endpoint:   req.Endpoint,
+		address:    req.Address,
+		transport:  req.Transport,
+		strict:     strictMode || req.Strict,
+		envelope:   req.Envelope,
+		env:        x.env,
+		expand:     req.Expand || !x.keep,
+		rawRequest: req.Request,
+		vars:       x.values,
+		retries:    -1,
+	}
+	if len(c.endpoint) == 0 {
+		c.endpoint = req.Method
+	}
+	if req.Retries != nil {
+		c.retries = *req.Retries
+	}
+
+	var err error
+	if c.request, err = x.request(req.Request); err != nil {
+		return nil, err
+	}
+	if len(req.Metadata) > 0 {
+		c.metadata = make(map[string]string)
+		for k, v := range req.Metadata {
+			// 从历史或集合中载入的请求，敏感的值没有保存
+			if v == redactedValue {
+				continue
+			}
+			c.metadata[k] = v
+		}
+		c.rawMetadata = make(map[string]string, len(c.metadata))
+		for k, v := range c.metadata {
+			c.rawMetadata[k] = v
+		}
+		if err := x.metadata(c.metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.timeout, err = parseTimeout(req.Timeout); err != nil {
+		return nil, err
+	}
+	if c.requestTimeout, err = parseTimeout(req.RequestTimeout); err != nil {
+		return nil, err
+	}
+	// go-micro 有 context 的 deadline 时用它代替每次请求的超时，request_timeout 不会生效
+	if c.transport != transportGRPC && c.timeout > 0 && c.requestTimeout > 0 {
+		return nil, fmt.Errorf("timeout and request_timeout cannot be combined on the micro transport, the timeout applies to every request")
+	}
+
+	if len(c.endpoint) == 0 {
+		return nil, fmt.Errorf("invalid endpoint")
+	}
+	// gRPC 方式可以只给地址，不需要服务在 registry 中
+	if len(c.service) == 0 && c.transport != transportGRPC {
+		return nil, fmt.Errorf("invalid service")
+	}
+	return c, nil
+}
+
+// callError 把 client 返回的错误转成 go-micro 的错误格式
+func callError(err error) *errors.Error {
+	ce := errors.Parse(err.Error())
+	if ce.Code == 0 {
+		// assuming it's totally screwed
+		ce.Code = 500
+		ce.Id = "go.micro.rpc"
+		ce.Status = http.StatusText(500)
+		ce.Detail = "error during request: " + ce.Detail
+	}
+	return ce
+}
+
+func badRequestError(detail string) *errors.Error {
+	return &errors.Error{Id: "go.micro.rpc", Code: 400, Detail: detail, Status: http.StatusText(400)}
+}
+
+// do 发出调用并记下耗时，ctx 取消时调用结束，r 用来取 HTTP header 作为 metadata
+func (c *rpcCall) do(ctx context.Context, r *http.Request) *rpcResult {
+	start := time.Now()
+	res := c.call(ctx, r)
+	res.latency = time.Since(start)
+	return res
+}
+
+func (c *rpcCall) call(ctx context.Context, r *http.Request) *rpcResult {
+	if c.transport == transportGRPC {
+		return grpcDo(ctx, r, c)
+	}
+
+	if c.strict {
+		if errs := validateEndpointRequest(c.service, c.endpoint, c.request); len(errs) > 0 {
+			return &rpcResult{err: badRequestError(strings.Join(errs, "; "))}
+		}
+	}
+
+	// create request
+	cl := defaultClient()
+	req := cl.NewRequest(c.service, c.endpoint, c.request, client.WithContentType("application/json"))
+
+	// create context
+	ctx = requestToContext(ctx, r, c.service, c.metadata)
+	// 设置了 timeout 时 go-micro 以 context 的 deadline 作为每次请求的超时
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	result := new(rpcResult)
+	result.timing.connect = -1
+	if b, err := json.Marshal(c.request); err == nil {
+		result.timing.requestBytes = len(b)
+	}
+	// 记录实际发往的节点、请求次数和耗时，第一次进来之前的时间花在选节点上
+	trace := &callTrace{start: time.Now()}
+	opts := []client.CallOption{
+		client.WithCallWrapper(func(cf client.CallFunc) client.CallFunc {
+			return func(ctx context.Context, node *registry.Node, req client.Request, rsp interface{}, opts client.CallOptions) error {
+				// 每次请求用自己的 buffer，超时后还在进行的请求不会写到返回的响应里
+				a := trace.begin(node)
+				var response json.RawMessage
+				err := cf(ctx, node, req, &response, opts)
+				trace.end(a, response)
+				return err
+			}
+		}),
+	}
+
+	if c.requestTimeout > 0 {
+		opts = append(opts, client.WithRequestTimeout(c.requestTimeout))
+	}
+	if c.retries >= 0 {
+		opts = append(opts, client.WithRetries(c.retries))
+	}
+
+	// remote call
+	if len(c.address) > 0 {
+		opts = append(opts, client.WithAddress(c.address))
+	}
+
+	// remote call
+	err := cl.Call(ctx, req, new(json.RawMessage), opts...)
+	response := trace.result(result)
+	if err != nil {
+		result.err = callError(err)
+		return result
+	}
+
+	b, _ := response.MarshalJSON()
+	result.timing.responseBytes = len(b)
+	result.response = b
+	return result
+}
+
+// callTrace 记录 go-micro 调用中的每次请求
+// 超时后 client 不等请求结束就返回，请求的 goroutine 还会写，所以都在锁内读写，Call 返回后只用 result 取出的副本
+type callTrace struct {
+	sync.Mutex
+	start    time.Time
+	attempts []*callAttempt
+}
+
+// callAttempt 是一次请求发往的节点、耗时和响应
+type callAttempt struct {
+	node     *registry.Node
+	start    time.Time
+	server   time.Duration
+	done     bool
+	response json.RawMessage
+}
+
+func (t *callTrace) begin(node *registry.Node) *callAttempt {
+	a := &callAttempt{node: node, start: time.Now()}
+	t.Lock()
+	t.attempts = append(t.attempts, a)
+	t.Unlock()
+	return a
+}
+
+func (t *callTrace) end(a *callAttempt, response json.RawMessage) {
+	t.Lock()
+	a.server = time.Since(a.start)
+	a.done = true
+	a.response = response
+	t.Unlock()
+}
+
+// result 把节点、请求次数和耗时复制到 res 中，返回最后一次请求的响应
+// 最后一次请求还没结束时（超时）耗时算到现在，没有响应
+func (t *callTrace) result(res *rpcResult) json.RawMessage {
+	t.Lock()
+	defer t.Unlock()
+	res.attempts = len(t.attempts)
+	if len(t.attempts) == 0 {
+		return nil
+	}
+	res.timing.selector = t.attempts[0].start.Sub(t.start)
+	last := t.attempts[len(t.attempts)-1]
+	res.node = last.node
+	if !last.done {
+		res.timing.server = time.Since(last.start)
+		return nil
+	}
+	res.timing.server = last.server
+	return last.response
+}
+
+// envelope 把响应和调用信息放在一起返回，成功和出错时都带 status、code、id、detail 和各阶段的耗时
+// 出错时 status、code、id、detail 取自 go-micro 的错误，gRPC 方式调用时另带 grpc_code
+func (res *rpcResult) envelope() jsonObject {
+	obj := jsonObject{}
+	if res.err != nil {
+		obj = append(obj,
+			jsonField{"status", res.err.Status},
+			jsonField{"code", res.err.Code},
+			jsonField{"id", res.err.Id},
+			jsonField{"detail", res.err.Detail},
+		)
+		if len(res.grpcCode) > 0 {
+			obj = append(obj, jsonField{"grpc_code", res.grpcCode})
+		}
+	} else {
+		obj = append(obj,
+			jsonField{"status", http.StatusText(http.StatusOK)},
+			jsonField{"code", http.StatusOK},
+			jsonField{"id", ""},
+			jsonField{"detail", ""},
+		)
+	}
+	obj = append(obj, jsonField{"latency_ms", durationMs(res.latency)}, jsonField{"timing", res.timing.object(res.latency)})
+	if res.node != nil {
+		obj = append(obj, jsonField{"node", jsonObject{{"id", res.node.Id}, {"address", res.node.Address}}})
+	} else {
+		obj = append(obj, jsonField{"node", nil})
+	}
+	obj = append(obj, jsonField{"attempts", res.attempts})
+	if res.err != nil {
+		return append(obj, jsonField{"error", res.err})
+	}
+	return append(obj, jsonField{"response", json.RawMessage(res.response)})
+}
+
+// object 给出 envelope 中的 timing，total 是整个调用的耗时
+func (t rpcTiming) object(total time.Duration) jsonObject {
+	var connect interface{}
+	if t.connect >= 0 {
+		connect = durationMs(t.connect)
+	}
+	return jsonObject{
+		{"selector_ms", durationMs(t.selector)},
+		{"connect_ms", connect},
+		{"server_ms", durationMs(t.server)},
+		{"total_ms", durationMs(total)},
+		{"request_bytes", t.requestBytes},
+		{"response_bytes", t.responseBytes},
+	}
+}
+
+// rpc 把 JSON 或表单格式的请求转发给服务
+func rpc(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "OPTIONS" {
+		return
+	}
+	defer r.Body.Close()
+
+	// response content type
+	w.Header().Set("Content-Type", "application/json")
+
+	c, err := parseRPCRequest(r)
+	if err != nil {
+		e := errors.BadRequest("go.micro.rpc", "%v", err)
+		w.WriteHeader(400)
+		w.Write([]byte(e.Error()))
+		return
+	}
+
+	res := c.do(r.Context(), r)
+	if res.node != nil {
+		w.Header().Set("X-Micro-Node", res.node.Address)
+	}
+	id, err := history.add(newHistoryEntry(c, res))
+	if err != nil {
+		log.Logf("error recording call history: %v", err)
+	}
+
+	var b []byte
+	if c.envelope {
+		// 只有 dashboard 显示用的 envelope 按 fieldNaming 改写字段名，直接调用 /rpc 时原样返回服务的响应
+		res.response = displayResult(c, res)
+		env := res.envelope()
+		if history != nil {
+			env = append(env, jsonField{"history", id})
+		}
+		b, _ = marshalJSON(env, "")
+	} else if res.err != nil {
+		b = []byte(res.err.Error())
+	} else {
+		b = res.response
+	}
+	if res.err != nil {
+		w.WriteHeader(int(res.err.Code))
+		w.Write(b)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	w.Write(b)
+}
+
+// validateEndpointRequest 按 registry 中 endpoint 的类型校验请求
+func validateEndpointRequest(service, endpoint string, request interface{}) []string {
+	s, err := defaultRegistry().GetService(service)
+	if err != nil {
+		return []string{"error looking up service: " + err.Error()}
+	}
+	if len(s) == 0 {
+		return []string{"unknown service " + service}
+	}
+	for _, ep := range s[0].Endpoints {
+		if ep.Name != endpoint {
+			continue
+		}
+		schema, _ := endpointSchema(catalog(s[0]), ep, fieldNaming).get("request")
+		return validateRequest(schema.(jsonObject), request)
+	}
+	return []string{"unknown endpoint " + endpoint}
+}
+
+// schemaCache 按 serviceKey、endpoint 和字段名的写法缓存响应的 JSON Schema，找不到 endpoint 时缓存 nil
+type schemaCache struct {
+	sync.RWMutex
+	schemas map[string]jsonObject
+}
+
+var responseSchemas = &schemaCache{schemas: make(map[string]jsonObject)}
+
+// responseSchema 返回 endpoint 响应的 JSON Schema，字段名按 naming 的写法，找不到时返回 nil
+func responseSchema(service, endpoint, naming string) jsonObject {
+	s, err := defaultRegistry().GetService(service)
+	if err != nil || len(s) == 0 {
+		return nil
+	}
+	key := serviceKey(s[0]) + "/" + endpoint + "/" + naming
+
+	responseSchemas.RLock()
+	schema, ok := responseSchemas.schemas[key]
+	responseSchemas.RUnlock()
+	if ok {
+		return schema
+	}
+
+	for _, ep := range s[0].Endpoints {
+		if ep.Name == endpoint {
+			v, _ := endpointSchema(catalog(s[0]), ep, naming).get("response")
+			schema = v.(jsonObject)
+			break
+		}
+	}
+
+	responseSchemas.Lock()
+	if len(responseSchemas.schemas) >= catalogCacheSize {
+		responseSchemas.schemas = make(map[string]jsonObject)
+	}
+	responseSchemas.schemas[key] = schema
+	responseSchemas.Unlock()
+	return schema
+}
@@ -0,0 +1,196 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/errors"
+	"github.com/micro/go-micro/registry"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCallTrace(t *testing.T) {
+	first := &registry.Node{Id: "first", Address: "127.0.0.1:9090"}
+	second := &registry.Node{Id: "second", Address: "127.0.0.1:9091"}
+
+	tests := []struct {
+		name     string
+		run      func(trace *callTrace)
+		node     *registry.Node
+		attempts int
+		response string
+	}{
+		{name: "no attempts", run: func(trace *callTrace) {}},
+		{name: "one attempt", run: func(trace *callTrace) {
+			trace.end(trace.begin(first), json.RawMessage(`{"id":"1"}`))
+		}, node: first, attempts: 1, response: `{"id":"1"}`},
+		{name: "retried", run: func(trace *callTrace) {
+			trace.end(trace.begin(first), nil)
+			trace.end(trace.begin(second), json.RawMessage(`{"id":"2"}`))
+		}, node: second, attempts: 2, response: `{"id":"2"}`},
+		// 超时后最后一次请求还没结束，没有响应
+		{name: "timed out", run: func(trace *callTrace) {
+			trace.end(trace.begin(first), nil)
+			trace.begin(second)
+		}, node: second, attempts: 2},
+	}
+	for _, tt := range tests {
+		trace := &callTrace{start: time.Now()}
+		tt.run(trace)
+		res := new(rpcResult)
+		response := trace.result(res)
+		if res.node != tt.node || res.attempts != tt.attempts || string(response) != tt.response {
+			t.Errorf("%s: expected node %v, %d attempts and %q, got %v, %d and %q", tt.name, tt.node, tt.attempts, tt.response, res.node, res.attempts, response)
+		}
+	}
+}
+
+// Call 超时返回后，请求的 goroutine 还在写，取出的结果不再变
+func TestCallTraceAfterTimeout(t *testing.T) {
+	trace := &callTrace{start: time.Now()}
+	a := trace.begin(&registry.Node{Id: "slow"})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		trace.end(a, json.RawMessage(`{}`))
+		trace.begin(&registry.Node{Id: "retry"})
+	}()
+	res := new(rpcResult)
+	trace.result(res)
+	node, attempts := res.node, res.attempts
+	wg.Wait()
+	if res.node != node || res.attempts != attempts {
+		t.Fatalf("result changed after the call returned: %v, %d", res.node, res.attempts)
+	}
+}
+
+func TestRPCEnvelope(t *testing.T) {
+	node := &registry.Node{Id: "users-1", Address: "127.0.0.1:9090"}
+	timing := `"timing":{"selector_ms":0,"connect_ms":null,"server_ms":0,"total_ms":2,"request_bytes":0,"response_bytes":0}`
+	tests := []struct {
+		name string
+		res  *rpcResult
+		want string
+	}{
+		{
+			name: "response",
+			res:  &rpcResult{response: []byte(`{"id":"u-1"}`), node: node, attempts: 1, latency: 2 * time.Millisecond, timing: rpcTiming{connect: -1}},
+			want: `{"status":"OK","code":200,"id":"","detail":"","latency_ms":2,` + timing + `,"node":{"id":"users-1","address":"127.0.0.1:9090"},"attempts":1,"response":{"id":"u-1"}}`,
+		},
+		{
+			name: "micro error",
+			res:  &rpcResult{err: errors.NotFound("go.micro.srv.users", "user not found").(*errors.Error), node: node, attempts: 2, latency: 2 * time.Millisecond, timing: rpcTiming{connect: -1}},
+			want: `{"status":"Not Found","code":404,"id":"go.micro.srv.users","detail":"user not found","latency_ms":2,` + timing + `,"node":{"id":"users-1","address":"127.0.0.1:9090"},"attempts":2,` +
+				`"error":{"id":"go.micro.srv.users","code":404,"detail":"user not found","status":"Not Found"}}`,
+		},
+		// 没有选出节点时 node 为 null
+		{
+			name: "grpc error",
+			res:  &rpcResult{err: grpcError("users.Users", status.Error(codes.PermissionDenied, "no access")), grpcCode: "PermissionDenied", latency: 2 * time.Millisecond, timing: rpcTiming{connect: -1}},
+			want: `{"status":"PermissionDenied","code":403,"id":"users.Users","detail":"no access","grpc_code":"PermissionDenied","latency_ms":2,` + timing + `,"node":null,"attempts":0,` +
+				`"error":{"id":"users.Users","code":403,"detail":"no access","status":"PermissionDenied"}}`,
+		},
+	}
+	for _, tt := range tests {
+		if got := compactJSON(t, tt.res.envelope()); got != tt.want {
+			t.Errorf("%s: expected\n%s\ngot\n%s", tt.name, tt.want, got)
+		}
+	}
+}
+
+// 调用出错时 /rpc 的 HTTP 状态码和错误的 code 相同，envelope 中带上错误
+func TestRPCEnvelopeError(t *testing.T) {
+	_, _, reset := useMemoryRegistry()
+	defer reset()
+	m := startTestMock(t, testMock)
+	defer stopTestMock(m)
+
+	tests := []struct {
+		envelope string
+		code     int
+		want     string
+	}{
+		{envelope: "true", code: 404, want: `"detail":"user not found"`},
+		{envelope: "true", code: 404, want: `"attempts":1,"error":{`},
+		{envelope: "false", code: 404, want: `{"id":"go.micro.srv.mock","code":404,"detail":"user not found","status":"Not Found"}`},
+	}
+	for _, tt := range tests {
+		form := url.Values{
+			"service":  {m.Service},
+			"endpoint": {"User.Get"},
+			"request":  {`{"id": "missing"}`},
+			"envelope": {tt.envelope},
+		}
+		r := httptest.NewRequest("POST", "/rpc", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		rpc(w, r)
+		if w.Code != tt.code || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("envelope %s: expected %d with %s, got %d %s", tt.envelope, tt.code, tt.want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRPCTiming(t *testing.T) {
+	tests := []struct {
+		name   string
+		timing rpcTiming
+		total  time.Duration
+		want   string
+	}{
+		{
+			name:   "grpc",
+			timing: rpcTiming{selector: 1500 * time.Microsecond, connect: 2 * time.Millisecond, server: 10 * time.Millisecond, requestBytes: 12, responseBytes: 345},
+			total:  14 * time.Millisecond,
+			want:   `{"selector_ms":1.5,"connect_ms":2,"server_ms":10,"total_ms":14,"request_bytes":12,"response_bytes":345}`,
+		},
+		// go-micro 方式测不到连接的耗时
+		{
+			name:   "micro",
+			timing: rpcTiming{selector: time.Millisecond, connect: -1, server: 3 * time.Millisecond, requestBytes: 2},
+			total:  4 * time.Millisecond,
+			want:   `{"selector_ms":1,"connect_ms":null,"server_ms":3,"total_ms":4,"request_bytes":2,"response_bytes":0}`,
+		},
+		{
+			name:   "connect under a millisecond",
+			timing: rpcTiming{connect: 0},
+			want:   `{"selector_ms":0,"connect_ms":0,"server_ms":0,"total_ms":0,"request_bytes":0,"response_bytes":0}`,
+		},
+	}
+	for _, tt := range tests {
+		if got := compactJSON(t, tt.timing.object(tt.total)); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+// go-micro 方式调用 mock 时记下请求和响应的大小，各阶段的耗时不超过总耗时
+func TestRPCCallTiming(t *testing.T) {
+	_, _, reset := useMemoryRegistry()
+	defer reset()
+	m := startTestMock(t, testMock)
+	defer stopTestMock(m)
+
+	c := &rpcCall{service: m.Service, endpoint: "User.Get", request: map[string]interface{}{"id": "u-1", "age": 30}}
+	res := c.do(context.Background(), nil)
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	if res.timing.requestBytes != len(`{"age":30,"id":"u-1"}`) || res.timing.responseBytes != len(res.response) {
+		t.Errorf("expected the JSON sizes, got %d and %d for %s", res.timing.requestBytes, res.timing.responseBytes, res.response)
+	}
+	if res.timing.connect != -1 {
+		t.Errorf("expected no connect time for the micro transport, got %s", res.timing.connect)
+	}
+	if res.timing.selector < 0 || res.timing.server <= 0 || res.timing.selector+res.timing.server > res.latency {
+		t.Errorf("expected selector %s and server %s within the total %s", res.timing.selector, res.timing.server, res.latency)
+	}
+}
@@ -0,0 +1,432 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/micro/go-micro/errors"
+)
+
+// 步骤的结果
+const (
+	scenarioPass = "pass"
+	scenarioFail = "fail"
+	scenarioSkip = "skip"
+)
+
+// scenario 是按顺序执行的一组调用，前面步骤的响应中取出的值可以用在后面的请求中
+type scenario struct {
+	Name string `json:"name"`
+	// 替换 {{env.xxx}} 的环境，运行时可以指定其他环境
+	Env string `json:"env,omitempty"`
+	// 变量的初始值，请求中写成 {{vars.xxx}}
+	Vars  map[string]interface{} `json:"vars,omitempty"`
+	Steps []*scenarioStep        `json:"steps"`
+}
+
+// scenarioStep 是场景中的一次调用，请求的写法和 /rpc 相同
+type scenarioStep struct {
+	Name string `json:"name"`
+	rpcRequest
+	// 从响应中取值保存为变量，如 {"user_id": "$.user.id"}
+	Extract map[string]string `json:"extract,omitempty"`
+	Assert  []*scenarioAssert `json:"assert,omitempty"`
+	// 为 true 时调用应该返回错误，extract 和 assert 使用错误的内容，如 $.code
+	ExpectError bool `json:"expect_error,omitempty"`
+}
+
+// scenarioAssert 检查响应中 path 处的值，path 为空时检查整个响应
+type scenarioAssert struct {
+	Path string `json:"path"`
+	// 任意 JSON 值，可以使用占位符
+	Equals   json.RawMessage `json:"equals,omitempty"`
+	Contains string          `json:"contains,omitempty"`
+	Matches  string          `json:"matches,omitempty"`
+	Exists   *bool           `json:"exists,omitempty"`
+}
+
+// scenarioResult 是场景运行的结果
+type scenarioResult struct {
+	Name      string                `json:"name"`
+	Env       string                `json:"env,omitempty"`
+	Passed    bool                  `json:"passed"`
+	Steps     []*scenarioStepResult `json:"steps"`
+	LatencyMs float64               `json:"latency_ms"`
+}
+
+type scenarioStepResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// 替换占位符后实际发送的请求
+	Request   interface{}            `json:"request,omitempty"`
+	Response  json.RawMessage        `json:"response,omitempty"`
+	Error     *errors.Error          `json:"error,omitempty"`
+	Vars      map[string]interface{} `json:"vars,omitempty"`
+	Failures  []string               `json:"failures,omitempty"`
+	LatencyMs float64                `json:"latency_ms"`
+	Node      string                 `json:"node,omitempty"`
+}
+
+// parseScenario 解析 JSON 或 YAML 格式的场景
+func parseScenario(b []byte) (*scenario, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] != '{' {
+		var err error
+		if b, err = yaml.YAMLToJSON(b); err != nil {
+			return nil, err
+		}
+	}
+	sc := new(scenario)
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(sc); err != nil {
+		return nil, err
+	}
+	if len(sc.Steps) == 0 {
+		return nil, fmt.Errorf("scenario has no steps")
+	}
+	for i, step := range sc.Steps {
+		if len(step.Name) == 0 {
+			step.Name = fmt.Sprintf("step %d", i+1)
+		}
+	}
+	return sc, nil
+}
+
+// run 按顺序执行步骤，有步骤失败时后面的步骤不再执行
+// env 不为空时代替场景中的环境，r 为 nil 时（命令行中运行）不带 HTTP header
+func (sc *scenario) run(r *http.Request, env string) *scenarioResult {
+	start := time.Now()
+	if len(env) == 0 {
+		env = sc.Env
+	}
+	result := &scenarioResult{Name: sc.Name, Env: env, Passed: true}
+	for _, step := range sc.Steps {
+		result.Steps = append(result.Steps, &scenarioStepResult{Name: step.Name, Status: scenarioSkip})
+	}
+
+	x, err := newExpander(env)
+	if err != nil {
+		result.Passed = false
+		result.Steps[0].Status = scenarioFail
+		result.Steps[0].Failures = []string{err.Error()}
+		return result
+	}
+	x.values = make(map[string]interface{})
+	for k, v := range sc.Vars {
+		x.values[k] = v
+	}
+
+	for i, step := range sc.Steps {
+		sr := result.Steps[i]
+		step.run(r, x, sr)
+		if sr.Status != scenarioPass {
+			result.Passed = false
+			break
+		}
+	}
+	result.LatencyMs = float64(time.Since(start)) / float64(time.Millisecond)
+	return result
+}
+
+func (s *scenarioStep) run(r *http.Request, x *expander, sr *scenarioStepResult) {
+	sr.Status = scenarioFail
+	c, err := s.newCall(x)
+	if err != nil {
+		sr.Failures = []string{err.Error()}
+		return
+	}
+	sr.Request = c.request
+
+	res := c.do(requestContext(r), r)
+	history.add(newHistoryEntry(c, res))
+	sr.LatencyMs = float64(res.latency) / float64(time.Millisecond)
+	if res.node != nil {
+		sr.Node = res.node.Address
+	}
+
+	// 错误按 JSON 对象处理，可以检查 code 和 detail
+	var doc interface{}
+	b := res.response
+	if res.err != nil {
+		sr.Error = res.err
+		if !s.ExpectError {
+			sr.Failures = []string{"call failed: " + res.err.Error()}
+			return
+		}
+		b, _ = json.Marshal(res.err)
+	} else {
+		// 断言和取值的路径使用页面上显示的字段名
+		b = displayResult(c, res)
+		sr.Response = b
+		if s.ExpectError {
+			sr.Failures = []string{"expected an error, the call succeeded"}
+			return
+		}
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		sr.Failures = []string{"error decoding response: " + err.Error()}
+		return
+	}
+
+	names := make([]string, 0, len(s.Extract))
+	for name := range s.Extract {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v, err := jsonPath(doc, s.Extract[name])
+		if err != nil {
+			sr.Failures = append(sr.Failures, fmt.Sprintf("extract %s: %v", name, err))
+			continue
+		}
+		if sr.Vars == nil {
+			sr.Vars = make(map[string]interface{})
+		}
+		sr.Vars[name] = v
+		x.values[name] = v
+	}
+	for _, a := range s.Assert {
+		sr.Failures = append(sr.Failures, a.check(x, doc)...)
+	}
+	if len(sr.Failures) == 0 {
+		sr.Status = scenarioPass
+	}
+}
+
+// check 返回没有通过的原因，通过时为空
+func (a *scenarioAssert) check(x *expander, doc interface{}) []string {
+	path := a.Path
+	if len(path) == 0 {
+		path = "$"
+	}
+	v, err := jsonPath(doc, path)
+	if a.Exists != nil {
+		if *a.Exists && err != nil {
+			return []string{err.Error()}
+		}
+		if !*a.Exists && err == nil {
+			return []string{fmt.Sprintf("%s: expected not to exist, got %s", path, jsonText(v))}
+		}
+	}
+	if err != nil {
+		if a.Exists != nil {
+			return nil
+		}
+		return []string{err.Error()}
+	}
+
+	var failures []string
+	if len(a.Equals) > 0 {
+		want, err := x.request(string(a.Equals))
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", path, err))
+		} else if !jsonEqual(want, v) {
+			failures = append(failures, fmt.Sprintf("%s: expected %s, got %s", path, jsonText(want), jsonText(v)))
+		}
+	}
+	if len(a.Contains) > 0 {
+		want, err := x.expandString(a.Contains)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", path, err))
+		} else if !strings.Contains(placeholderString(v), want) {
+			failures = append(failures, fmt.Sprintf("%s: expected to contain %q, got %s", path, want, jsonText(v)))
+		}
+	}
+	if len(a.Matches) > 0 {
+		re, err := regexp.Compile(a.Matches)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", path, err))
+		} else if !re.MatchString(placeholderString(v)) {
+			failures = append(failures, fmt.Sprintf("%s: expected to match %q, got %s", path, a.Matches, jsonText(v)))
+		}
+	}
+	return failures
+}
+
+// jsonPath 按 JSONPath 取值，支持 $.a.b、$.a[0]、$['a'] 和负数下标
+func jsonPath(doc interface{}, path string) (interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("%s: path must start with $", path)
+	}
+	v := doc
+	p := path[1:]
+	for len(p) > 0 {
+		done := path[:len(path)-len(p)]
+		key, index, isIndex := "", 0, false
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			key, p = p[:end], p[end:]
+			if len(key) == 0 {
+				return nil, fmt.Errorf("%s: invalid path", path)
+			}
+		case '[':
+			end := strings.Index(p, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("%s: missing ]", path)
+			}
+			sel := p[1:end]
+			p = p[end+1:]
+			if len(sel) >= 2 && (sel[0] == '\'' || sel[0] == '"') && sel[len(sel)-1] == sel[0] {
+				key = sel[1 : len(sel)-1]
+				break
+			}
+			n, err := strconv.Atoi(sel)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid index %q", path, sel)
+			}
+			index, isIndex = n, true
+		default:
+			return nil, fmt.Errorf("%s: invalid path", path)
+		}
+
+		if isIndex {
+			a, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: not an array", done)
+			}
+			if index < 0 {
+				index += len(a)
+			}
+			if index < 0 || index >= len(a) {
+				return nil, fmt.Errorf("%s: index %d out of range", done, index)
+			}
+			v = a[index]
+			continue
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: not an object", done)
+		}
+		if v, ok = m[key]; !ok {
+			return nil, fmt.Errorf("%s: no field %q", done, key)
+		}
+	}
+	return v, nil
+}
+
+// jsonEqual 按 JSON 的值比较，数字 1 和 1.0 相等
+func jsonEqual(a, b interface{}) bool {
+	var x, y interface{}
+	ba, _ := json.Marshal(a)
+	bb, _ := json.Marshal(b)
+	if json.Unmarshal(ba, &x) != nil || json.Unmarshal(bb, &y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+func jsonText(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// print 以文本格式输出结果
+func (res *scenarioResult) print(w io.Writer) {
+	status := "PASS"
+	if !res.Passed {
+		status = "FAIL"
+	}
+	fmt.Fprintf(w, "%s %s (%.1fms)\n", status, res.Name, res.LatencyMs)
+	for _, s := range res.Steps {
+		fmt.Fprintf(w, "  %-4s %s", strings.ToUpper(s.Status), s.Name)
+		if s.Status != scenarioSkip {
+			fmt.Fprintf(w, " (%.1fms)", s.LatencyMs)
+		}
+		fmt.Fprintln(w)
+		for _, f := range s.Failures {
+			fmt.Fprintf(w, "       %s\n", f)
+		}
+	}
+}
+
+// runScenarioFiles 在命令行中运行文件中的场景，有场景失败时返回错误
+func runScenarioFiles(files []string, env string, asJSON bool, w io.Writer) error {
+	if len(files) == 0 {
+		return fmt.Errorf("no scenario files")
+	}
+	results := []*scenarioResult{}
+	failed := 0
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		sc, err := parseScenario(b)
+		if err != nil {
+			return fmt.Errorf("load %s: %v", file, err)
+		}
+		if len(sc.Name) == 0 {
+			sc.Name = file
+		}
+		res := sc.run(nil, env)
+		if !res.Passed {
+			failed++
+		}
+		if asJSON {
+			results = append(results, res)
+		} else {
+			res.print(w)
+		}
+	}
+	if asJSON {
+		b, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(b))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d scenario(s) failed", failed, len(files))
+	}
+	return nil
+}
+
+// scenarioHandler 显示场景页面，POST 运行场景并返回每一步的结果
+// POST /scenarios?env=staging
+func scenarioHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		render(w, r, scenarioTemplate, nil)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "ParseForm err:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	sc, err := parseScenario(b)
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	b, err = json.Marshal(sc.run(r, r.Form.Get("env")))
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
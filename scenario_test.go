@@ -0,0 +1,155 @@
+package web
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONPath(t *testing.T) {
+	var doc interface{}
+	d := json.NewDecoder(strings.NewReader(`{"user": {"id": "u-1", "tags": ["a", "b", "c"]}, "x-trace": "t-1", "items": [{"n": 1}, {"n": 2}]}`))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want string
+		err  string
+	}{
+		{path: "$", want: `{"items":[{"n":1},{"n":2}],"user":{"id":"u-1","tags":["a","b","c"]},"x-trace":"t-1"}`},
+		{path: "$.user.id", want: `"u-1"`},
+		{path: "$.user.tags[1]", want: `"b"`},
+		{path: "$.user.tags[-1]", want: `"c"`},
+		{path: "$.items[1].n", want: `2`},
+		{path: "$['x-trace']", want: `"t-1"`},
+		{path: `$["user"]["id"]`, want: `"u-1"`},
+		{path: "user.id", err: "must start with $"},
+		{path: "$.user.name", err: `$.user: no field "name"`},
+		{path: "$.user.tags[3]", err: "$.user.tags: index 3 out of range"},
+		{path: "$.user.tags[-4]", err: "out of range"},
+		{path: "$.user[0]", err: "$.user: not an array"},
+		{path: "$.user.id.value", err: "$.user.id: not an object"},
+		{path: "$.items[x]", err: `invalid index "x"`},
+		{path: "$.items[0", err: "missing ]"},
+		{path: "$..id", err: "invalid path"},
+	}
+	for _, tt := range tests {
+		v, err := jsonPath(doc, tt.path)
+		if len(tt.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: expected error %q, got %v", tt.path, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.path, err)
+			continue
+		}
+		if got := compactJSON(t, v); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestScenarioAssert(t *testing.T) {
+	var doc interface{}
+	d := json.NewDecoder(strings.NewReader(`{"id": "u-1", "age": 30, "name": "zhouxiaojun"}`))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	yes, no := true, false
+	tests := []struct {
+		name   string
+		assert *scenarioAssert
+		fail   string
+	}{
+		{name: "equals", assert: &scenarioAssert{Path: "$.age", Equals: json.RawMessage(`30`)}},
+		{name: "equals var", assert: &scenarioAssert{Path: "$.id", Equals: json.RawMessage(`"{{vars.id}}"`)}},
+		{name: "not equal", assert: &scenarioAssert{Path: "$.age", Equals: json.RawMessage(`31`)}, fail: "$.age: expected 31, got 30"},
+		{name: "contains", assert: &scenarioAssert{Path: "$.name", Contains: "xiao"}},
+		{name: "contains number", assert: &scenarioAssert{Path: "$.age", Contains: "3"}},
+		{name: "not contained", assert: &scenarioAssert{Path: "$.name", Contains: "li"}, fail: `expected to contain "li"`},
+		{name: "matches", assert: &scenarioAssert{Path: "$.id", Matches: `^u-\d+$`}},
+		{name: "invalid regexp", assert: &scenarioAssert{Path: "$.id", Matches: `(`}, fail: "missing closing )"},
+		{name: "exists", assert: &scenarioAssert{Path: "$.id", Exists: &yes}},
+		{name: "missing", assert: &scenarioAssert{Path: "$.email", Exists: &yes}, fail: `no field "email"`},
+		{name: "not exists", assert: &scenarioAssert{Path: "$.email", Exists: &no}},
+		{name: "unexpected field", assert: &scenarioAssert{Path: "$.id", Exists: &no}, fail: `$.id: expected not to exist, got "u-1"`},
+		{name: "whole response", assert: &scenarioAssert{Equals: json.RawMessage(`{"name": "zhouxiaojun", "id": "u-1", "age": 30}`)}},
+	}
+	x := &expander{values: map[string]interface{}{"id": "u-1"}}
+	for _, tt := range tests {
+		failures := tt.assert.check(x, doc)
+		if len(tt.fail) == 0 {
+			if len(failures) > 0 {
+				t.Errorf("%s: unexpected failures %q", tt.name, failures)
+			}
+			continue
+		}
+		if len(failures) != 1 || !strings.Contains(failures[0], tt.fail) {
+			t.Errorf("%s: expected failure %q, got %q", tt.name, tt.fail, failures)
+		}
+	}
+}
+
+// 前一步取出的值用在后一步的请求和断言中，失败的步骤之后的步骤跳过
+func TestScenarioRun(t *testing.T) {
+	_, _, reset := useMemoryRegistry()
+	defer reset()
+	m := startTestMock(t, testMock)
+	defer stopTestMock(m)
+
+	sc, err := parseScenario([]byte(`
+name: users
+vars:
+  id: u-7
+steps:
+  - name: get
+    service: go.micro.srv.mock
+    endpoint: User.Get
+    request: {"id": "{{vars.id}}", "age": 30}
+    extract: {user_id: $.id, age: $.age}
+  - name: get again
+    service: go.micro.srv.mock
+    endpoint: User.Get
+    request: {"id": "{{vars.user_id}}-copy", "age": "{{vars.age}}"}
+    assert:
+      - {path: $.id, equals: "{{vars.user_id}}-copy"}
+  - name: missing
+    service: go.micro.srv.mock
+    endpoint: User.Get
+    request: {"id": "missing"}
+    expect_error: true
+    assert:
+      - {path: $.code, equals: 404}
+  - name: fails
+    service: go.micro.srv.mock
+    endpoint: User.Get
+    request: {"id": "missing"}
+  - service: go.micro.srv.mock
+    endpoint: User.Get
+    request: {}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := sc.run(nil, "")
+	if result.Passed {
+		t.Fatal("expected the scenario to fail")
+	}
+	want := []string{scenarioPass, scenarioPass, scenarioPass, scenarioFail, scenarioSkip}
+	for i, step := range result.Steps {
+		if step.Status != want[i] {
+			t.Errorf("%s: expected %s, got %s %q", step.Name, want[i], step.Status, step.Failures)
+		}
+	}
+	if got := compactJSON(t, result.Steps[0].Vars); got != `{"age":30,"user_id":"u-7"}` {
+		t.Errorf("expected the extracted vars, got %s", got)
+	}
+	if name := result.Steps[4].Name; name != "step 5" {
+		t.Errorf("expected a default step name, got %q", name)
+	}
+}
@@ -0,0 +1,287 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/micro/go-micro/registry"
+)
+
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// scalarSchemas 把 Go 的基本类型名对应到 JSON Schema
+// protojson 中 64 位整数写成字符串，两种写法都可以
+var scalarSchemas = map[string]jsonObject{
+	"string":  {{"type", "string"}},
+	"bool":    {{"type", "boolean"}},
+	"int32":   {{"type", "integer"}, {"format", "int32"}},
+	"int64":   {{"type", []string{"integer", "string"}}, {"format", "int64"}, {"pattern", "^-?[0-9]+$"}},
+	"uint8":   {{"type", "integer"}, {"minimum", 0}, {"maximum", 255}},
+	"uint32":  {{"type", "integer"}, {"format", "uint32"}, {"minimum", 0}},
+	"uint64":  {{"type", []string{"integer", "string"}}, {"format", "uint64"}, {"minimum", 0}, {"pattern", "^[0-9]+$"}},
+	"float32": {{"type", "number"}, {"format", "float"}},
+	"float64": {{"type", "number"}, {"format", "double"}},
+}
+
+// schemaBuilder 生成一个根 schema，嵌套的消息放在 $defs 中，自引用的消息也能表示
+type schemaBuilder struct {
+	catalog *typeCatalog
+	naming  string
+	defs    map[string]interface{}
+}
+
+// valueSchema 把 registry.Value 转成 JSON Schema，字段名按 naming 的写法
+func valueSchema(c *typeCatalog, v *registry.Value, naming string) jsonObject {
+	if c == nil {
+		c = newTypeCatalog(v)
+	}
+	b := &schemaBuilder{catalog: c, naming: naming, defs: make(map[string]interface{})}
+
+	root := jsonObject{{"$schema", schemaDraft}}
+	if v == nil {
+		return append(root, jsonField{"type", "object"})
+	}
+	root = append(root, jsonField{"title", v.Type})
+	root = append(root, b.object(v)...)
+	if len(b.defs) > 0 {
+		root = append(root, jsonField{"$defs", b.defs})
+	}
+	return root
+}
+
+func (b *schemaBuilder) object(v *registry.Value) jsonObject {
+	props := make(jsonObject, 0, len(v.Values))
+	for _, val := range v.Values {
+		props = append(props, jsonField{namedField(b.naming, val.Name), b.value(val)})
+	}
+	return jsonObject{
+		{"type", "object"},
+		{"properties", props},
+		{"additionalProperties", false},
+	}
+}
+
+func (b *schemaBuilder) value(v *registry.Value) interface{} {
+	if full, ok := b.catalog.wellKnown(v.Type, v); ok {
+		return wellKnownSchema(full)
+	}
+	if len(v.Values) > 0 {
+		return b.ref(v.Type, v)
+	}
+	return b.typeSchema(v.Type)
+}
+
+func (b *schemaBuilder) typeSchema(typ string) interface{} {
+	typ = strings.TrimPrefix(typ, "*")
+	if s, ok := scalarSchemas[typ]; ok {
+		return s
+	}
+	if isBytes(typ) {
+		return bytesSchema
+	}
+	if strings.HasPrefix(typ, "[]") {
+		return jsonObject{
+			{"type", "array"},
+			{"items", b.typeSchema(strings.TrimPrefix(typ, "[]"))},
+		}
+	}
+	if _, val, ok := mapType(typ); ok {
+		return jsonObject{
+			{"type", "object"},
+			{"additionalProperties", b.typeSchema(val)},
+		}
+	}
+	// extractor 给出的 map 字段没有类型名
+	if len(typ) == 0 {
+		return jsonObject{{"type", "object"}}
+	}
+	if full, ok := b.catalog.wellKnown(typ, nil); ok {
+		return wellKnownSchema(full)
+	}
+	if t, ok := b.catalog.types[typ]; ok {
+		return b.ref(typ, t)
+	}
+	// 不认识的类型，不做限制
+	return jsonObject{{"description", typ}}
+}
+
+// ref 把消息结构放进 $defs 并返回引用
+func (b *schemaBuilder) ref(name string, v *registry.Value) jsonObject {
+	if _, ok := b.defs[name]; !ok {
+		// 先占位，自引用时不会重复展开
+		b.defs[name] = nil
+		b.defs[name] = append(jsonObject{{"title", name}}, b.object(v)...)
+	}
+	return jsonObject{{"$ref", "#/$defs/" + name}}
+}
+
+// messageSchema 按 proto 描述生成 JSON Schema，字段名按 naming 的写法
+func (d *descriptorSet) messageSchema(name, naming string) jsonObject {
+	d.RLock()
+	defer d.RUnlock()
+	b := &protoSchema{set: d, naming: naming, defs: make(map[string]interface{})}
+
+	root := jsonObject{{"$schema", schemaDraft}, {"title", strings.TrimPrefix(name, ".")}}
+	root = append(root, b.object(name)...)
+	if len(b.defs) > 0 {
+		root = append(root, jsonField{"$defs", b.defs})
+	}
+	return root
+}
+
+type protoSchema struct {
+	set    *descriptorSet
+	naming string
+	defs   map[string]interface{}
+}
+
+func (b *protoSchema) object(name string) jsonObject {
+	m, ok := b.set.messages[name]
+	if !ok {
+		return jsonObject{{"type", "object"}}
+	}
+	props := make(jsonObject, 0, len(m.Field))
+	for _, f := range m.Field {
+		props = append(props, jsonField{namedProtoField(b.naming, f), b.field(f)})
+	}
+	return jsonObject{
+		{"type", "object"},
+		{"properties", props},
+		{"additionalProperties", false},
+	}
+}
+
+func (b *protoSchema) field(f *descriptor.FieldDescriptorProto) interface{} {
+	if f.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		return b.single(f)
+	}
+	if entry, ok := b.set.messages[f.GetTypeName()]; ok && entry.GetOptions().GetMapEntry() {
+		for _, ef := range entry.Field {
+			if ef.GetNumber() == 2 {
+				return jsonObject{
+					{"type", "object"},
+					{"additionalProperties", b.single(ef)},
+				}
+			}
+		}
+		return jsonObject{{"type", "object"}}
+	}
+	return jsonObject{
+		{"type", "array"},
+		{"items", b.single(f)},
+	}
+}
+
+func (b *protoSchema) single(f *descriptor.FieldDescriptorProto) interface{} {
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+		if full, ok := wellKnownType(f.GetTypeName()); ok {
+			return wellKnownSchema(full)
+		}
+		name := strings.TrimPrefix(f.GetTypeName(), ".")
+		if _, ok := b.defs[name]; !ok {
+			b.defs[name] = nil
+			b.defs[name] = append(jsonObject{{"title", name}}, b.object(f.GetTypeName())...)
+		}
+		return jsonObject{{"$ref", "#/$defs/" + name}}
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		// 枚举可以写名字也可以写数字
+		name := jsonObject{{"type", "string"}}
+		if e, ok := b.set.enums[f.GetTypeName()]; ok && len(e.Value) > 0 {
+			var names []string
+			for _, v := range e.Value {
+				names = append(names, v.GetName())
+			}
+			name = append(name, jsonField{"enum", names})
+		}
+		return jsonObject{{"oneOf", []jsonObject{
+			name,
+			{{"type", "integer"}, {"format", "int32"}},
+		}}}
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return bytesSchema
+	}
+	if s, ok := scalarSchemas[scalarTypes[f.GetType()]]; ok {
+		return s
+	}
+	return jsonObject{}
+}
+
+// endpointSchema 生成 endpoint 请求和响应的 JSON Schema，有 proto 描述时以描述为准
+func endpointSchema(c *typeCatalog, ep *registry.Endpoint, naming string) jsonObject {
+	if m := descriptors.method(ep); m != nil {
+		return jsonObject{
+			{"request", descriptors.messageSchema(m.GetInputType(), naming)},
+			{"response", descriptors.messageSchema(m.GetOutputType(), naming)},
+		}
+	}
+	return jsonObject{
+		{"request", valueSchema(c, ep.Request, naming)},
+		{"response", valueSchema(c, ep.Response, naming)},
+	}
+}
+
+// serviceSchemas 按 endpoint 名生成服务所有 endpoint 的 JSON Schema
+func serviceSchemas(services []*registry.Service) map[string]interface{} {
+	schemas := make(map[string]interface{})
+	for _, s := range services {
+		c := catalog(s)
+		for _, ep := range s.Endpoints {
+			if _, ok := schemas[ep.Name]; !ok {
+				schemas[ep.Name] = endpointSchema(c, ep, fieldNaming)
+			}
+		}
+	}
+	return schemas
+}
+
+// schemaHandler 返回 endpoint 的 JSON Schema，不指定 endpoint 时返回服务所有 endpoint 的
+// GET /schema?service=go.micro.srv.greeter&endpoint=Say.Hello
+func schemaHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "ParseForm err:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	svc := r.Form.Get("service")
+	endpoint := r.Form.Get("endpoint")
+	if len(svc) == 0 {
+		http.Error(w, "Error occurred: service is required", http.StatusBadRequest)
+		return
+	}
+
+	s, err := defaultRegistry().GetService(svc)
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(s) == 0 {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	var rsp interface{}
+	if len(endpoint) == 0 {
+		rsp = serviceSchemas(s)
+	} else {
+		for _, ep := range s[0].Endpoints {
+			if ep.Name == endpoint {
+				rsp = endpointSchema(catalog(s[0]), ep, fieldNaming)
+				break
+			}
+		}
+	}
+	if rsp == nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	b, err := json.Marshal(rsp)
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
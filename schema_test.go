@@ -0,0 +1,135 @@
+package web
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMessageSchema(t *testing.T) {
+	d := testDescriptors()
+	int64Schema := `{"type":["integer","string"],"format":"int64","pattern":"^-?[0-9]+$"}`
+	tests := []struct {
+		name     string
+		naming   string
+		property string
+		want     string
+	}{
+		{name: "string", naming: namingProto, property: "id", want: `{"type":"string"}`},
+		{name: "bytes", naming: namingProto, property: "avatar", want: `{"type":"string","contentEncoding":"base64"}`},
+		{name: "map", naming: namingProto, property: "scores", want: `{"type":"object","additionalProperties":` + int64Schema + `}`},
+		{name: "timestamp", naming: namingProto, property: "created_at", want: `{"type":"string","format":"date-time"}`},
+		{name: "wrapper", naming: namingProto, property: "age", want: int64Schema},
+		{name: "enum", naming: namingProto, property: "status", want: `{"oneOf":[{"type":"string","enum":["ACTIVE","INACTIVE"]},{"type":"integer","format":"int32"}]}`},
+		{name: "repeated message", naming: namingProto, property: "groups", want: `{"type":"array","items":{"$ref":"#/$defs/test.Group"}}`},
+		{name: "camel", naming: namingCamel, property: "createdAt", want: `{"type":"string","format":"date-time"}`},
+		{name: "snake", naming: namingSnake, property: "created_at", want: `{"type":"string","format":"date-time"}`},
+	}
+	for _, tt := range tests {
+		props, _ := d.messageSchema(".test.User", tt.naming).get("properties")
+		p, ok := props.(jsonObject).get(tt.property)
+		if !ok {
+			t.Errorf("%s: no property %s in %s", tt.name, tt.property, compactJSON(t, props))
+			continue
+		}
+		if got := compactJSON(t, p); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestValueSchema(t *testing.T) {
+	clock := testService("go.micro.srv.clock", new(Clock))
+	int64Schema := `{"type":["integer","string"],"format":"int64","pattern":"^-?[0-9]+$"}`
+	want := `{"$schema":"` + schemaDraft + `","title":"ScheduleRequest","type":"object","properties":{` +
+		`"at":{"$ref":"#/$defs/Timestamp"},"payload":{"$ref":"#/$defs/Any"}},"additionalProperties":false,"$defs":{` +
+		`"Any":{"title":"Any","type":"object","properties":{"kind":{"type":"string"}},"additionalProperties":false},` +
+		`"Timestamp":{"title":"Timestamp","type":"object","properties":{"unix":` + int64Schema + `,"zone":{"type":"string"}},"additionalProperties":false}}}`
+	got := compactJSON(t, valueSchema(catalog(clock), testEndpoint(clock, "Clock.Schedule").Request, namingProto))
+	if got != want {
+		t.Fatalf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+// 互相引用和引用自己的消息都放进 $defs
+func TestSchemaRecursion(t *testing.T) {
+	graph := testService("go.micro.srv.graph", new(Graph))
+	walk := valueSchema(catalog(graph), testEndpoint(graph, "Graph.Walk").Request, namingProto)
+	user := testDescriptors().messageSchema(".test.User", namingProto)
+	int64Schema := `{"type":["integer","string"],"format":"int64","pattern":"^-?[0-9]+$"}`
+
+	tests := []struct {
+		name   string
+		schema jsonObject
+		def    string
+		want   string
+	}{
+		{name: "mutual recursion", schema: walk, def: "Node", want: `{"title":"Node","type":"object","properties":{"name":{"type":"string"},"edges":{"type":"array","items":{"$ref":"#/$defs/Edge"}}},"additionalProperties":false}`},
+		{name: "mutual recursion back", schema: walk, def: "Edge", want: `{"title":"Edge","type":"object","properties":{"weight":` + int64Schema + `,"to":{"$ref":"#/$defs/Node"}},"additionalProperties":false}`},
+		{name: "self recursion", schema: walk, def: "Tree", want: `{"title":"Tree","type":"object","properties":{"value":{"type":"string"},"children":{"type":"array","items":{"$ref":"#/$defs/Tree"}}},"additionalProperties":false}`},
+		{name: "proto mutual recursion", schema: user, def: "test.Group", want: `{"title":"test.Group","type":"object","properties":{"name":{"type":"string"},"members":{"type":"array","items":{"$ref":"#/$defs/test.User"}}},"additionalProperties":false}`},
+		// 被引用的根消息也要放进 $defs
+		{name: "proto root", schema: user, def: "test.User", want: `{"title":"test.User","type":"object","properties":{"id":{"type":"string"},"avatar":{"type":"string","contentEncoding":"base64"},"scores":{"type":"object","additionalProperties":` + int64Schema + `},"created_at":{"type":"string","format":"date-time"},"status":{"oneOf":[{"type":"string","enum":["ACTIVE","INACTIVE"]},{"type":"integer","format":"int32"}]},"groups":{"type":"array","items":{"$ref":"#/$defs/test.Group"}},"age":` + int64Schema + `},"additionalProperties":false}`},
+	}
+	for _, tt := range tests {
+		defs, _ := tt.schema.get("$defs")
+		def, ok := defs.(map[string]interface{})[tt.def]
+		if !ok {
+			t.Errorf("%s: no $defs/%s in %s", tt.name, tt.def, compactJSON(t, tt.schema))
+			continue
+		}
+		if got := compactJSON(t, def); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestValueSchemaNaming(t *testing.T) {
+	users := testService("go.micro.srv.users", new(Users))
+	get := testEndpoint(users, "Users.Get").Request
+	tests := []struct {
+		naming string
+		want   string
+	}{
+		{naming: namingProto, want: "id avatar scores created_at status groups age"},
+		{naming: namingCamel, want: "id avatar scores createdAt status groups age"},
+		{naming: namingSnake, want: "id avatar scores created_at status groups age"},
+	}
+	for _, tt := range tests {
+		props, _ := valueSchema(catalog(users), get, tt.naming).get("properties")
+		var keys []string
+		for _, p := range props.(jsonObject) {
+			keys = append(keys, p.Key)
+		}
+		if got := strings.Join(keys, " "); got != tt.want {
+			t.Errorf("%s: expected properties %s, got %s", tt.naming, tt.want, got)
+		}
+	}
+}
+
+func TestSchemaInt64AndEnum(t *testing.T) {
+	schema := testDescriptors().messageSchema(".test.User", namingProto)
+	tests := []struct {
+		name    string
+		request string
+		valid   bool
+	}{
+		{name: "number", request: `{"age": 30}`, valid: true},
+		{name: "string", request: `{"age": "30"}`, valid: true},
+		{name: "not a number", request: `{"age": "thirty"}`},
+		{name: "enum name", request: `{"status": "INACTIVE"}`, valid: true},
+		{name: "enum number", request: `{"status": 1}`, valid: true},
+		{name: "unknown enum name", request: `{"status": "DELETED"}`},
+	}
+	for _, tt := range tests {
+		var v interface{}
+		d := json.NewDecoder(strings.NewReader(tt.request))
+		d.UseNumber()
+		if err := d.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		if errs := validateRequest(schema, v); (len(errs) == 0) != tt.valid {
+			t.Errorf("%s: expected valid %v, got %q", tt.name, tt.valid, errs)
+		}
+	}
+}
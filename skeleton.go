@@ -0,0 +1,373 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/registry"
+)
+
+// repeated 字段示例中放几个元素
+const repeatedCount = 3
+
+var (
+	// 请求示例中消息嵌套的最大层数
+	maxDepth = 10
+	// 为 true 时截断的分支写成 null，否则写成 "<recursive User>" 这样的标记
+	truncateAsNull bool
+)
+
+// truncated 是因为自引用或层数过多而没有展开的消息
+func truncated(reason, typ string) interface{} {
+	if truncateAsNull {
+		return nil
+	}
+	return "<" + reason + " " + typ + ">"
+}
+
+// DefaultMap 是基本类型的示例值
+var DefaultMap = map[string]interface{}{
+	"string":  "zhouxiaojun",
+	"bool":    true,
+	"int32":   32,
+	"int64":   64,
+	"uint8":   8,
+	"uint32":  32,
+	"uint64":  64,
+	"float32": 32.0,
+	"float64": 64.0,
+
+	"google.protobuf.Timestamp": "2019-11-11T11:11:11Z",
+	"google.protobuf.Duration":  "1.5s",
+	"google.protobuf.Struct":    jsonObject{{"key", "value"}},
+	"google.protobuf.Value":     "value",
+	"google.protobuf.ListValue": []interface{}{"value"},
+	"google.protobuf.Any": jsonObject{
+		{"@type", "type.googleapis.com/google.protobuf.StringValue"},
+		{"value", "zhouxiaojun"},
+	},
+	"google.protobuf.Empty":     jsonObject{},
+	"google.protobuf.FieldMask": "name",
+}
+
+// jsonField 是 jsonObject 中的一个键值对
+type jsonField struct {
+	Key   string
+	Value interface{}
+}
+
+// jsonObject 是保持字段顺序的 JSON 对象，顺序与 registry 中的字段顺序一致
+type jsonObject []jsonField
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := marshalJSON(f.Key, "")
+		if err != nil {
+			return nil, err
+		}
+		v, err := marshalJSON(f.Value, "")
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// typeCatalog 记录一个服务里出现过的消息结构
+// registry 中 repeated 字段只带类型名（如 []User），需要从这里找回 User 的结构
+// 建好之后只读，可以在多个请求之间共享
+type typeCatalog struct {
+	types map[string]*registry.Value
+}
+
+// 最多缓存的服务版本数，超过时清空重建
+const catalogCacheSize = 256
+
+// catalogCache 按 serviceKey 缓存 typeCatalog
+type catalogCache struct {
+	sync.RWMutex
+	catalogs map[string]*typeCatalog
+}
+
+var catalogs = &catalogCache{catalogs: make(map[string]*typeCatalog)}
+
+// serviceKey 由服务名、版本和节点 id 组成
+// 以同一个版本（如 latest）重新部署后节点 id 会变，也不会用到旧的缓存
+func serviceKey(s *registry.Service) string {
+	ids := make([]string, 0, len(s.Nodes))
+	for _, n := range s.Nodes {
+		ids = append(ids, n.Id)
+	}
+	sort.Strings(ids)
+	h := fnv.New64a()
+	for _, id := range ids {
+		h.Write([]byte(id))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%s:%s:%x", s.Name, s.Version, h.Sum64())
+}
+
+// catalog 取得服务对应的 typeCatalog，没有就新建一个
+func catalog(s *registry.Service) *typeCatalog {
+	if s == nil {
+		return newTypeCatalog()
+	}
+	key := serviceKey(s)
+
+	catalogs.RLock()
+	c, ok := catalogs.catalogs[key]
+	catalogs.RUnlock()
+	if ok {
+		return c
+	}
+
+	var vals []*registry.Value
+	for _, ep := range s.Endpoints {
+		vals = append(vals, ep.Request, ep.Response)
+	}
+	c = newTypeCatalog(vals...)
+
+	catalogs.Lock()
+	if len(catalogs.catalogs) >= catalogCacheSize {
+		catalogs.catalogs = make(map[string]*typeCatalog)
+	}
+	catalogs.catalogs[key] = c
+	catalogs.Unlock()
+	return c
+}
+
+func newTypeCatalog(vals ...*registry.Value) *typeCatalog {
+	c := &typeCatalog{types: make(map[string]*registry.Value)}
+	for _, v := range vals {
+		c.learn(v)
+	}
+	return c
+}
+
+// learn 把 v 里所有嵌套的消息结构记下来，repeated 字段出现在前面时也能找到
+func (c *typeCatalog) learn(v *registry.Value) {
+	if v == nil {
+		return
+	}
+	for _, val := range v.Values {
+		if len(val.Values) == 0 {
+			continue
+		}
+		if _, ok := c.types[val.Type]; !ok {
+			c.types[val.Type] = val
+		}
+		c.learn(val)
+	}
+}
+
+// format 生成缩进后的请求示例，c 为空时只用 v 自身的结构
+func format(c *typeCatalog, v *registry.Value) string {
+	return marshalIndent(skeletonOf(c, v, nil))
+}
+
+// formatCompact 生成压缩成一行的请求示例
+func formatCompact(c *typeCatalog, v *registry.Value) string {
+	b, err := marshalJSON(skeletonOf(c, v, nil), "")
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+func marshalIndent(v interface{}) string {
+	b, err := marshalJSON(v, "\t")
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+// marshalJSON 和 json.Marshal 一样，但不转义 <、> 和 &，示例里的 "<recursive User>" 可以原样显示
+func marshalJSON(v interface{}, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// skeletonOf 把 registry.Value 转成一棵可以直接交给 encoding/json 的树
+// values 为空时使用默认的 profile
+func skeletonOf(c *typeCatalog, v *registry.Value, values valueSource) interface{} {
+	// 如果为空，或者Values为0，就返回个{}完事
+	if v == nil || len(v.Values) == 0 {
+		return jsonObject{}
+	}
+	if c == nil {
+		c = newTypeCatalog(v)
+	}
+	if values == nil {
+		values = defaultValues()
+	}
+	b := &skeletonBuilder{catalog: c, values: values, visiting: make(map[string]bool)}
+	return b.message(v.Type, v)
+}
+
+// defaultValues 返回默认 profile 的取值函数
+func defaultValues() valueSource {
+	return profiles.get(defaultProfile, "").values(0)
+}
+
+// skeletonBuilder 生成一次请求示例，catalog 只读，values 决定示例值
+type skeletonBuilder struct {
+	catalog *typeCatalog
+	values  valueSource
+	// 当前路径上正在展开的消息类型和层数，用来发现自引用
+	visiting map[string]bool
+	depth    int
+}
+
+// message 展开一个消息，自引用或超过 maxDepth 时截断
+func (b *skeletonBuilder) message(typ string, v *registry.Value) interface{} {
+	if len(typ) > 0 && b.visiting[typ] {
+		return truncated("recursive", typ)
+	}
+	if b.depth >= maxDepth {
+		return truncated("max depth", typ)
+	}
+	if len(typ) > 0 {
+		b.visiting[typ] = true
+		defer delete(b.visiting, typ)
+	}
+	b.depth++
+	defer func() { b.depth-- }()
+	return b.object(v)
+}
+
+func (b *skeletonBuilder) object(v *registry.Value) jsonObject {
+	obj := make(jsonObject, 0, len(v.Values))
+	for _, val := range v.Values {
+		name := fieldName(val.Name)
+		obj = append(obj, jsonField{
+			Key:   name,
+			Value: b.value(name, val),
+		})
+	}
+	return obj
+}
+
+func (b *skeletonBuilder) value(name string, v *registry.Value) interface{} {
+	// well-known type 在 registry 中也会展开成 seconds、nanos 之类的字段，要先认出来
+	if full, ok := b.catalog.wellKnown(v.Type, v); ok {
+		return wellKnownValue(b.values, name, full)
+	}
+	// 还有层在下面，是嵌套的消息
+	if len(v.Values) > 0 {
+		return b.message(v.Type, v)
+	}
+	return b.defaultValue(name, v.Type)
+}
+
+// defaultValue 按字段名和类型名给出示例值
+func (b *skeletonBuilder) defaultValue(name, typ string) interface{} {
+	typ = strings.TrimPrefix(typ, "*")
+	if isBytes(typ) {
+		return bytesValue(b.values, name)
+	}
+
+	// repeated 字段，类型形如 []User
+	if strings.HasPrefix(typ, "[]") {
+		list := make([]interface{}, repeatedCount)
+		for i := range list {
+			list[i] = b.defaultValue(name, strings.TrimPrefix(typ, "[]"))
+		}
+		return list
+	}
+
+	// map 字段，类型形如 map[string]*Job
+	if key, val, ok := mapType(typ); ok {
+		return jsonObject{{mapKey(b.values, name, key), b.defaultValue(name, val)}}
+	}
+	// go-micro 的 extractor 给 map 字段的类型名是空的，不知道键和值的类型
+	if len(typ) == 0 {
+		return jsonObject{}
+	}
+
+	if full, ok := b.catalog.wellKnown(typ, nil); ok {
+		return wellKnownValue(b.values, name, full)
+	}
+
+	if t, ok := b.catalog.types[typ]; ok {
+		return b.message(typ, t)
+	}
+
+	if d, ok := b.values(name, typ); ok {
+		return d
+	}
+
+	// 不认识的类型，原样写出类型名
+	return typ
+}
+
+// skeletonHandler 按 profile 生成 endpoint 的请求示例
+// GET /skeleton?service=go.micro.srv.greeter&endpoint=Say.Hello&profile=zero
+func skeletonHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "ParseForm err:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	svc := r.Form.Get("service")
+	endpoint := r.Form.Get("endpoint")
+	profile := r.Form.Get("profile")
+	if len(profile) == 0 {
+		profile = defaultProfile
+	}
+	// 没有指定 seed 时随机取一个，通过 X-Skeleton-Seed 返回以便复现
+	seed, err := strconv.ParseInt(r.Form.Get("seed"), 10, 64)
+	if err != nil {
+		seed = time.Now().UnixNano()
+	}
+
+	s, err := defaultRegistry().GetService(svc)
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(s) == 0 {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	for _, ep := range s[0].Endpoints {
+		if ep.Name != endpoint {
+			continue
+		}
+		values := profiles.get(profile, svc).values(seed)
+		b, err := marshalJSON(requestSkeleton(catalog(s[0]), ep, values), "\t")
+		if err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Skeleton-Seed", strconv.FormatInt(seed, 10))
+		w.Write(b)
+		return
+	}
+
+	http.Error(w, "Not found", http.StatusNotFound)
+}
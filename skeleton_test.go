@@ -0,0 +1,102 @@
+package web
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/micro/go-micro/registry"
+)
+
+// 自引用和互相引用的消息在第二次出现时截断
+func TestSkeletonRecursion(t *testing.T) {
+	graph := testService("go.micro.srv.graph", new(Graph))
+	team := profiles.get("team", "")
+	walk := skeletonOf(catalog(graph), testEndpoint(graph, "Graph.Walk").Request, team.values(0))
+	proto, _ := testDescriptors().skeleton(".test.User", team.values(0))
+
+	tests := []struct {
+		name     string
+		skeleton interface{}
+		path     string
+		want     string
+	}{
+		{name: "self recursion", skeleton: walk, path: "tree", want: `{"value":"zhouxiaojun","children":["<recursive Tree>","<recursive Tree>","<recursive Tree>"]}`},
+		{name: "mutual recursion", skeleton: walk, path: "node", want: `{"name":"zhouxiaojun","edges":[{"weight":64,"to":"<recursive Node>"},{"weight":64,"to":"<recursive Node>"},{"weight":64,"to":"<recursive Node>"}]}`},
+		{name: "mutual recursion from edge", skeleton: walk, path: "edge", want: `{"weight":64,"to":{"name":"zhouxiaojun","edges":["<recursive Edge>","<recursive Edge>","<recursive Edge>"]}}`},
+		{name: "proto mutual recursion", skeleton: proto, path: "groups", want: `[{"name":"zhouxiaojun","members":["<recursive User>","<recursive User>","<recursive User>"]},{"name":"zhouxiaojun","members":["<recursive User>","<recursive User>","<recursive User>"]},{"name":"zhouxiaojun","members":["<recursive User>","<recursive User>","<recursive User>"]}]`},
+	}
+	for _, tt := range tests {
+		v, ok := lookup(tt.skeleton, tt.path)
+		if !ok {
+			t.Errorf("%s: no %s in %s", tt.name, tt.path, compactJSON(t, tt.skeleton))
+			continue
+		}
+		if got := compactJSON(t, v); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestSkeletonEnumHints(t *testing.T) {
+	// groups[].members[] 是截断的 User，不再给出提示
+	_, hints := testDescriptors().skeleton(".test.User", nil)
+	if len(hints) != 1 || hints[0] != "status: ACTIVE | INACTIVE" {
+		t.Fatalf("expected one hint for status, got %q", hints)
+	}
+}
+
+// 同一个 catalog 和 descriptorSet 在多个请求之间共享，并发生成的结果要一致
+func TestSkeletonConcurrent(t *testing.T) {
+	users := testService("go.micro.srv.users", new(Users))
+	d := testDescriptors()
+	team := profiles.get("team", "")
+	render := func(message string) string {
+		if len(message) > 0 {
+			v, _ := d.skeleton(message, team.values(0))
+			return compactJSON(t, v)
+		}
+		return compactJSON(t, skeletonOf(catalog(users), testEndpoint(users, "Users.Update").Request, team.values(0)))
+	}
+	want := map[string]string{"": render(""), ".test.User": render(".test.User")}
+
+	const rounds = 8
+	for i := 0; i < rounds; i++ {
+		for message, expected := range want {
+			message, expected := message, expected
+			t.Run(fmt.Sprintf("%q/%d", message, i), func(t *testing.T) {
+				t.Parallel()
+				if got := render(message); got != expected {
+					t.Fatalf("expected\n%s\ngot\n%s", expected, got)
+				}
+			})
+		}
+	}
+}
+
+func TestServiceKey(t *testing.T) {
+	users := testService("go.micro.srv.users", new(Users))
+	redeployed := testService("go.micro.srv.users", new(Users))
+	redeployed.Nodes = []*registry.Node{{Id: "go.micro.srv.users-2", Address: "127.0.0.1:9091"}}
+	scaled := testService("go.micro.srv.users", new(Users))
+	scaled.Nodes = []*registry.Node{redeployed.Nodes[0], users.Nodes[0]}
+	reordered := testService("go.micro.srv.users", new(Users))
+	reordered.Nodes = []*registry.Node{users.Nodes[0], redeployed.Nodes[0]}
+
+	tests := []struct {
+		name string
+		a, b *registry.Service
+		same bool
+	}{
+		{name: "same nodes", a: users, b: testService("go.micro.srv.users", new(Users)), same: true},
+		{name: "node order", a: scaled, b: reordered, same: true},
+		// 以同一个版本重新部署，节点 id 变了
+		{name: "redeployed", a: users, b: redeployed},
+		{name: "scaled", a: users, b: scaled},
+		{name: "other service", a: users, b: testService("go.micro.srv.graph", new(Graph))},
+	}
+	for _, tt := range tests {
+		if same := serviceKey(tt.a) == serviceKey(tt.b); same != tt.same {
+			t.Errorf("%s: expected same key %v, got %q and %q", tt.name, tt.same, serviceKey(tt.a), serviceKey(tt.b))
+		}
+	}
+}
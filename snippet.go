@@ -0,0 +1,490 @@
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	goformat "go/format"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/micro/go-micro/registry"
+)
+
+// 可以生成的代码片段
+const (
+	snippetCurl    = "curl"
+	snippetGRPCurl = "grpcurl"
+	snippetMicro   = "micro"
+	snippetGo      = "go"
+)
+
+// shellQuote 用单引号括起 s，s 中的单引号先结束引号、转义后再重新开始
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// sortedKeys 返回排好序的 metadata 键，生成的片段每次都一样
+func sortedKeys(md map[string]string) []string {
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// requestJSON 把请求写成一行 JSON
+func (c *rpcCall) requestJSON() string {
+	if c.request == nil {
+		return "{}"
+	}
+	b, err := marshalJSON(c.request, "")
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+// snippet 按 kind 生成命令或代码，base 是控制台的地址，如 http://localhost:8082
+func (c *rpcCall) snippet(kind, base string) (string, error) {
+	switch kind {
+	case snippetCurl:
+		return c.curlSnippet(base), nil
+	case snippetGRPCurl:
+		return c.grpcurlSnippet()
+	case snippetMicro:
+		return c.microSnippet(), nil
+	case snippetGo:
+		return c.goSnippet()
+	}
+	return "", fmt.Errorf("unknown format %s, expected one of %s, %s, %s, %s",
+		kind, snippetCurl, snippetGRPCurl, snippetMicro, snippetGo)
+}
+
+// curlSnippet 生成调用 /rpc 的 curl 命令
+func (c *rpcCall) curlSnippet(base string) string {
+	body := jsonObject{
+		{"service", c.service},
+		{"endpoint", c.endpoint},
+		{"request", json.RawMessage(c.requestJSON())},
+	}
+	if len(c.transport) > 0 && c.transport != transportMicro {
+		body = append(body, jsonField{"transport", c.transport})
+	}
+	if len(c.address) > 0 {
+		body = append(body, jsonField{"address", c.address})
+	}
+	if len(c.metadata) > 0 {
+		body = append(body, jsonField{"metadata", c.metadata})
+	}
+	if c.timeout > 0 {
+		body = append(body, jsonField{"timeout", c.timeout.String()})
+	}
+	if c.requestTimeout > 0 {
+		body = append(body, jsonField{"request_timeout", c.requestTimeout.String()})
+	}
+	if c.retries >= 0 {
+		body = append(body, jsonField{"retries", c.retries})
+	}
+	// 片段中保留了占位符，由 /rpc 按环境替换
+	if c.expand {
+		if len(c.env) > 0 {
+			body = append(body, jsonField{"env", c.env})
+		}
+		body = append(body, jsonField{"expand", true})
+	}
+	b, _ := marshalJSON(body, "")
+	return fmt.Sprintf("curl -X POST %s \\\n  -H 'Content-Type: application/json' \\\n  -d %s",
+		shellQuote(strings.TrimRight(base, "/")+"/rpc"), shellQuote(string(b)))
+}
+
+// registryEndpoint 返回 registry 中的 endpoint，服务不在 registry 中时只有名字
+func (c *rpcCall) registryEndpoint() *registry.Endpoint {
+	if len(c.service) > 0 {
+		if s, err := defaultRegistry().GetService(c.service); err == nil && len(s) > 0 {
+			for _, e := range s[0].Endpoints {
+				if e.Name == c.endpoint {
+					return e
+				}
+			}
+		}
+	}
+	return &registry.Endpoint{Name: c.endpoint}
+}
+
+// protoService 按加载的 proto 描述返回 gRPC 的服务全名和所在的文件，如 go.micro.srv.hello.Say
+// 没有描述时返回空，调用方按服务名推断
+func protoService(ep *registry.Endpoint) (string, *descriptor.FileDescriptorProto) {
+	m := descriptors.method(ep)
+	if m == nil {
+		return "", nil
+	}
+	fd, sd := descriptors.methodFile(m)
+	if fd == nil {
+		return "", nil
+	}
+	if len(fd.GetPackage()) == 0 {
+		return sd.GetName(), fd
+	}
+	return fd.GetPackage() + "." + sd.GetName(), fd
+}
+
+// grpcurlSnippet 生成 grpcurl 命令，没有指定地址时用 registry 中的第一个节点
+func (c *rpcCall) grpcurlSnippet() (string, error) {
+	address, err := grpcAddress(c.service, c.address)
+	if err != nil {
+		return "", err
+	}
+	service, method := grpcMethodName(c.endpoint)
+	if full, _ := protoService(c.registryEndpoint()); len(full) > 0 {
+		service = full
+	} else if len(c.service) > 0 && !strings.Contains(service, ".") {
+		// 没有 proto 描述时假定 proto 包名和服务名相同，go-micro 的 Say.Hello 对应 go.micro.srv.hello.Say/Hello
+		service = c.service + "." + service
+	}
+
+	args := []string{"grpcurl -plaintext"}
+	for _, k := range sortedKeys(c.metadata) {
+		args = append(args, "-H "+shellQuote(k+": "+c.metadata[k]))
+	}
+	if c.timeout > 0 {
+		args = append(args, "-max-time "+strconv.FormatFloat(c.timeout.Seconds(), 'f', -1, 64))
+	}
+	// 加载过 proto 文件时直接用文件，否则要靠服务端的 reflection
+	for _, f := range descriptors.Files() {
+		args = append(args, "-proto "+shellQuote(f))
+	}
+	args = append(args, "-d "+shellQuote(c.requestJSON()), address, service+"/"+method)
+	return strings.Join(args, " \\\n  "), nil
+}
+
+// microSnippet 生成 micro call 命令
+func (c *rpcCall) microSnippet() string {
+	args := []string{"micro call"}
+	if len(c.address) > 0 {
+		args = append(args, "--address="+shellQuote(c.address))
+	}
+	for _, k := range sortedKeys(c.metadata) {
+		args = append(args, "--metadata="+shellQuote(k+"="+c.metadata[k]))
+	}
+	args = append(args, c.service, c.endpoint, shellQuote(c.requestJSON()))
+	return strings.Join(args, " ")
+}
+
+// goPackage 是 protoc-gen-go 在没有 go_package 选项时用的包名，如 go.micro.srv.hello 得到 go_micro_srv_hello
+func goPackage(service string) string {
+	pkg := strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' {
+			return '_'
+		}
+		return r
+	}, service)
+	if len(pkg) == 0 {
+		return "pb"
+	}
+	return pkg
+}
+
+// goImport 按 proto 文件的 go_package 返回生成代码的包名和 import 路径
+// 如 "github.com/foo/hello;hello"，没有 go_package 时路径为空，包名按 proto 包名推断
+func goImport(fd *descriptor.FileDescriptorProto) (string, string) {
+	path := fd.GetOptions().GetGoPackage()
+	if len(path) == 0 {
+		return goPackage(fd.GetPackage()), ""
+	}
+	if i := strings.Index(path, ";"); i >= 0 {
+		return path[i+1:], path[:i]
+	}
+	return goPackage(path[strings.LastIndex(path, "/")+1:]), path
+}
+
+// goName 和 protoc-gen-go 生成字段名的规则一致：每段首字母大写，去掉后面跟小写字母的下划线
+func goName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if i == 0 && c == '_' {
+			b.WriteByte('X')
+			continue
+		}
+		if (c == '_' || c == '.') && i+1 < len(name) && 'a' <= name[i+1] && name[i+1] <= 'z' {
+			continue
+		}
+		if '0' <= c && c <= '9' {
+			b.WriteByte(c)
+			continue
+		}
+		if 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		b.WriteByte(c)
+		for i+1 < len(name) && 'a' <= name[i+1] && name[i+1] <= 'z' {
+			i++
+			b.WriteByte(name[i])
+		}
+	}
+	return b.String()
+}
+
+// goScalars 是可以直接写出字面量的基本类型
+var goScalars = map[string]bool{
+	"string": true, "bool": true,
+	"int32": true, "int64": true, "uint32": true, "uint64": true, "uint8": true,
+	"float32": true, "float64": true,
+}
+
+// goLiteral 按 registry 中的类型把请求写成 Go 的字面量
+type goLiteral struct {
+	catalog *typeCatalog
+	pkg     string
+	buf     bytes.Buffer
+}
+
+// typeName 是字段在生成代码中的类型，消息是指针
+func (g *goLiteral) typeName(typ string) string {
+	typ = strings.TrimPrefix(typ, "*")
+	switch {
+	case isBytes(typ):
+		return "[]byte"
+	case strings.HasPrefix(typ, "[]"):
+		return "[]" + g.typeName(strings.TrimPrefix(typ, "[]"))
+	case goScalars[typ]:
+		return typ
+	}
+	if key, val, ok := mapType(typ); ok {
+		return "map[" + key + "]" + g.typeName(val)
+	}
+	if _, ok := g.catalog.types[typ]; ok {
+		return "*" + g.pkg + "." + typ
+	}
+	return g.pkg + "." + typ
+}
+
+// message 写出 &pkg.Type{...}，请求中的键按 registry 中的字段找，写法不同也能找到
+func (g *goLiteral) message(v *registry.Value, value interface{}) {
+	obj, _ := value.(map[string]interface{})
+	fmt.Fprintf(&g.buf, "&%s.%s{\n", g.pkg, v.Type)
+	for _, f := range v.Values {
+		for k, fv := range obj {
+			if normalizeName(k) != normalizeName(f.Name) || fv == nil {
+				continue
+			}
+			fmt.Fprintf(&g.buf, "%s: ", goName(f.Name))
+			g.value(f.Type, fv)
+			g.buf.WriteString(",\n")
+			break
+		}
+	}
+	g.buf.WriteString("}")
+}
+
+func (g *goLiteral) value(typ string, value interface{}) {
+	typ = strings.TrimPrefix(typ, "*")
+	if len(typ) == 0 {
+		// extractor 不给出 map 字段的键和值的类型，留给使用者填写
+		fmt.Fprintf(&g.buf, "nil /* map: %s */", g.comment(value))
+		return
+	}
+	if full, ok := g.catalog.wellKnown(typ, nil); ok {
+		// well-known type 需要 ptypes 之类的转换，留给使用者填写
+		fmt.Fprintf(&g.buf, "nil /* %s: %s */", full, g.comment(value))
+		return
+	}
+	switch {
+	case isBytes(typ):
+		s, _ := value.(string)
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			b = []byte(s)
+		}
+		fmt.Fprintf(&g.buf, "[]byte(%s)", strconv.Quote(string(b)))
+		return
+	case strings.HasPrefix(typ, "[]"):
+		elem := strings.TrimPrefix(typ, "[]")
+		fmt.Fprintf(&g.buf, "%s{\n", g.typeName(typ))
+		list, _ := value.([]interface{})
+		for _, item := range list {
+			g.value(elem, item)
+			g.buf.WriteString(",\n")
+		}
+		g.buf.WriteString("}")
+		return
+	}
+	if key, val, ok := mapType(typ); ok {
+		fmt.Fprintf(&g.buf, "%s{\n", g.typeName(typ))
+		obj, _ := value.(map[string]interface{})
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			g.scalar(key, k)
+			g.buf.WriteString(": ")
+			g.value(val, obj[k])
+			g.buf.WriteString(",\n")
+		}
+		g.buf.WriteString("}")
+		return
+	}
+	if t, ok := g.catalog.types[typ]; ok {
+		g.message(t, value)
+		return
+	}
+	g.scalar(typ, value)
+}
+
+// scalar 写出基本类型或枚举的值，枚举可以是名字也可以是数字
+func (g *goLiteral) scalar(typ string, value interface{}) {
+	s := fmt.Sprint(value)
+	switch {
+	case typ == "string":
+		g.buf.WriteString(strconv.Quote(s))
+	case typ == "bool":
+		g.buf.WriteString(s)
+	case goScalars[typ]:
+		// protojson 中 64 位整数和浮点数也可以写成字符串
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			s = "0"
+		}
+		g.buf.WriteString(s)
+	default:
+		if _, err := strconv.ParseInt(s, 10, 32); err == nil {
+			fmt.Fprintf(&g.buf, "%s.%s(%s)", g.pkg, typ, s)
+			return
+		}
+		fmt.Fprintf(&g.buf, "%s.%s(%s.%s_value[%s])", g.pkg, typ, g.pkg, typ, strconv.Quote(s))
+	}
+}
+
+// comment 是放在注释中的 JSON 值
+func (g *goLiteral) comment(value interface{}) string {
+	b, err := marshalJSON(value, "")
+	if err != nil {
+		return "null"
+	}
+	return strings.Replace(string(b), "*/", "* /", -1)
+}
+
+// goSnippetTemplate 是生成的 Go 程序，%s 依次是 import、metadata、调用和请求
+const goSnippetTemplate = `package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/micro/go-micro"%s
+)
+
+func main() {
+	service := micro.NewService()
+	service.Init()
+
+	ctx := context.Background()%s
+	rsp, err := %s(ctx, %s%s)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(rsp)
+}
+`
+
+// goSnippet 用 protoc-gen-micro 生成的 client 调用，如 hello.NewSayService(...).Hello(ctx, &hello.Request{...})
+func (c *rpcCall) goSnippet() (string, error) {
+	if len(c.service) == 0 {
+		return "", fmt.Errorf("go snippet needs a registered service")
+	}
+	s, err := defaultRegistry().GetService(c.service)
+	if err != nil {
+		return "", err
+	}
+	if len(s) == 0 {
+		return "", fmt.Errorf("unknown service %s", c.service)
+	}
+	var ep *registry.Endpoint
+	for _, e := range s[0].Endpoints {
+		if e.Name == c.endpoint {
+			ep = e
+		}
+	}
+	if ep == nil || ep.Request == nil {
+		return "", fmt.Errorf("unknown endpoint %s", c.endpoint)
+	}
+	service, method := grpcMethodName(c.endpoint)
+	if i := strings.LastIndex(service, "."); i >= 0 {
+		service = service[i+1:]
+	}
+
+	pkg, path := goPackage(c.service), ""
+	if _, fd := protoService(ep); fd != nil {
+		pkg, path = goImport(fd)
+	}
+	g := &goLiteral{catalog: catalog(s[0]), pkg: pkg}
+	g.message(ep.Request, c.request)
+
+	imports := fmt.Sprintf("\n\n\t%s %q", g.pkg, path)
+	if len(path) == 0 {
+		imports = fmt.Sprintf("\n\n\t%s %q // replace with the import path of the generated code", g.pkg, "path/to/"+g.pkg)
+	}
+	var md bytes.Buffer
+	if len(c.metadata) > 0 {
+		imports = "\n\t\"github.com/micro/go-micro/metadata\"" + imports
+		md.WriteString("\n\tctx = metadata.NewContext(ctx, map[string]string{\n")
+		for _, k := range sortedKeys(c.metadata) {
+			fmt.Fprintf(&md, "%q: %q,\n", k, c.metadata[k])
+		}
+		md.WriteString("})")
+	}
+	var opts string
+	if len(c.address) > 0 {
+		imports = "\n\t\"github.com/micro/go-micro/client\"" + imports
+		opts = fmt.Sprintf(", client.WithAddress(%q)", c.address)
+	}
+	call := fmt.Sprintf("%s.New%sService(%q, service.Client()).%s", g.pkg, goName(service), c.service, method)
+	src := fmt.Sprintf(goSnippetTemplate, imports, md.String(), call, g.buf.String(), opts)
+	b, err := goformat.Source([]byte(src))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// snippetHandler 把请求转成命令或代码，请求的写法和 /rpc 相同
+// 占位符原样保留，不把环境中的 token 之类的值写进片段
+// POST /snippet?format=curl|grpcurl|micro|go
+func snippetHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	req, err := decodeRPCRequest(r)
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	x, err := newExpander(req.Env)
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	x.keep, x.quote = true, true
+	c, err := req.newCall(x)
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	snippet, err := c.snippet(r.URL.Query().Get("format"), scheme+"://"+r.Host)
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(snippet))
+}
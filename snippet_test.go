@@ -0,0 +1,84 @@
+package web
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestGoName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "id", want: "Id"},
+		{name: "created_at", want: "CreatedAt"},
+		{name: "createdAt", want: "CreatedAt"},
+		{name: "user_id2", want: "UserId2"},
+		{name: "a_1", want: "A_1"},
+		{name: "_hidden", want: "XHidden"},
+		{name: "HTTPServer", want: "HTTPServer"},
+	}
+	for _, tt := range tests {
+		if got := goName(tt.name); got != tt.want {
+			t.Errorf("goName(%q) = %q, expected %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "", want: `''`},
+		{in: `{"name": "john"}`, want: `'{"name": "john"}'`},
+		{in: "it's", want: `'it'\''s'`},
+		{in: "$HOME `id`", want: "'$HOME `id`'"},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, expected %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestGoSnippet(t *testing.T) {
+	r, _, reset := useMemoryRegistry()
+	defer reset()
+	users := testService("go.micro.srv.users", new(Users))
+	if err := r.Register(users); err != nil {
+		t.Fatal(err)
+	}
+
+	var request interface{}
+	json.Unmarshal([]byte(`{
+		"user": {"id": "u-1", "avatar": "aGk=", "scores": {"math": 90}, "status": "INACTIVE", "groups": [{"name": "admins"}]},
+		"labels": {"team": "a*/b"},
+		"ttl": "1.5s"
+	}`), &request)
+	c := &rpcCall{service: "go.micro.srv.users", endpoint: "Users.Update", request: request}
+	src, err := c.goSnippet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "main.go", src, 0); err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, src)
+	}
+	for _, want := range []string{
+		`go_micro_srv_users.NewUsersService("go.micro.srv.users", service.Client()).Update(ctx, &go_micro_srv_users.UpdateRequest{`,
+		`Avatar: []byte("hi"),`,
+		// extractor 不给出 map 的类型，写成带注释的 nil
+		`Scores: nil, /* map: {"math":90} */`,
+		`Labels: nil, /* map: {"team":"a* /b"} */`,
+		`Status: go_micro_srv_users.Status(go_micro_srv_users.Status_value["INACTIVE"]),`,
+		`Groups: []*go_micro_srv_users.Group{`,
+		`Ttl:    nil, /* google.protobuf.Duration: "1.5s" */`,
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("expected %s in\n%s", want, src)
+		}
+	}
+}
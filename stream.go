@@ -0,0 +1,356 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// streamFrame 是页面通过 WebSocket 发来的消息
+type streamFrame struct {
+	// 要发送的请求，第一条请求用来打开流
+	Request json.RawMessage `json:"request"`
+	// 为 true 时结束发送，之后仍然接收服务返回的消息
+	Close bool `json:"close"`
+}
+
+// rpcStream 是一次流式调用，go-micro 和 gRPC 两种方式都实现它
+type rpcStream interface {
+	Send(request interface{}) error
+	// Recv 返回 JSON 格式的消息，流结束时返回 io.EOF
+	Recv() ([]byte, error)
+	CloseSend() error
+	Close() error
+}
+
+// microStream 通过 go-micro client.Stream 调用
+type microStream struct {
+	stream client.Stream
+}
+
+func (m *microStream) Send(request interface{}) error {
+	return m.stream.Send(request)
+}
+
+func (m *microStream) Recv() ([]byte, error) {
+	var rsp json.RawMessage
+	if err := m.stream.Recv(&rsp); err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
+// errCloseSendUnsupported 是 go-micro 方式下结束发送时返回的错误
+var errCloseSendUnsupported = &errors.Error{
+	Id:     "go.micro.rpc",
+	Code:   http.StatusNotImplemented,
+	Detail: "close send is not supported by the micro transport, use gRPC or disconnect",
+	Status: http.StatusText(http.StatusNotImplemented),
+}
+
+// go-micro 的流不能只关闭发送的一端，关闭整个流会丢掉服务还没返回的消息
+func (m *microStream) CloseSend() error {
+	return errCloseSendUnsupported
+}
+
+func (m *microStream) Close() error {
+	return m.stream.Close()
+}
+
+// grpcStream 直接通过 gRPC 调用
+type grpcStream struct {
+	stream grpc.ClientStream
+	method protoreflect.MethodDescriptor
+}
+
+func (g *grpcStream) Send(request interface{}) error {
+	b, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	in := dynamicpb.NewMessage(g.method.Input())
+	if err := protojson.Unmarshal(b, in); err != nil {
+		return err
+	}
+	return g.stream.SendMsg(in)
+}
+
+func (g *grpcStream) Recv() ([]byte, error) {
+	out := dynamicpb.NewMessage(g.method.Output())
+	if err := g.stream.RecvMsg(out); err != nil {
+		return nil, err
+	}
+	return protojson.MarshalOptions{
+		UseProtoNames:   true,
+		EmitUnpopulated: true,
+	}.Marshal(out)
+}
+
+func (g *grpcStream) CloseSend() error {
+	return g.stream.CloseSend()
+}
+
+func (g *grpcStream) Close() error {
+	return g.stream.CloseSend()
+}
+
+// streamCall 是 /stream 的参数
+type streamCall struct {
+	service   string
+	endpoint  string
+	address   string
+	transport string
+	strict    bool
+	metadata  map[string]string
+	// grpc 方式下解析到的方法
+	method protoreflect.MethodDescriptor
+	conn   *grpc.ClientConn
+}
+
+// validate 在 strict 模式下校验每条要发送的请求
+func (s *streamCall) validate(request interface{}) []string {
+	if !s.strict {
+		return nil
+	}
+	if s.method != nil {
+		return validateRequest(descriptors.messageSchema("."+string(s.method.Input().FullName()), fieldNaming), request)
+	}
+	return validateEndpointRequest(s.service, s.endpoint, request)
+}
+
+// error 把调用的错误转成和 /rpc 一样的格式
+func (s *streamCall) error(err error) *errors.Error {
+	if s.transport == transportGRPC {
+		return grpcError(string(s.method.Parent().FullName()), err)
+	}
+	return callError(err)
+}
+
+// open 打开流，go-micro 方式下第一条请求作为打开流的请求发出
+func (s *streamCall) open(ctx context.Context, r *http.Request, first interface{}) (rpcStream, error) {
+	if s.transport == transportGRPC {
+		desc := &grpc.StreamDesc{
+			StreamName:    string(s.method.Name()),
+			ClientStreams: s.method.IsStreamingClient(),
+			ServerStreams: s.method.IsStreamingServer(),
+		}
+		stream, err := s.conn.NewStream(grpcContext(ctx, r, s.service, s.metadata), desc, grpcPath(s.method))
+		if err != nil {
+			return nil, err
+		}
+		g := &grpcStream{stream: stream, method: s.method}
+		if err := g.Send(first); err != nil {
+			return nil, err
+		}
+		return g, nil
+	}
+
+	c := defaultClient()
+	req := c.NewRequest(s.service, s.endpoint, first, client.WithContentType("application/json"), client.StreamingRequest())
+	var opts []client.CallOption
+	if len(s.address) > 0 {
+		opts = append(opts, client.WithAddress(s.address))
+	}
+	stream, err := c.Stream(requestToContext(ctx, r, s.service, s.metadata), req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &microStream{stream: stream}, nil
+}
+
+// streamWriter 保证同一时间只有一个 goroutine 写 WebSocket
+type streamWriter struct {
+	sync.Mutex
+	conn *websocket.Conn
+}
+
+// write 给页面发一条消息，type 为 message、error 或 eof
+func (w *streamWriter) write(typ string, data interface{}) error {
+	obj := jsonObject{{"type", typ}}
+	if data != nil {
+		obj = append(obj, jsonField{"data", data})
+	}
+	b, err := marshalJSON(obj, "")
+	if err != nil {
+		return err
+	}
+	w.Lock()
+	defer w.Unlock()
+	return w.conn.WriteMessage(websocket.TextMessage, b)
+}
+
+// streamHandler 通过 WebSocket 调用流式 endpoint
+// 页面发 {"request": {...}} 发送一条请求，发 {"close": true} 结束发送，服务返回的消息以 {"type": "message", "data": {...}} 推给页面
+// GET /stream?service=go.micro.srv.greeter&endpoint=Say.Stream
+func streamHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "ParseForm err:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	s := &streamCall{
+		service:   r.Form.Get("service"),
+		endpoint:  r.Form.Get("endpoint"),
+		address:   r.Form.Get("address"),
+		transport: r.Form.Get("transport"),
+		strict:    strictMode,
+	}
+	if b, err := strconv.ParseBool(r.Form.Get("strict")); err == nil {
+		s.strict = s.strict || b
+	}
+	if len(s.endpoint) == 0 {
+		http.Error(w, "Error occurred: endpoint is required", http.StatusBadRequest)
+		return
+	}
+	md, err := parseMetadata(r.Form.Get("metadata"))
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.metadata = md
+	// expand 为 true 时每条消息中的占位符在发送前按环境替换
+	x, err := newExpander(r.Form.Get("env"))
+	if err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if expand, _ := strconv.ParseBool(r.Form.Get("expand")); !expand {
+		x.keep = true
+	}
+	if err := x.metadata(s.metadata); err != nil {
+		http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// gRPC 方式下先找到方法，找不到时不用升级连接
+	if s.transport == transportGRPC {
+		address, err := grpcAddress(s.service, s.address)
+		if err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+			return
+		}
+		var release func()
+		if s.conn, release, err = grpcClients.get(address); err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer release()
+		if s.method, err = resolveMethod(ctx, s.conn, s.endpoint); err != nil {
+			http.Error(w, "Error occurred:"+err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else if len(s.service) == 0 {
+		http.Error(w, "Error occurred: service is required", http.StatusBadRequest)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	out := &streamWriter{conn: conn}
+
+	var stream rpcStream
+	defer func() {
+		if stream != nil {
+			stream.Close()
+		}
+	}()
+
+	for {
+		_, b, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var frame streamFrame
+		if err := json.Unmarshal(b, &frame); err != nil {
+			out.write("error", errors.BadRequest("go.micro.rpc", "invalid frame: %v", err))
+			continue
+		}
+
+		if frame.Close {
+			if stream != nil {
+				if err := stream.CloseSend(); err != nil {
+					out.write("error", s.error(err))
+				}
+			}
+			continue
+		}
+
+		var request interface{}
+		d := json.NewDecoder(strings.NewReader(string(frame.Request)))
+		d.UseNumber()
+		if err := d.Decode(&request); err != nil {
+			out.write("error", errors.BadRequest("go.micro.rpc", "error decoding request: %v", err))
+			continue
+		}
+		if request, err = x.request(request); err != nil {
+			out.write("error", errors.BadRequest("go.micro.rpc", "%v", err))
+			continue
+		}
+		if errs := s.validate(request); len(errs) > 0 {
+			out.write("error", errors.BadRequest("go.micro.rpc", "%s", strings.Join(errs, "; ")))
+			continue
+		}
+
+		if stream != nil {
+			if err := stream.Send(request); err != nil {
+				out.write("error", s.error(err))
+			}
+			continue
+		}
+
+		if stream, err = s.open(ctx, r, request); err != nil {
+			out.write("error", s.error(err))
+			stream = nil
+			continue
+		}
+		go s.receive(stream, out)
+	}
+}
+
+// display 和 /rpc 一样按 fieldNaming 改写服务返回的消息
+func (s *streamCall) display(b []byte) []byte {
+	if s.method != nil {
+		return displayOutput("."+string(s.method.Output().FullName()), b)
+	}
+	return displayResponse(s.service, s.endpoint, b)
+}
+
+// receive 把服务返回的消息推给页面，直到流结束
+func (s *streamCall) receive(stream rpcStream, out *streamWriter) {
+	for {
+		b, err := stream.Recv()
+		if err == io.EOF {
+			out.write("eof", nil)
+			return
+		}
+		if err != nil {
+			out.write("error", s.error(err))
+			return
+		}
+		if err := out.write("message", json.RawMessage(s.display(b))); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// testClientStream 每次 RecvMsg 返回同一条 JSON 消息
+type testClientStream struct {
+	grpc.ClientStream
+	message string
+}
+
+func (s *testClientStream) RecvMsg(m interface{}) error {
+	return protojson.Unmarshal([]byte(s.message), m.(proto.Message))
+}
+
+// 流式 gRPC 返回的消息和 /rpc 一样按 fieldNaming 改写
+func TestGRPCStreamNaming(t *testing.T) {
+	descriptors.add(testFile())
+	defer func(naming string) { fieldNaming = naming }(fieldNaming)
+
+	method := findMethod(testDescriptors().protoFiles(), "test.Users", "Get")
+	s := &streamCall{transport: transportGRPC, method: method}
+	g := &grpcStream{
+		stream: &testClientStream{message: `{"name":"admins","members":[{"id":"u-1","createdAt":"2019-11-11T11:11:11Z"}]}`},
+		method: method,
+	}
+	tests := []struct {
+		naming string
+		want   string
+	}{
+		{naming: namingProto, want: `"created_at":"2019-11-11T11:11:11Z"`},
+		{naming: namingCamel, want: `"createdAt":"2019-11-11T11:11:11Z"`},
+		{naming: namingSnake, want: `"created_at":"2019-11-11T11:11:11Z"`},
+	}
+	for _, tt := range tests {
+		fieldNaming = tt.naming
+		b, err := g.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// protojson 输出的空格不固定
+		var got bytes.Buffer
+		if err := json.Compact(&got, s.display(b)); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(got.String(), tt.want) {
+			t.Errorf("%s: expected %s in %s", tt.naming, tt.want, got.String())
+		}
+	}
+}
@@ -33,6 +33,13 @@ var (
 	          <li><a href="terminal">Terminal</a></li>
 	          <li><a href="registry">Registry</a></li>
 	          <li><a href="client">Client</a></li>
+	          <li><a href="history">History</a></li>
+	          <li><a href="collections">Collections</a></li>
+	          <li><a href="environments">Environments</a></li>
+	          <li><a href="scenarios">Scenarios</a></li>
+	          <li><a href="load">Load</a></li>
+	          <li><a href="mocks">Mocks</a></li>
+	          <li><a href="golden">Golden</a></li>
 	          {{if .StatsURL}}<li><a href="{{.StatsURL}}" class="navbar-link">Stats</a></li>{{end}}
 	        </ul>
               </div>
@@ -143,18 +150,109 @@ jQuery(function($, undefined) {
 					<input class="form-control" type=text name=otherendpoint id=otherendpoint disabled placeholder="Endpoint"/>
 				</ul>
 			</div>
+			<div class="form-group">
+				<label for="transport">Transport</label>
+				<select class="form-control" name=transport id=transport>
+				<option value="micro" selected>go-micro</option>
+				<option value="grpc">gRPC</option>
+				</select>
+			</div>
+			<div class="form-group">
+				<label for="env">Environment</label>
+				<select class="form-control" name=env id=env>
+				<option value="">None</option>
+				</select>
+			</div>
+			<div class="form-group">
+				<label for="address">Address</label>
+				<input class="form-control" type=text name=address id=address list=nodes placeholder="host:port, optional for registered services"/>
+				<datalist id="nodes"></datalist>
+			</div>
+			<div class="form-group row">
+				<div class="col-xs-4">
+					<label for="timeout">Timeout</label>
+					<input class="form-control" type=text name=timeout id=timeout placeholder="e.g. 10s"/>
+				</div>
+				<div class="col-xs-4">
+					<label for="request_timeout">Request timeout</label>
+					<input class="form-control" type=text name=request_timeout id=request_timeout placeholder="e.g. 500ms" title="gRPC only when a timeout is set"/>
+				</div>
+				<div class="col-xs-4">
+					<label for="retries">Retries</label>
+					<input class="form-control" type=number min=0 name=retries id=retries placeholder="default"/>
+				</div>
+			</div>
+			<div class="form-group">
+				<label for="profile">Example values</label>
+				<select class="form-control" name=profile id=profile>
+				{{range profiles}}
+				<option value="{{.}}" {{if eq . defaultProfile}}selected{{end}}>{{.}}</option>
+				{{end}}
+				</select>
+			</div>
+			<div class="form-group">
+				<label for="seed">Seed</label>
+				<input class="form-control" type=number name=seed id=seed placeholder="random"/>
+			</div>
 			<div class="form-group">
 				<label for="request">Request</label>
+				<div class="btn-group btn-group-xs pull-right">
+					<button type="button" class="btn btn-default" onclick="return reformat(2);">Pretty</button>
+					<button type="button" class="btn btn-default" onclick="return reformat(0);">Minify</button>
+				</div>
 				<textarea class="form-control" name=request id=request rows=8 placeholder="request">{}</textarea>
+				<pre class="help-block" id="hints" style="border: none; background: none;"></pre>
+			</div>
+			<div class="form-group">
+				<label>Metadata</label>
+				<div class="btn-group btn-group-xs pull-right">
+					<button type="button" class="btn btn-default" onclick="return addHeader('', '');">Add</button>
+					<button type="button" class="btn btn-default" onclick="return saveHeaders();">Save as service defaults</button>
+				</div>
+				<table class="table table-condensed" id="headers"><tbody></tbody></table>
+			</div>
+			<div class="checkbox">
+				<label><input type="checkbox" name=strict id=strict/> Validate request against endpoint types</label>
 			</div>
 			<div class="form-group">
 				<button class="btn btn-default">Execute</button>
+				<button type="button" class="btn btn-default" onclick="return saveRequest();">Save</button>
+				<button type="button" class="btn btn-default" onclick="return loadTest();">Load test</button>
+				<button type="button" class="btn btn-default" onclick="return callAllNodes();">Call all nodes</button>
+				<div class="btn-group dropup">
+					<button type="button" class="btn btn-default dropdown-toggle" data-toggle="dropdown">Copy as <span class="caret"></span></button>
+					<ul class="dropdown-menu">
+						<li><a href="#" onclick="return copyAs('curl');">cURL</a></li>
+						<li><a href="#" onclick="return copyAs('grpcurl');">grpcurl</a></li>
+						<li><a href="#" onclick="return copyAs('micro');">micro call</a></li>
+						<li><a href="#" onclick="return copyAs('go');">Go client</a></li>
+					</ul>
+				</div>
+				<div class="btn-group pull-right">
+					<button type="button" class="btn btn-default" id="stream-open" onclick="return openStream();">Open stream</button>
+					<button type="button" class="btn btn-default" id="stream-send" onclick="return sendStream();" disabled>Send</button>
+					<button type="button" class="btn btn-default" id="stream-close" onclick="return closeSend();" title="gRPC only" disabled>Close send</button>
+					<button type="button" class="btn btn-default" id="stream-stop" onclick="return stopStream();" disabled>Disconnect</button>
+				</div>
 			</div>
+			<pre id="snippet" style="display: none;"></pre>
 		</form>
 	</div>
 	<div class="col-sm-7">
-		<p><b>Response</b></p>
+		<p>
+			<b>Response</b> <small id="node" class="text-muted"></small>
+			<button type="button" class="btn btn-default btn-xs pull-right" id="golden" style="display: none;" onclick="return markGolden();">Mark as golden</button>
+		</p>
 		<pre id="response" style="min-height: 405px;">{}</pre>
+		<div class="panel panel-default" id="timing-panel" style="display: none;">
+			<div class="panel-heading">
+				<a data-toggle="collapse" href="#timing">Timing</a> <small class="text-muted" id="timing-summary"></small>
+			</div>
+			<div id="timing" class="panel-collapse collapse">
+				<table class="table table-condensed"><tbody></tbody></table>
+			</div>
+		</div>
+		<div id="fanout"></div>
 	</div>
     </div>
   </div>
@@ -165,15 +263,19 @@ jQuery(function($, undefined) {
 		$(document).ready(function(){
 			var s_map = {};
 			var se_map = {};
-			{{ range $service, $endpoints := .Results }}
+			var sh_map = {};
+			{{ range $service, $svc := .Results }}
 			var m_list = [];
 			var ee_map = {};
-			{{range $index, $element := $endpoints}}
+			var eh_map = {};
+			{{range $index, $element := $svc.Endpoints}}
 			m_list[{{$index}}] = {{$element.Name}}
-			ee_map[{{$element.Name}}] = {{format $element.Request}}
+			ee_map[{{$element.Name}}] = {{formatRequest (catalog $svc) $element}}
+			eh_map[{{$element.Name}}] = {{enumHints $element}}
 			{{end}}
 			s_map[{{$service}}] = m_list
 			se_map[{{$service}}] = ee_map
+			sh_map[{{$service}}] = eh_map
 			{{ end }}
 			//Function executes on change of first select option field 
 			$("#service").change(function(){
@@ -191,7 +293,26 @@ jQuery(function($, undefined) {
 					}
 				}
 				$("#endpoint").append("<option value=\"other\"> - Other</option>");
+				loadHeaders(select);
+				loadNodes(select);
 			});
+			// 地址可以从服务的节点中选，调用固定发往这个节点
+			function loadNodes(service) {
+				$("#nodes").empty();
+				$.ajax({
+					url: "registry",
+					data: {"service": service},
+					contentType: "application/json",
+					dataType: "json",
+					success: function(data) {
+						$.each(data.services || [], function(i, svc) {
+							$.each(svc.nodes || [], function(j, node) {
+								$("#nodes").append($("<option>").val(node.address).text(node.id + " (" + svc.version + ")"));
+							});
+						});
+					},
+				});
+			}
 			//Function executes on change of second select option field 
 			$("#endpoint").change(function(){
 				var select_service = $("#service option:selected").val();
@@ -202,16 +323,303 @@ jQuery(function($, undefined) {
 					$("#otherendpoint").attr("disabled", true);
 					$('#otherendpoint').val('');
 				}
-            	$("#request").empty();
-            	$("#request").append(se_map[select_service][select_endpoint]);
+				loadRequest();
+			});
+			// 选中的环境保存在浏览器中，终端也使用这个环境
+			$.ajax({
+				url: "environments",
+				contentType: "application/json",
+				dataType: "json",
+				success: function(data) {
+					$.each(Object.keys(data.environments || {}).sort(), function(i, name) {
+						$("#env").append($("<option>").val(name).text(name));
+					});
+					$("#env").val(localStorage.getItem("env") || "");
+				},
 			});
+			$("#env").change(function() {
+				localStorage.setItem("env", $("#env").val());
+			});
+			// client?history=12 把历史记录填入表单
+			// client?collection=greeter&name=hello 把保存的请求填入表单
+			var params = new URLSearchParams(window.location.search);
+			if (params.get("history")) {
+				$.getJSON("history", {"id": params.get("history")}, fillForm);
+			} else if (params.get("collection")) {
+				$.getJSON("collections", {"collection": params.get("collection"), "name": params.get("name")}, fillForm);
+			}
+			function fillForm(e) {
+				pendingHeaders = e.metadata || {};
+				$("#service").val(e.service).change();
+				if ($("#service").val() != e.service) {
+					// 服务不在 registry 中，如直接按地址调用的 gRPC 服务
+					$.each(pendingHeaders, addHeader);
+					pendingHeaders = null;
+				}
+				if ($("#endpoint option[value='" + e.endpoint + "']").length > 0) {
+					$("#endpoint").val(e.endpoint);
+				} else {
+					$("#endpoint").val("other");
+					$("#otherendpoint").attr("disabled", false).val(e.endpoint);
+				}
+				$("#transport").val(e.transport || "micro");
+				$("#address").val(e.address || "");
+				$("#timeout").val(e.timeout || "");
+				$("#request_timeout").val(e.request_timeout || "");
+				$("#retries").val(e.retries >= 0 ? e.retries : "");
+				$("#request").val(typeof e.request == "string" ? e.request : JSON.stringify(e.request, null, 2));
+			}
+			$("#profile").change(loadRequest);
+			$("#seed").change(loadRequest);
+			$("#transport").change(loadMethods);
+			$("#address").change(loadMethods);
+			// gRPC 方式下通过 reflection 列出地址上服务的方法
+			var grpc_map = {};
+			var grpch_map = {};
+			function loadMethods() {
+				var address = $("#address").val();
+				if ($("#transport").val() != "grpc" || address == "") {
+					return;
+				}
+				$.ajax({
+					url: "grpc",
+					data: {"address": address, "profile": $("#profile").val()},
+					dataType: "json",
+					success: function(data) {
+						grpc_map = {};
+						grpch_map = {};
+						$("#endpoint").empty();
+						$("#endpoint").append("<option disabled selected> -- select an endpoint -- </option>");
+						$.each(data.methods || [], function(i, m) {
+							grpc_map[m.name] = JSON.stringify(m.request, null, 2);
+							grpch_map[m.name] = m.hints || [];
+							$("#endpoint").append($("<option>").val(m.name).text(m.name + (m.streaming ? " (streaming)" : "")));
+						});
+						$("#endpoint").append("<option value=\"other\"> - Other</option>");
+					},
+					error: function(xhr) {
+						document.getElementById("response").innerText = xhr.responseText;
+					},
+				});
+			}
+			// 按选中的 profile 填充请求示例，默认 profile 的示例已经在页面里
+			function loadRequest() {
+				var select_service = $("#service option:selected").val();
+				var select_endpoint = $("#endpoint option:selected").val();
+				var profile = $("#profile").val();
+				if ($("#transport").val() == "grpc" && select_endpoint in grpc_map) {
+					$("#hints").text(grpch_map[select_endpoint].join("\n"));
+					$("#request").val(grpc_map[select_endpoint]);
+					return;
+				}
+				var hints = (sh_map[select_service] || {})[select_endpoint] || [];
+				$("#hints").text(hints.join("\n"));
+				if (!(select_service in se_map) || !(select_endpoint in se_map[select_service])) {
+					return;
+				}
+				var seed = $("#seed").val();
+				if (profile == {{defaultProfile}} && seed == "") {
+					$("#request").val(se_map[select_service][select_endpoint]);
+					return;
+				}
+				var data = {"service": select_service, "endpoint": select_endpoint, "profile": profile};
+				if (seed != "") {
+					data["seed"] = seed;
+				}
+				$.ajax({
+					url: "skeleton",
+					data: data,
+					dataType: "text",
+					success: function(data, status, xhr) {
+						$("#request").val(data);
+						// 随机生成时把 seed 填回去，方便在问题报告里复现
+						$("#seed").attr("placeholder", "random (last: " + xhr.getResponseHeader("X-Skeleton-Seed") + ")");
+					},
+				});
+			}
 		});
 	</script>
 	<script>
+		function reformat(indent) {
+			var el = document.forms[0].elements["request"];
+			try {
+				el.value = JSON.stringify(JSON.parse(el.value), null, indent);
+			} catch(e) {
+				document.getElementById("response").innerText = "Invalid request JSON: " + e.message;
+			}
+			return false;
+		};
+		// metadata 面板，每行一个键值，选中服务时填入服务的默认 metadata
+		function addHeader(key, value) {
+			var row = $("<tr>");
+			row.append($("<td>").append($("<input class=\"form-control input-sm header-key\" placeholder=\"key\"/>").val(key)));
+			row.append($("<td>").append($("<input class=\"form-control input-sm header-value\" placeholder=\"value\"/>").val(value)));
+			row.append($("<td>").append($("<button type=\"button\" class=\"btn btn-default btn-sm\">&times;</button>").click(function() {
+				row.remove();
+			})));
+			$("#headers tbody").append(row);
+			return false;
+		};
+		function collectHeaders() {
+			var md = {};
+			$("#headers tbody tr").each(function() {
+				var key = $(this).find(".header-key").val();
+				if (key != "") {
+					md[key] = $(this).find(".header-value").val();
+				}
+			});
+			return md;
+		};
+		// 从历史记录载入时用记录中的 metadata 代替服务的默认值
+		var pendingHeaders = null;
+		function loadHeaders(service) {
+			$("#headers tbody").empty();
+			$.getJSON("headers", {"service": service}, function(data) {
+				if (pendingHeaders != null) {
+					data = pendingHeaders;
+					pendingHeaders = null;
+				}
+				$.each(data, function(key, value) {
+					addHeader(key, value);
+				});
+			});
+		};
+		function saveHeaders() {
+			var service = document.forms[0].elements["service"].value;
+			$.ajax({
+				method: "POST",
+				url: "headers?" + $.param({"service": service}),
+				contentType: "application/json",
+				data: JSON.stringify(collectHeaders()),
+				success: function() {
+					document.getElementById("response").innerText = "Saved default metadata for " + service;
+				},
+				error: function(xhr) {
+					document.getElementById("response").innerText = xhr.responseText;
+				},
+			});
+			return false;
+		};
+		// 流式 endpoint 通过 WebSocket 调用，收发的消息依次显示在 Response 中
+		var ws = null;
+		function streamLog(prefix, data) {
+			var el = document.getElementById("response");
+			if (typeof data != "string") {
+				data = JSON.stringify(data, null, 2);
+			}
+			el.innerText += prefix + " " + data + "\n";
+			el.scrollTop = el.scrollHeight;
+		};
+		function streamButtons(open) {
+			$("#stream-open").attr("disabled", open);
+			$("#stream-send").attr("disabled", !open);
+			// go-micro 的流不能只关闭发送的一端
+			$("#stream-close").attr("disabled", !open || document.forms[0].elements["transport"].value != "grpc");
+			$("#stream-stop").attr("disabled", !open);
+		};
+		function openStream() {
+			var endpoint = document.forms[0].elements["endpoint"].value
+			if (!($('#otherendpoint').prop('disabled'))) {
+				endpoint = document.forms[0].elements["otherendpoint"].value
+			}
+			var params = $.param({
+				"service": document.forms[0].elements["service"].value,
+				"endpoint": endpoint,
+				"transport": document.forms[0].elements["transport"].value,
+				"address": document.forms[0].elements["address"].value,
+				"strict": document.forms[0].elements["strict"].checked,
+				"metadata": JSON.stringify(collectHeaders()),
+				"env": document.forms[0].elements["env"].value,
+				"expand": true
+			});
+			var url = new URL("stream?" + params, window.location.href);
+			url.protocol = url.protocol.replace("http", "ws");
+			document.getElementById("response").innerText = "";
+			ws = new WebSocket(url.href);
+			ws.onopen = function() {
+				streamButtons(true);
+				streamLog("--", "connected to " + endpoint);
+				sendStream();
+			};
+			ws.onmessage = function(e) {
+				var msg = JSON.parse(e.data);
+				if (msg.type == "message") {
+					streamLog("<<", msg.data);
+				} else if (msg.type == "eof") {
+					streamLog("--", "end of stream");
+				} else {
+					streamLog("!!", msg.data);
+				}
+			};
+			ws.onclose = function() {
+				streamLog("--", "disconnected");
+				streamButtons(false);
+				ws = null;
+			};
+			return false;
+		};
+		function sendStream() {
+			if (ws == null) {
+				return false;
+			}
+			var request = requestBody();
+			ws.send(JSON.stringify({"request": request}));
+			streamLog(">>", request);
+			return false;
+		};
+		function closeSend() {
+			if (ws != null) {
+				ws.send(JSON.stringify({"close": true}));
+				streamLog("--", "close send");
+			}
+			return false;
+		};
+		function stopStream() {
+			if (ws != null) {
+				ws.close();
+			}
+			return false;
+		};
+		// 请求中有不加引号的占位符时不是合法的 JSON，原样发给服务端替换
+		function requestBody() {
+			var body = document.forms[0].elements["request"].value;
+			try {
+				return JSON.parse(body);
+			} catch(e) {
+				return body;
+			}
+		};
 		function call() {
 			var req = new XMLHttpRequest()
 			req.onreadystatechange = function() {
-				if (req.readyState == 4 && req.status == 200) {
+				if (req.readyState != 4) {
+					return;
+				}
+				// 响应放在 envelope 中，同时带回实际调用的节点
+				var rsp = null;
+				try {
+					rsp = JSON.parse(req.responseText);
+				} catch(e) {}
+				$("#response").removeClass("text-danger");
+				renderTiming(rsp);
+				// 调用记入历史时可以标记为 golden
+				lastHistory = rsp != null ? rsp.history : null;
+				$("#golden").toggle(lastHistory != null).prop("disabled", false).text("Mark as golden");
+				if (rsp != null && "attempts" in rsp) {
+					var node = rsp.node ? rsp.node.id + " " + rsp.node.address : "no node";
+					document.getElementById("node").innerText = node + ", " + rsp.attempts + " attempt(s), " + rsp.latency_ms.toFixed(1) + " ms";
+					if ("error" in rsp) {
+						$("#response").addClass("text-danger").text(errorSummary(rsp) + "\n\n" + JSON.stringify(rsp.error, null, 2));
+					} else {
+						document.getElementById("response").innerText = JSON.stringify(rsp.response, null, 2);
+					}
+					return;
+				}
+				document.getElementById("node").innerText = "";
+				if (req.status != 200) {
+					$("#response").addClass("text-danger");
+				}
+				if (req.status == 200) {
 					document.getElementById("response").innerText = JSON.stringify(JSON.parse(req.responseText), null, 2);
 				} else if (req.responseText.slice(0, 1) == "{") {
 					document.getElementById("response").innerText = JSON.stringify(JSON.parse(req.responseText), null, 2);
@@ -222,6 +630,71 @@ jQuery(function($, undefined) {
 				}
 				console.log(req.responseText);
 			}
+			var request = rpcRequest();
+			request["envelope"] = true;
+			req.open("POST", "/rpc", true);
+			req.setRequestHeader("Content-type","application/json");				
+			req.send(JSON.stringify(request));
+
+			return false;
+		};	
+		// 在响应下面显示各阶段的耗时和大小，go-micro 方式的连接时间测不到，算在 server 中
+		function renderTiming(rsp) {
+			if (rsp == null || !rsp.timing) {
+				$("#timing-panel").hide();
+				return;
+			}
+			var t = rsp.timing;
+			var ms = function(v) { return v == null ? "n/a (included in server)" : v.toFixed(1) + " ms"; };
+			var rows = [
+				["Node", rsp.node ? rsp.node.address : "no node"],
+				["Selector", ms(t.selector_ms)],
+				["Connect", ms(t.connect_ms)],
+				["Server", ms(t.server_ms)],
+				["Total", ms(t.total_ms)],
+				["Request size", t.request_bytes + " bytes"],
+				["Response size", t.response_bytes + " bytes"]
+			];
+			var body = $("#timing tbody").empty();
+			$.each(rows, function(i, row) {
+				body.append($("<tr>").append($("<th>").text(row[0]), $("<td>").text(row[1])));
+			});
+			$("#timing-summary").text(ms(t.total_ms) + ", " + t.response_bytes + " bytes");
+			$("#timing-panel").show();
+		};
+		// 把最近一次调用和结果保存到 golden 套件，以后用 replay 命令重放比较
+		var lastHistory = null;
+		function markGolden() {
+			var endpoint = $("#endpoint").val();
+			if (!($('#otherendpoint').prop('disabled'))) {
+				endpoint = $("#otherendpoint").val();
+			}
+			var name = prompt("Name", endpoint || "");
+			if (name == null) {
+				return false;
+			}
+			$.ajax({
+				method: "POST",
+				url: "golden",
+				contentType: "application/json",
+				data: JSON.stringify({"history": lastHistory, "name": name}),
+				success: function() {
+					$("#golden").prop("disabled", true).text("Marked as golden");
+				},
+				error: function(xhr) { alert(xhr.responseText); },
+			});
+			return false;
+		};
+		// 错误的摘要，如 "404 Not Found (gRPC NotFound)" 和 "go.micro.srv.greeter: user not found"
+		function errorSummary(rsp) {
+			var summary = rsp.code + " " + rsp.status;
+			if (rsp.grpc_code) {
+				summary += " (gRPC " + rsp.grpc_code + ")";
+			}
+			return summary + "\n" + rsp.id + ": " + rsp.detail;
+		};
+		// 按表单构造 /rpc 的请求
+		function rpcRequest() {
 			var endpoint = document.forms[0].elements["endpoint"].value
 			if (!($('#otherendpoint').prop('disabled'))) {
 				endpoint = document.forms[0].elements["otherendpoint"].value
@@ -229,14 +702,142 @@ jQuery(function($, undefined) {
 			var request = {
 				"service": document.forms[0].elements["service"].value,
 				"endpoint": endpoint,
-				"request": JSON.parse(document.forms[0].elements["request"].value)
+				"request": requestBody(),
+				"strict": document.forms[0].elements["strict"].checked,
+				"transport": document.forms[0].elements["transport"].value,
+				"address": document.forms[0].elements["address"].value,
+				"metadata": collectHeaders(),
+				"timeout": document.forms[0].elements["timeout"].value,
+				"request_timeout": document.forms[0].elements["request_timeout"].value,
+				"env": document.forms[0].elements["env"].value,
+				"expand": true
 			}
-			req.open("POST", "/rpc", true);
-			req.setRequestHeader("Content-type","application/json");				
-			req.send(JSON.stringify(request));
-
+			var retries = document.forms[0].elements["retries"].value;
+			if (retries != "") {
+				request["retries"] = parseInt(retries, 10);
+			}
+			return request;
+		};
+		// 把当前的请求转成命令或代码，显示在表单下面并复制到剪贴板
+		function copyAs(format) {
+			$.ajax({
+				method: "POST",
+				url: "snippet?" + $.param({"format": format}),
+				contentType: "application/json",
+				data: JSON.stringify(rpcRequest()),
+				dataType: "text",
+				success: function(text) {
+					$("#snippet").removeClass("text-danger").text(text).show();
+					if (navigator.clipboard) {
+						navigator.clipboard.writeText(text);
+					}
+				},
+				error: function(xhr) {
+					$("#snippet").addClass("text-danger").text(xhr.responseText).show();
+				},
+			});
 			return false;
-		};	
+		};
+		// 把同一个请求发给服务的所有节点，和多数节点的结果不同的节点标红并列出差异
+		function callAllNodes() {
+			$("#fanout").html($("<p class=\"text-muted\">").text("Calling all nodes..."));
+			$.ajax({
+				method: "POST",
+				url: "fanout",
+				contentType: "application/json",
+				data: JSON.stringify(rpcRequest()),
+				dataType: "json",
+				success: renderFanout,
+				error: function(xhr) {
+					$("#fanout").html($("<pre class=\"text-danger\">").text(xhr.responseText));
+				},
+			});
+			return false;
+		};
+		function renderFanout(data) {
+			var summary = data.majority + " of " + data.nodes.length + " node(s) agree";
+			var table = $("<table class=\"table table-condensed\">").append(
+				$("<thead>").append($("<tr>").append(
+					$("<th>").text("Node"), $("<th>").text("Version"), $("<th>").text("Latency (ms)"), $("<th>").text("Result"))));
+			var body = $("<tbody>");
+			$.each(data.nodes, function(i, n) {
+				var result = n.error ? n.error.code + " " + n.error.detail : "ok";
+				var row = $("<tr>").toggleClass("danger", !n.match).append(
+					$("<td>").append($("<a href=\"#\">").text(n.id + " " + n.address)),
+					$("<td>").text(n.version),
+					$("<td>").text(n.latency_ms.toFixed(1)),
+					$("<td>").text(result + (n.match ? "" : " (differs)")));
+				var details = JSON.stringify(n.error ? n.error : n.response, null, 2);
+				if (n.diff) {
+					details = $.map(n.diff, function(d) {
+						return d.kind + " " + d.path + ": " + JSON.stringify(d.want) + " -> " + JSON.stringify(d.got);
+					}).join("\n") + "\n\n" + details;
+				}
+				var detail = $("<tr>").hide().append($("<td colspan=\"4\">").append($("<pre>").text(details)));
+				row.find("a").click(function() {
+					detail.toggle();
+					return false;
+				});
+				body.append(row, detail);
+			});
+			$("#fanout").empty().append(
+				$("<p>").append($("<b>").text("All nodes"), " ", $("<small>").toggleClass("text-danger", !data.consistent).text(summary)),
+				table.append(body));
+		};
+		// 在压测页面打开当前的请求
+		function loadTest() {
+			var endpoint = $("#endpoint").val();
+			if (!($('#otherendpoint').prop('disabled'))) {
+				endpoint = $("#otherendpoint").val();
+			}
+			window.location = "load?" + $.param({
+				"service": $("#service").val() || "",
+				"endpoint": endpoint || "",
+				"transport": $("#transport").val(),
+				"address": $("#address").val(),
+				"env": $("#env").val(),
+				"request": $("#request").val()
+			});
+			return false;
+		};
+		// 保存到请求集合，集合名默认使用服务名
+		function saveRequest() {
+			var endpoint = $("#endpoint").val();
+			if (!($('#otherendpoint').prop('disabled'))) {
+				endpoint = $("#otherendpoint").val();
+			}
+			var service = $("#service").val();
+			var collection = prompt("Collection", service || "");
+			if (!collection) {
+				return false;
+			}
+			var name = prompt("Name", endpoint || "");
+			if (!name) {
+				return false;
+			}
+			var saved = {
+				"name": name,
+				"service": service,
+				"endpoint": endpoint,
+				"transport": $("#transport").val(),
+				"address": $("#address").val(),
+				"metadata": collectHeaders(),
+				"request": requestBody(),
+				"timeout": $("#timeout").val(),
+				"request_timeout": $("#request_timeout").val()
+			};
+			if ($("#retries").val() != "") {
+				saved["retries"] = parseInt($("#retries").val(), 10);
+			}
+			$.ajax({
+				method: "POST",
+				url: "collections?" + $.param({"collection": collection}),
+				contentType: "application/json",
+				data: JSON.stringify(saved),
+				error: function(xhr) { alert(xhr.responseText); },
+			});
+			return false;
+		};
 	</script>
 {{end}}
 `
@@ -249,10 +850,36 @@ jQuery(function($, undefined) {
 		<a href="registry?service={{.Name}}" data-filter={{.Name}} class="btn btn-default btn-lg service" style="margin: 5px 3px 5px 3px;">{{.Name}}</a>
 		{{end}}
 	</div>
+	<hr/>
+	<form id="descriptor-form" class="form-inline">
+		<div class="form-group">
+			<label for="descriptor">Proto descriptor set</label>
+			<input type="file" name="file" id="descriptor"/>
+		</div>
+		<button class="btn btn-default">Upload</button>
+		<span id="descriptor-files" class="help-inline"></span>
+	</form>
 {{end}}
 {{define "script"}}
 <script type="text/javascript">
 jQuery(function($, undefined) {
+	var showFiles = function(data) {
+		$('#descriptor-files').text((data.files || []).join(", "));
+	};
+	$.getJSON('descriptors', showFiles);
+	$('#descriptor-form').on('submit', function() {
+		$.ajax({
+			url: 'descriptors',
+			type: 'POST',
+			data: new FormData(this),
+			processData: false,
+			contentType: false,
+			success: showFiles,
+			error: function(xhr) { $('#descriptor-files').text(xhr.responseText); },
+		});
+		return false;
+	});
+
 	var refs = $('a[data-filter]');
 	$('.search').on('keyup', function() {
 		var val = $.trim(this.value);
@@ -297,7 +924,7 @@ jQuery(function($, undefined) {
 	<hr/>
 	{{end}}
 	{{range $svc.Endpoints}}
-		<h4>{{.Name}}</h4>
+		<h4>{{.Name}} <small><a href="schema?service={{$svc.Name}}&endpoint={{.Name}}">JSON Schema</a></small></h4>
 		<table class="table table-bordered">
 			<tbody>
 				<tr>
@@ -306,11 +933,11 @@ jQuery(function($, undefined) {
 				</tr>
 				<tr>
 					<th class="col-sm-2" scope="row">Request</th>
-					<td><pre>{{format .Request}}</pre></td>
+					<td><pre>{{formatRequest (catalog $svc) .}}</pre></td>
 				</tr>
 				<tr>
 					<th class="col-sm-2" scope="row">Response</th>
-					<td><pre>{{format .Response}}</pre></td>
+					<td><pre>{{formatResponse (catalog $svc) .}}</pre></td>
 				</tr>
 			</tbody>
 		</table>
@@ -342,6 +969,11 @@ jQuery(function($, undefined) {
 <script src="https://cdnjs.cloudflare.com/ajax/libs/jquery.terminal/2.0.2/js/jquery.terminal.min.js"></script>
 <script type="text/javascript">
 jQuery(function($, undefined) {
+    // 调用的节点、耗时和次数，显示在响应或错误下面
+    function callInfo(data) {
+	var node = data.node ? data.node.id + " " + data.node.address : "no node";
+	return node + ", " + data.latency_ms.toFixed(1) + " ms, " + data.attempts + " attempt(s)";
+    }
     $('#shell').terminal(function(command, term) {
         if (command == '') {
             term.echo('');
@@ -491,25 +1123,70 @@ jQuery(function($, undefined) {
 
 		break;
 	    case "call":
-		if (args.length < 3) {
-		    term.echo("USAGE:\n    call [service] [endpoint] [request]");
+		// --header k=v 可以写多次，放在请求之前
+		// --env name 选择替换 env.xxx 占位符的环境，默认使用 Call 页面选中的环境
+		var md = {};
+		var env = localStorage.getItem("env") || "";
+		var rest = [];
+		for (var i = 1; i < args.length; i++) {
+			var kv = null;
+			if (rest.length < 2 && args[i] == "--env" && i + 1 < args.length) {
+				env = args[++i];
+				continue;
+			} else if (rest.length < 2 && args[i].indexOf("--env=") == 0) {
+				env = args[i].slice("--env=".length);
+				continue;
+			} else if (rest.length < 2 && (args[i] == "--header" || args[i] == "-H") && i + 1 < args.length) {
+				kv = args[++i];
+			} else if (rest.length < 2 && args[i].indexOf("--header=") == 0) {
+				kv = args[i].slice("--header=".length);
+			} else {
+				rest.push(args[i]);
+				continue;
+			}
+			var idx = kv.indexOf("=");
+			if (idx <= 0) {
+			    term.echo("invalid header " + kv + ", expected k=v");
+			    return;
+			}
+			md[kv.slice(0, idx)] = kv.slice(idx + 1);
+		}
+
+		if (rest.length < 2) {
+		    term.echo("USAGE:\n    call [--env name] [--header k=v]... [service] [endpoint] [request]");
 		    return;
 		}
 
 		var request = "{}"
 
-		if (args.length > 3) {
-			request = args.slice(3).join(" ");
+		if (rest.length > 2) {
+			request = rest.slice(2).join(" ");
 		}		
 
 		$.ajax({
-		  endpoint: "POST",
+		  method: "POST",
 		  dataType: "json",
 		  contentType: "application/json",
 		  url: "rpc",
-		  data: JSON.stringify({"service": args[1], "endpoint": args[2], "request": request}),
+		  data: JSON.stringify({"service": rest[0], "endpoint": rest[1], "request": request, "metadata": md, "env": env, "expand": true, "envelope": true}),
 		  success: function(data) {
-		    term.echo(JSON.stringify(data, null, 2));
+		    term.echo(JSON.stringify(data.response, null, 2));
+		    term.echo("[[;gray;]" + $.terminal.escape_brackets(callInfo(data)) + "]");
+		  },
+		  error: function(xhr) {
+		    // 调用出错时响应是 envelope，请求本身有错时是 go-micro 的错误或文本
+		    var data = null;
+		    try {
+			data = JSON.parse(xhr.responseText);
+		    } catch(e) {}
+		    if (data == null || !("attempts" in data)) {
+			term.error(xhr.responseText);
+			return;
+		    }
+		    var summary = data.code + " " + data.status + (data.grpc_code ? " (gRPC " + data.grpc_code + ")" : "");
+		    term.error(summary);
+		    term.error(data.id + ": " + data.detail);
+		    term.echo("[[;gray;]" + $.terminal.escape_brackets(callInfo(data)) + "]");
 		  },
 		});
 		
@@ -529,5 +1206,869 @@ jQuery(function($, undefined) {
 });
 </script>
 {{end}}
+`
+	historyTemplate = `
+{{define "title"}}History{{end}}
+{{define "heading"}}<h3>History</h3>{{end}}
+{{define "content"}}
+	<form id="history-form" class="form-inline" onsubmit="return loadHistory();">
+		<input class="form-control" type=text name=service id=service placeholder="Service"/>
+		<input class="form-control" type=text name=endpoint id=endpoint placeholder="Endpoint"/>
+		<select class="form-control" name=status id=status>
+			<option value="">Any status</option>
+			<option value="ok">OK</option>
+			<option value="error">Error</option>
+		</select>
+		<button class="btn btn-default">Filter</button>
+		<button type="button" class="btn btn-default pull-right" onclick="return clearHistory();">Clear</button>
+	</form>
+	<table class="table table-condensed" id="history">
+		<thead>
+			<tr><th>Time</th><th>Service</th><th>Endpoint</th><th>Status</th><th>Latency</th><th>Node</th><th></th></tr>
+		</thead>
+		<tbody></tbody>
+	</table>
+{{end}}
+{{define "script"}}
+<script type="text/javascript">
+	function loadHistory() {
+		$.ajax({
+			url: "history",
+			data: {
+				"service": $("#service").val(),
+				"endpoint": $("#endpoint").val(),
+				"status": $("#status").val()
+			},
+			contentType: "application/json",
+			dataType: "json",
+			success: function(data) {
+				var body = $("#history tbody").empty();
+				$.each(data.history, function(i, e) {
+					var row = $("<tr>");
+					row.append($("<td>").text(new Date(e.time).toLocaleString()));
+					row.append($("<td>").text(e.service));
+					row.append($("<td>").text(e.endpoint));
+					row.append($("<td>").append($("<span class=\"label\">")
+						.addClass(e.status == "ok" ? "label-success" : "label-danger").text(e.code)));
+					row.append($("<td>").text(e.latency_ms.toFixed(1) + " ms"));
+					row.append($("<td>").text(e.node || ""));
+					var detail = $("<tr>").hide().append($("<td colspan=7>").append($("<pre>").text(
+						"request:\n" + JSON.stringify(e.request, null, 2) + "\n\n" +
+						(e.status == "ok" ? "response:\n" + JSON.stringify(e.response, null, 2) : "error:\n" + JSON.stringify(e.error, null, 2)))));
+					row.append($("<td class=\"text-right\">")
+						.append($("<button class=\"btn btn-default btn-xs\">Details</button>").click(function() { detail.toggle(); }))
+						.append(" ")
+						.append($("<button class=\"btn btn-default btn-xs\">Replay</button>").click(function() { replay(e.id, detail); }))
+						.append(" ")
+						.append($("<button class=\"btn btn-default btn-xs\">Mark as golden</button>").click(function() { markGolden(e.id, $(this)); }))
+						.append(" ")
+						.append($("<a class=\"btn btn-default btn-xs\">Load into editor</a>").attr("href", "client?history=" + e.id)));
+					body.append(row).append(detail);
+				});
+			},
+		});
+		return false;
+	};
+	function replay(id, detail) {
+		$.ajax({
+			method: "POST",
+			url: "history/replay?" + $.param({"id": id}),
+			dataType: "json",
+			complete: function(xhr) {
+				detail.show().find("pre").text("replay:\n" + JSON.stringify(JSON.parse(xhr.responseText), null, 2));
+				setTimeout(loadHistory, 1000);
+			},
+		});
+	};
+	function markGolden(id, button) {
+		$.ajax({
+			method: "POST",
+			url: "golden",
+			contentType: "application/json",
+			data: JSON.stringify({"history": id}),
+			success: function() { button.prop("disabled", true).text("Golden"); },
+			error: function(xhr) { alert(xhr.responseText); },
+		});
+	};
+	function clearHistory() {
+		if (!confirm("Delete all recorded calls?")) {
+			return false;
+		}
+		$.ajax({method: "DELETE", url: "history", success: loadHistory});
+		return false;
+	};
+	$(document).ready(loadHistory);
+</script>
+{{end}}
+`
+	collectionTemplate = `
+{{define "title"}}Collections{{end}}
+{{define "heading"}}<h3>Collections</h3>{{end}}
+{{define "content"}}
+	<form id="import-form" class="form-inline" onsubmit="return importCollections();">
+		<div class="form-group">
+			<input type="file" name="file" id="file"/>
+		</div>
+		<select class="form-control" name=format id=format>
+			<option value="">Detect format</option>
+			<option value="native">Exported collections</option>
+			<option value="postman">Postman v2.1</option>
+			<option value="grpcurl">grpcurl commands</option>
+		</select>
+		<input class="form-control" type=text name=collection id=collection placeholder="Collection for grpcurl"/>
+		<button class="btn btn-default">Import</button>
+		<a class="btn btn-default pull-right" href="collections?export=true">Export</a>
+	</form>
+	<p class="help-block" id="import-result"></p>
+	<div id="collections"></div>
+{{end}}
+{{define "script"}}
+<script type="text/javascript">
+	function loadCollections() {
+		$.ajax({
+			url: "collections",
+			contentType: "application/json",
+			dataType: "json",
+			success: function(data) {
+				var list = $("#collections").empty();
+				$.each(data.collections || [], function(i, c) {
+					list.append($("<h4>").text(c.name + " ")
+						.append($("<button class=\"btn btn-default btn-xs\">Delete</button>").click(function() { remove(c.name, ""); })));
+					// 集合中的请求按服务分组
+					var services = {};
+					$.each(c.requests || [], function(j, req) {
+						var svc = req.service || req.address || "";
+						(services[svc] = services[svc] || []).push(req);
+					});
+					$.each(Object.keys(services).sort(), function(j, svc) {
+						var table = $("<table class=\"table table-condensed\">")
+							.append($("<thead>").append($("<tr>").append($("<th colspan=3>").text(svc))));
+						var body = $("<tbody>").appendTo(table);
+						$.each(services[svc], function(k, req) {
+							var detail = $("<tr>").hide().append($("<td colspan=3>").append($("<pre>").text(JSON.stringify(req, null, 2))));
+							body.append($("<tr>")
+								.append($("<td>").text(req.name))
+								.append($("<td>").text(req.endpoint + (req.transport == "grpc" ? " (grpc)" : "")))
+								.append($("<td class=\"text-right\">")
+									.append($("<button class=\"btn btn-default btn-xs\">Details</button>").click(function() { detail.toggle(); }))
+									.append(" ")
+									.append($("<a class=\"btn btn-default btn-xs\">Load into editor</a>")
+										.attr("href", "client?" + $.param({"collection": c.name, "name": req.name})))
+									.append(" ")
+									.append($("<button class=\"btn btn-default btn-xs\">Delete</button>").click(function() { remove(c.name, req.name); }))))
+								.append(detail);
+						});
+						list.append(table);
+					});
+				});
+			},
+		});
+	};
+	function remove(collection, name) {
+		if (!confirm(name ? "Delete " + name + "?" : "Delete collection " + collection + "?")) {
+			return;
+		}
+		$.ajax({
+			method: "DELETE",
+			url: "collections?" + $.param({"collection": collection, "name": name}),
+			success: loadCollections,
+		});
+	};
+	function importCollections() {
+		$.ajax({
+			method: "POST",
+			url: "collections/import?" + $.param({"format": $("#format").val(), "collection": $("#collection").val()}),
+			data: new FormData(document.getElementById("import-form")),
+			processData: false,
+			contentType: false,
+			dataType: "json",
+			success: function(data) {
+				var text = "Imported " + data.imported + " request(s).";
+				if (data.skipped.length > 0) {
+					text += " Skipped: " + data.skipped.join("; ");
+				}
+				$("#import-result").text(text);
+				loadCollections();
+			},
+			error: function(xhr) {
+				$("#import-result").text(xhr.responseText);
+			},
+		});
+		return false;
+	};
+	$(document).ready(loadCollections);
+</script>
+{{end}}
+`
+	environmentTemplate = `
+{{define "title"}}Environments{{end}}
+{{define "heading"}}<h3>Environments</h3>{{end}}
+{{define "content"}}
+<div class="row">
+	<div class="col-sm-4">
+		<div class="list-group" id="environments"></div>
+	</div>
+	<div class="col-sm-8">
+		<form id="environment-form" onsubmit="return saveEnvironment();">
+			<div class="form-group">
+				<label for="name">Name</label>
+				<input class="form-control" type=text name=name id=name placeholder="dev, test, staging"/>
+			</div>
+			<div class="form-group">
+				<label for="vars">Variables</label>
+				<textarea class="form-control" name=vars id=vars rows=10 placeholder='{"tenant": "t1"}'>{}</textarea>
+				<p class="help-block">
+					Use <code>{{"{{"}}env.tenant{{"}}"}}</code> in requests and metadata, together with
+					<code>{{"{{"}}uuid{{"}}"}}</code>, <code>{{"{{"}}now | rfc3339{{"}}"}}</code> and <code>{{"{{"}}random.int 1 100{{"}}"}}</code>.
+					Write <code>{{"{{{{"}}</code> for a literal <code>{{"{{"}}</code>. API calls to /rpc expand placeholders only with <code>"expand": true</code>.
+				</p>
+			</div>
+			<button class="btn btn-default">Save</button>
+			<button type="button" class="btn btn-default" onclick="return deleteEnvironment();">Delete</button>
+		</form>
+	</div>
+</div>
+{{end}}
+{{define "script"}}
+<script type="text/javascript">
+	var envs = {};
+	function loadEnvironments() {
+		$.ajax({
+			url: "environments",
+			contentType: "application/json",
+			dataType: "json",
+			success: function(data) {
+				envs = data.environments || {};
+				var list = $("#environments").empty();
+				$.each(Object.keys(envs).sort(), function(i, name) {
+					list.append($("<a href=\"#\" class=\"list-group-item\">").text(name).click(function() {
+						$("#name").val(name);
+						$("#vars").val(JSON.stringify(envs[name], null, 2));
+						return false;
+					}));
+				});
+			},
+		});
+	};
+	function saveEnvironment() {
+		try {
+			var vars = JSON.parse($("#vars").val());
+		} catch(e) {
+			alert("Invalid variables JSON: " + e.message);
+			return false;
+		}
+		$.ajax({
+			method: "POST",
+			url: "environments?" + $.param({"name": $("#name").val()}),
+			contentType: "application/json",
+			data: JSON.stringify(vars),
+			success: loadEnvironments,
+			error: function(xhr) { alert(xhr.responseText); },
+		});
+		return false;
+	};
+	function deleteEnvironment() {
+		var name = $("#name").val();
+		if (!name || !confirm("Delete environment " + name + "?")) {
+			return false;
+		}
+		$.ajax({method: "DELETE", url: "environments?" + $.param({"name": name}), success: loadEnvironments});
+		return false;
+	};
+	$(document).ready(loadEnvironments);
+</script>
+{{end}}
+`
+	scenarioTemplate = `
+{{define "title"}}Scenarios{{end}}
+{{define "heading"}}<h3>Scenarios</h3>{{end}}
+{{define "content"}}
+<div class="row">
+	<div class="col-sm-5">
+		<form id="scenario-form" onsubmit="return runScenario();">
+			<div class="form-group">
+				<label for="env">Environment</label>
+				<select class="form-control" name=env id=env>
+				<option value="">As in scenario</option>
+				</select>
+			</div>
+			<div class="form-group">
+				<label for="scenario">Scenario</label>
+				<textarea class="form-control" name=scenario id=scenario rows=24 style="font-family: monospace;"></textarea>
+				<p class="help-block">
+					Steps run in order and stop at the first failure. <code>extract</code> saves JSONPath values as
+					<code>{{"{{"}}vars.name{{"}}"}}</code> for later steps, <code>assert</code> checks
+					<code>equals</code>, <code>contains</code>, <code>matches</code> or <code>exists</code> at a path.
+				</p>
+			</div>
+			<button class="btn btn-default">Run</button>
+		</form>
+	</div>
+	<div class="col-sm-7">
+		<p><b>Result</b> <span id="result"></span></p>
+		<table class="table table-condensed" id="steps">
+			<thead>
+				<tr><th>Step</th><th>Status</th><th>Latency</th><th>Node</th><th></th></tr>
+			</thead>
+			<tbody></tbody>
+		</table>
+	</div>
+</div>
+{{end}}
+{{define "script"}}
+<script type="text/javascript">
+	// 编辑中的场景保存在浏览器中
+	var example = {
+		"name": "create user, then greet them",
+		"steps": [
+			{
+				"name": "create",
+				"service": "go.micro.srv.user",
+				"endpoint": "User.Create",
+				"request": {"name": "test-{{"{{"}}uuid{{"}}"}}"},
+				"extract": {"user_id": "$.id"}
+			},
+			{
+				"name": "greet",
+				"service": "go.micro.srv.greeter",
+				"endpoint": "Say.Hello",
+				"request": {"name": "{{"{{"}}vars.user_id{{"}}"}}"},
+				"assert": [{"path": "$.msg", "contains": "{{"{{"}}vars.user_id{{"}}"}}"}]
+			}
+		]
+	};
+	function runScenario() {
+		localStorage.setItem("scenario", $("#scenario").val());
+		$("#result").text("running...");
+		$("#steps tbody").empty();
+		$.ajax({
+			method: "POST",
+			url: "scenarios?" + $.param({"env": $("#env").val()}),
+			contentType: "application/json",
+			data: $("#scenario").val(),
+			dataType: "json",
+			success: function(data) {
+				$("#result").empty().append($("<span class=\"label\">")
+					.addClass(data.passed ? "label-success" : "label-danger")
+					.text(data.passed ? "PASS" : "FAIL"))
+					.append(" " + data.latency_ms.toFixed(1) + " ms" + (data.env ? ", environment " + data.env : ""));
+				var body = $("#steps tbody");
+				$.each(data.steps, function(i, s) {
+					var label = {"pass": "label-success", "fail": "label-danger", "skip": "label-default"}[s.status];
+					var detail = $("<tr>").hide().append($("<td colspan=5>").append($("<pre>").text(
+						"request:\n" + JSON.stringify(s.request, null, 2) + "\n\n" +
+						(s.error ? "error:\n" + JSON.stringify(s.error, null, 2) : "response:\n" + JSON.stringify(s.response, null, 2)) +
+						(s.vars ? "\n\nvars:\n" + JSON.stringify(s.vars, null, 2) : ""))));
+					var row = $("<tr>")
+						.append($("<td>").text(s.name))
+						.append($("<td>").append($("<span class=\"label\">").addClass(label).text(s.status.toUpperCase())))
+						.append($("<td>").text(s.status == "skip" ? "" : s.latency_ms.toFixed(1) + " ms"))
+						.append($("<td>").text(s.node || ""))
+						.append($("<td class=\"text-right\">").append(s.status == "skip" ? "" :
+							$("<button class=\"btn btn-default btn-xs\">Details</button>").click(function() { detail.toggle(); })));
+					body.append(row);
+					$.each(s.failures || [], function(j, f) {
+						body.append($("<tr class=\"danger\">").append($("<td colspan=5>").append($("<small>").text(f))));
+					});
+					body.append(detail);
+				});
+			},
+			error: function(xhr) {
+				$("#result").text(xhr.responseText);
+			},
+		});
+		return false;
+	};
+	$(document).ready(function() {
+		$("#scenario").val(localStorage.getItem("scenario") || JSON.stringify(example, null, 2));
+		$.ajax({
+			url: "environments",
+			contentType: "application/json",
+			dataType: "json",
+			success: function(data) {
+				$.each(Object.keys(data.environments || {}).sort(), function(i, name) {
+					$("#env").append($("<option>").val(name).text(name));
+				});
+			},
+		});
+	});
+</script>
+{{end}}
+`
+	loadTemplate = `
+{{define "title"}}Load{{end}}
+{{define "heading"}}<h3>Load test</h3>{{end}}
+{{define "content"}}
+<div class="row">
+	<div class="col-sm-5">
+		<form id="load-form" onsubmit="return startLoad();">
+			<div class="form-group">
+				<label for="service">Service</label>
+				<input class="form-control" type=text name=service id=service placeholder="go.micro.srv.greeter"/>
+			</div>
+			<div class="form-group">
+				<label for="endpoint">Endpoint</label>
+				<input class="form-control" type=text name=endpoint id=endpoint placeholder="Say.Hello"/>
+			</div>
+			<div class="form-group row">
+				<div class="col-xs-4">
+					<label for="transport">Transport</label>
+					<select class="form-control" name=transport id=transport>
+					<option value="micro" selected>go-micro</option>
+					<option value="grpc">gRPC</option>
+					</select>
+				</div>
+				<div class="col-xs-4">
+					<label for="address">Address</label>
+					<input class="form-control" type=text name=address id=address placeholder="optional"/>
+				</div>
+				<div class="col-xs-4">
+					<label for="env">Environment</label>
+					<select class="form-control" name=env id=env>
+					<option value="">None</option>
+					</select>
+				</div>
+			</div>
+			<div class="form-group row">
+				<div class="col-xs-3">
+					<label for="concurrency">Concurrency</label>
+					<input class="form-control" type=number min=1 max=100 name=concurrency id=concurrency value=10/>
+				</div>
+				<div class="col-xs-3">
+					<label for="qps">QPS</label>
+					<input class="form-control" type=number min=0 name=qps id=qps placeholder="unlimited"/>
+				</div>
+				<div class="col-xs-3">
+					<label for="duration">Duration</label>
+					<input class="form-control" type=text name=duration id=duration value="10s"/>
+				</div>
+				<div class="col-xs-3">
+					<label for="requests">Requests</label>
+					<input class="form-control" type=number min=0 name=requests id=requests placeholder="no limit"/>
+				</div>
+			</div>
+			<div class="form-group">
+				<label for="request">Request</label>
+				<textarea class="form-control" name=request id=request rows=8>{}</textarea>
+			</div>
+			<button class="btn btn-default" id="load-start">Start</button>
+			<button type="button" class="btn btn-default" id="load-stop" onclick="return stopLoad();" disabled>Stop</button>
+			<div class="btn-group pull-right">
+				<button type="button" class="btn btn-default" id="load-export" onclick="return exportLoad();" disabled>Export</button>
+				<label class="btn btn-default">Compare<input type="file" id="baseline" style="display: none;"/></label>
+			</div>
+		</form>
+	</div>
+	<div class="col-sm-7">
+		<div class="progress">
+			<div class="progress-bar" id="load-progress" style="width: 0%;"></div>
+		</div>
+		<p id="load-status" class="text-muted"></p>
+		<table class="table table-condensed" id="load-stats">
+			<thead>
+				<tr><th></th><th>This run</th><th id="baseline-name">Baseline</th></tr>
+			</thead>
+			<tbody></tbody>
+		</table>
+		<table class="table table-condensed" id="load-errors">
+			<thead>
+				<tr><th>Count</th><th>Code</th><th>Error</th></tr>
+			</thead>
+			<tbody></tbody>
+		</table>
+	</div>
+</div>
+{{end}}
+{{define "script"}}
+<script type="text/javascript">
+	var ws = null;
+	var report = null;
+	var baseline = null;
+	var rows = [
+		["Requests", "sent", 0],
+		["Failed", "failed", 0],
+		["Throughput (req/s)", "throughput", 1],
+		["Min (ms)", "latency_ms.min", 1],
+		["Mean (ms)", "latency_ms.mean", 1],
+		["p50 (ms)", "latency_ms.p50", 1],
+		["p95 (ms)", "latency_ms.p95", 1],
+		["p99 (ms)", "latency_ms.p99", 1],
+		["Max (ms)", "latency_ms.max", 1]
+	];
+	function value(rep, path) {
+		var v = rep;
+		$.each(path.split("."), function(i, k) { v = v == null ? null : v[k]; });
+		return v;
+	};
+	function showReport() {
+		var body = $("#load-stats tbody").empty();
+		$.each(rows, function(i, row) {
+			var tr = $("<tr>").append($("<td>").text(row[0]));
+			tr.append($("<td>").text(report ? value(report, row[1]).toFixed(row[2]) : ""));
+			tr.append($("<td>").text(baseline ? value(baseline, row[1]).toFixed(row[2]) : ""));
+			body.append(tr);
+		});
+		var errors = $("#load-errors tbody").empty();
+		$.each(report ? report.errors : [], function(i, e) {
+			errors.append($("<tr>").append($("<td>").text(e.count)).append($("<td>").text(e.code)).append($("<td>").text(e.detail)));
+		});
+		if (!report) {
+			return;
+		}
+		var progress = 0;
+		if (report.requests > 0) {
+			progress = report.sent / report.requests;
+		} else if (report.duration_ms > 0) {
+			progress = report.elapsed_ms / report.duration_ms;
+		}
+		if (report.done) {
+			progress = 1;
+		}
+		$("#load-progress").css("width", Math.min(100, progress * 100) + "%");
+		$("#load-status").text((report.done ? "Finished" : "Running") + ", " + (report.elapsed_ms / 1000).toFixed(1) + "s elapsed");
+	};
+	function loading(running) {
+		$("#load-start").attr("disabled", running);
+		$("#load-stop").attr("disabled", !running);
+		$("#load-export").attr("disabled", running || report == null);
+	};
+	function startLoad() {
+		var request = $("#request").val();
+		try {
+			request = JSON.parse(request);
+		} catch(e) {}
+		var spec = {
+			"service": $("#service").val(),
+			"endpoint": $("#endpoint").val(),
+			"transport": $("#transport").val(),
+			"address": $("#address").val(),
+			"env": $("#env").val(),
+			"request": request,
+			"concurrency": parseInt($("#concurrency").val(), 10) || 1,
+			"qps": parseFloat($("#qps").val()) || 0,
+			"duration": $("#duration").val(),
+			"requests": parseInt($("#requests").val(), 10) || 0
+		};
+		var url = new URL("load", window.location.href);
+		url.protocol = url.protocol.replace("http", "ws");
+		report = null;
+		showReport();
+		ws = new WebSocket(url.href);
+		ws.onopen = function() {
+			loading(true);
+			ws.send(JSON.stringify(spec));
+		};
+		ws.onmessage = function(e) {
+			var msg = JSON.parse(e.data);
+			if (msg.type == "error") {
+				$("#load-status").text(msg.data.detail || JSON.stringify(msg.data));
+				return;
+			}
+			report = msg.data;
+			showReport();
+		};
+		ws.onclose = function() {
+			ws = null;
+			loading(false);
+		};
+		return false;
+	};
+	function stopLoad() {
+		if (ws != null) {
+			ws.send(JSON.stringify({"stop": true}));
+		}
+		return false;
+	};
+	// 导出的结果可以在下次压测时作为 Baseline 对比
+	function exportLoad() {
+		var blob = new Blob([JSON.stringify(report, null, 2)], {type: "application/json"});
+		var a = document.createElement("a");
+		a.href = URL.createObjectURL(blob);
+		a.download = "load-" + report.endpoint + "-" + new Date(report.start).toISOString() + ".json";
+		a.click();
+		return false;
+	};
+	$(document).ready(function() {
+		var params = new URLSearchParams(window.location.search);
+		$.each(["service", "endpoint", "transport", "address", "request"], function(i, k) {
+			if (params.get(k)) {
+				$("#" + k).val(params.get(k));
+			}
+		});
+		$.ajax({
+			url: "environments",
+			contentType: "application/json",
+			dataType: "json",
+			success: function(data) {
+				$.each(Object.keys(data.environments || {}).sort(), function(i, name) {
+					$("#env").append($("<option>").val(name).text(name));
+				});
+				$("#env").val(params.get("env") || localStorage.getItem("env") || "");
+			},
+		});
+		$("#baseline").change(function() {
+			var reader = new FileReader();
+			var file = this.files[0];
+			reader.onload = function() {
+				try {
+					baseline = JSON.parse(reader.result);
+				} catch(e) {
+					alert("Invalid result file: " + e.message);
+					return;
+				}
+				$("#baseline-name").text(file.name);
+				showReport();
+			};
+			reader.readAsText(file);
+		});
+		showReport();
+	});
+</script>
+{{end}}
+`
+	mockTemplate = `
+{{define "title"}}Mocks{{end}}
+{{define "heading"}}<h3>Mocks</h3>{{end}}
+{{define "content"}}
+<div class="row">
+	<div class="col-sm-5">
+		<form id="mock-form" onsubmit="return saveMock();">
+			<div class="form-group">
+				<label for="mock">Mock</label>
+				<textarea class="form-control" name=mock id=mock rows=24 style="font-family: monospace;"></textarea>
+				<p class="help-block">
+					YAML or JSON. Each endpoint answers with the first rule whose <code>when</code> conditions
+					(<code>equals</code>, <code>contains</code>, <code>matches</code> or <code>exists</code> at a path) all hold.
+					Responses may use <code>{{"{{"}}request.name{{"}}"}}</code>, <code>{{"{{"}}uuid{{"}}"}}</code> and <code>{{"{{"}}now{{"}}"}}</code>.
+					Saving registers the service; it is removed from the registry when deleted or when the dashboard stops.
+					A service that already has real nodes is refused unless <code>"override": true</code> is set.
+				</p>
+			</div>
+			<button class="btn btn-default">Save and register</button>
+			<span id="saved" class="text-muted"></span>
+		</form>
+	</div>
+	<div class="col-sm-7">
+		<table class="table table-condensed" id="mocks">
+			<thead>
+				<tr><th>Service</th><th>Endpoints</th><th>Address</th><th></th></tr>
+			</thead>
+			<tbody></tbody>
+		</table>
+	</div>
+</div>
+{{end}}
+{{define "script"}}
+<script type="text/javascript">
+	var example = {
+		"service": "go.micro.srv.user",
+		"endpoints": [
+			{
+				"name": "User.Get",
+				"request": {"id": "u-1"},
+				"rules": [
+					{
+						"when": [{"path": "$.id", "equals": "missing"}],
+						"error": {"code": 404, "detail": "user not found"}
+					},
+					{
+						"response": {"id": "{{"{{"}}request.id{{"}}"}}", "name": "Alice", "created": "{{"{{"}}now | rfc3339{{"}}"}}"},
+						"delay": "50ms"
+					}
+				]
+			}
+		]
+	};
+	function saveMock() {
+		$("#saved").text("");
+		$.ajax({
+			method: "POST",
+			url: "mocks",
+			contentType: "text/plain",
+			data: $("#mock").val(),
+			success: function() {
+				$("#saved").removeClass("text-danger").text("registered");
+				loadMocks();
+			},
+			error: function(xhr) {
+				$("#saved").addClass("text-danger").text(xhr.responseText);
+				loadMocks();
+			},
+		});
+		return false;
+	};
+	function deleteMock(service) {
+		$.ajax({
+			method: "DELETE",
+			url: "mocks?" + $.param({"service": service}),
+			success: loadMocks,
+			error: function(xhr) { alert(xhr.responseText); },
+		});
+	};
+	function loadMocks() {
+		$.ajax({
+			url: "mocks",
+			contentType: "application/json",
+			dataType: "json",
+			success: function(data) {
+				var body = $("#mocks tbody").empty();
+				$.each(data.mocks || [], function(i, m) {
+					var def = {"service": m.service, "endpoints": m.endpoints};
+					if (m.version) {
+						def["version"] = m.version;
+					}
+					if (m.override) {
+						def["override"] = true;
+					}
+					var address = m.error ? $("<span class=\"text-danger\">").text(m.error) : $("<span>").text(m.address || "stopped");
+					body.append($("<tr>")
+						.append($("<td>").text(m.service + (m.version ? " (" + m.version + ")" : "")))
+						.append($("<td>").text($.map(m.endpoints, function(e) { return e.name; }).join(", ")))
+						.append($("<td>").append(address))
+						.append($("<td class=\"text-right\">")
+							.append($("<button class=\"btn btn-default btn-xs\">Edit</button>").click(function() {
+								$("#mock").val(JSON.stringify(def, null, 2));
+							}))
+							.append(" ")
+							.append($("<button class=\"btn btn-default btn-xs\">Delete</button>").click(function() {
+								deleteMock(m.service);
+							}))));
+				});
+			},
+		});
+	};
+	$(document).ready(function() {
+		$("#mock").val(JSON.stringify(example, null, 2));
+		loadMocks();
+	});
+</script>
+{{end}}
+`
+	goldenTemplate = `
+{{define "title"}}Golden{{end}}
+{{define "heading"}}<h3>Golden</h3>{{end}}
+{{define "content"}}
+	<form id="suite-form" class="form-inline" onsubmit="return saveSuite();">
+		<input class="form-control" type=text name=name id=name placeholder="Suite name"/>
+		<input class="form-control" type=text name=ignore id=ignore size=50 placeholder="Ignore e.g. $..updated_at, $.items[*].id"/>
+		<button class="btn btn-default">Save</button>
+		<div class="btn-group pull-right">
+			<button type="button" class="btn btn-default" onclick="return replayGolden(0);">Replay all</button>
+			<a class="btn btn-default" href="golden?download=true">Download suite</a>
+		</div>
+	</form>
+	<p class="help-block">
+		Mark calls as golden from the Client or History page. Replaying re-issues each call and compares the response
+		field by field with the recorded one, skipping ignored fields: <code>$.created_at</code>, <code>$.items[*].id</code>,
+		<code>$.*.version</code>, or <code>$..updated_at</code> (also written <code>updated_at</code>) at any depth.
+		Run the downloaded suite with <code>epc replay golden.json</code>.
+	</p>
+	<p id="summary"></p>
+	<table class="table table-condensed" id="calls">
+		<thead>
+			<tr><th>Name</th><th>Service</th><th>Endpoint</th><th>Recorded</th><th>Ignore</th><th></th></tr>
+		</thead>
+		<tbody></tbody>
+	</table>
+{{end}}
+{{define "script"}}
+<script type="text/javascript">
+	var results = {};
+	function splitRules(s) {
+		return $.grep($.map(s.split(","), $.trim), function(r) { return r != ""; });
+	};
+	function saveSuite() {
+		$.ajax({
+			method: "PUT",
+			url: "golden",
+			contentType: "application/json",
+			data: JSON.stringify({"name": $("#name").val(), "ignore": splitRules($("#ignore").val())}),
+			success: loadGolden,
+			error: function(xhr) { alert(xhr.responseText); },
+		});
+		return false;
+	};
+	function saveCall(id, name, ignore) {
+		$.ajax({
+			method: "PUT",
+			url: "golden?" + $.param({"id": id}),
+			contentType: "application/json",
+			data: JSON.stringify({"name": name, "ignore": splitRules(ignore)}),
+			success: loadGolden,
+			error: function(xhr) { alert(xhr.responseText); },
+		});
+	};
+	function deleteCall(id, name) {
+		if (!confirm("Delete " + name + "?")) {
+			return;
+		}
+		$.ajax({method: "DELETE", url: "golden?" + $.param({"id": id}), success: loadGolden});
+	};
+	// id 为 0 时重放整个套件
+	function replayGolden(id) {
+		$("#summary").removeClass("text-danger text-success").text("Replaying...");
+		$.ajax({
+			method: "POST",
+			url: "golden/replay?" + $.param({"id": id}),
+			dataType: "json",
+			success: function(rep) {
+				$.each(rep.calls, function(i, c) { results[c.id] = c; });
+				$("#summary").toggleClass("text-danger", !rep.passed).toggleClass("text-success", rep.passed)
+					.text(rep.calls.length + " call(s) replayed, " + rep.failed + " differ (" + rep.latency_ms.toFixed(1) + " ms)");
+				loadGolden();
+			},
+			error: function(xhr) {
+				$("#summary").addClass("text-danger").text(xhr.responseText);
+			},
+		});
+		return false;
+	};
+	function loadGolden() {
+		$.ajax({
+			url: "golden",
+			contentType: "application/json",
+			dataType: "json",
+			success: function(suite) {
+				$("#name").val(suite.name || "");
+				$("#ignore").val((suite.ignore || []).join(", "));
+				var body = $("#calls tbody").empty();
+				$.each(suite.calls, function(i, g) {
+					var name = $("<input class=\"form-control input-sm\" type=text>").val(g.name);
+					var ignore = $("<input class=\"form-control input-sm\" type=text>").val((g.ignore || []).join(", "));
+					var recorded = $("<span class=\"label\">").addClass(g.status == "ok" ? "label-success" : "label-danger").text(g.code);
+					var row = $("<tr>").append(
+						$("<td>").append(name),
+						$("<td>").text(g.service),
+						$("<td>").text(g.endpoint),
+						$("<td>").append(recorded),
+						$("<td>").append(ignore));
+					var res = results[g.id];
+					var details = "request:\n" + JSON.stringify(g.request, null, 2) + "\n\n" +
+						(g.status == "ok" ? "response:\n" + JSON.stringify(g.response, null, 2) : "error:\n" + JSON.stringify(g.error, null, 2));
+					if (res) {
+						row.toggleClass("danger", res.status != "pass").toggleClass("success", res.status == "pass");
+						var replayed = (res.failures || []).join("\n");
+						if (res.ignored) {
+							replayed += (replayed ? "\n" : "") + res.ignored + " ignored difference(s)";
+						}
+						details = "replay (" + res.latency_ms.toFixed(1) + " ms): " + res.status + (replayed ? "\n" + replayed : "") + "\n\n" + details;
+					}
+					var detail = $("<tr>").toggle(res != null && res.status != "pass").append($("<td colspan=6>").append($("<pre>").text(details)));
+					row.append($("<td class=\"text-right\">")
+						.append($("<button class=\"btn btn-default btn-xs\">Details</button>").click(function() { detail.toggle(); }))
+						.append(" ")
+						.append($("<button class=\"btn btn-default btn-xs\">Save</button>").click(function() { saveCall(g.id, name.val(), ignore.val()); }))
+						.append(" ")
+						.append($("<button class=\"btn btn-default btn-xs\">Replay</button>").click(function() { replayGolden(g.id); }))
+						.append(" ")
+						.append($("<button class=\"btn btn-default btn-xs\">Delete</button>").click(function() { deleteCall(g.id, g.name); })));
+					body.append(row).append(detail);
+				});
+			},
+		});
+	};
+	$(document).ready(loadGolden);
+</script>
+{{end}}
 `
 )
@@ -0,0 +1,199 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// get 取出字段的值
+func (o jsonObject) get(key string) (interface{}, bool) {
+	for _, f := range o {
+		if f.Key == key {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+// schemaValidator 按 schema.go 生成的 JSON Schema 校验请求，只支持其中用到的关键字
+type schemaValidator struct {
+	defs   map[string]interface{}
+	errors []string
+}
+
+// validateRequest 校验 v，返回带字段路径的错误，如 "users[1].jobs[0].salary: expected number"
+// v 需要用 json.Decoder.UseNumber 解出
+func validateRequest(schema jsonObject, v interface{}) []string {
+	sv := &schemaValidator{}
+	if defs, ok := schema.get("$defs"); ok {
+		sv.defs, _ = defs.(map[string]interface{})
+	}
+	sv.validate(schema, v, "")
+	return sv.errors
+}
+
+func (sv *schemaValidator) errorf(path, f string, args ...interface{}) {
+	if len(path) == 0 {
+		path = "request"
+	}
+	sv.errors = append(sv.errors, path+": "+fmt.Sprintf(f, args...))
+}
+
+func (sv *schemaValidator) validate(schema jsonObject, v interface{}, path string) {
+	if ref, ok := schema.get("$ref"); ok {
+		def, _ := sv.defs[strings.TrimPrefix(ref.(string), "#/$defs/")].(jsonObject)
+		sv.validate(def, v, path)
+		return
+	}
+	// proto 中 null 等同于没有设置
+	if v == nil {
+		return
+	}
+	if oneOf, ok := schema.get("oneOf"); ok {
+		sv.oneOf(oneOf.([]jsonObject), v, path)
+		return
+	}
+
+	typ, _ := schema.get("type")
+	// 64 位整数是 ["integer", "string"]，字符串也按整数校验
+	if types, ok := typ.([]string); ok && len(types) > 0 {
+		typ = types[0]
+	}
+	switch typ {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			sv.errorf(path, "expected object")
+			return
+		}
+		sv.object(schema, obj, path)
+	case "array":
+		list, ok := v.([]interface{})
+		if !ok {
+			sv.errorf(path, "expected array")
+			return
+		}
+		items, _ := schema.get("items")
+		for i, item := range list {
+			sv.validate(items.(jsonObject), item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			sv.errorf(path, "expected string")
+			return
+		}
+		if pattern, ok := schema.get("pattern"); ok && !regexp.MustCompile(pattern.(string)).MatchString(s) {
+			sv.errorf(path, "expected format %s", pattern)
+			return
+		}
+		if enum, ok := schema.get("enum"); ok {
+			for _, e := range enum.([]string) {
+				if e == s {
+					return
+				}
+			}
+			sv.errorf(path, "expected one of %s", strings.Join(enum.([]string), ", "))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			sv.errorf(path, "expected boolean")
+		}
+	case "integer":
+		sv.integer(schema, v, path)
+	case "number":
+		switch n := v.(type) {
+		case json.Number:
+		case string:
+			// protojson 允许 "NaN"、"Infinity" 和字符串形式的数字
+			if _, err := strconv.ParseFloat(n, 64); err != nil && n != "NaN" && n != "Infinity" && n != "-Infinity" {
+				sv.errorf(path, "expected number")
+			}
+		default:
+			sv.errorf(path, "expected number")
+		}
+	}
+}
+
+// oneOf 满足任一分支即可，都不满足时列出每个分支的错误，如枚举的名字和数字
+func (sv *schemaValidator) oneOf(schemas []jsonObject, v interface{}, path string) {
+	var errs []string
+	for _, s := range schemas {
+		sub := &schemaValidator{defs: sv.defs}
+		sub.validate(s, v, "")
+		if len(sub.errors) == 0 {
+			return
+		}
+		errs = append(errs, strings.TrimPrefix(sub.errors[0], "request: "))
+	}
+	sv.errorf(path, "%s", strings.Join(errs, " or "))
+}
+
+func (sv *schemaValidator) object(schema jsonObject, obj map[string]interface{}, path string) {
+	props, _ := schema.get("properties")
+	properties, _ := props.(jsonObject)
+
+	// map 字段，值的类型由 additionalProperties 给出
+	extra, _ := schema.get("additionalProperties")
+	extraSchema, isMap := extra.(jsonObject)
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		// 服务端的 JSON 解码同时接受 proto 原名和 lowerCamel 写法
+		if _, p, ok := findProperty(properties, k); ok {
+			sv.validate(p, obj[k], joinPath(path, k))
+			continue
+		}
+		if isMap {
+			sv.validate(extraSchema, obj[k], fmt.Sprintf("%s[%q]", path, k))
+			continue
+		}
+		if extra == false {
+			sv.errorf(joinPath(path, k), "unknown field")
+		}
+	}
+}
+
+func (sv *schemaValidator) integer(schema jsonObject, v interface{}, path string) {
+	var s string
+	switch n := v.(type) {
+	case json.Number:
+		s = n.String()
+	case string:
+		// protojson 中 64 位整数用字符串表示
+		if f, _ := schema.get("format"); f != "int64" && f != "uint64" {
+			sv.errorf(path, "expected integer")
+			return
+		}
+		s = n
+	default:
+		sv.errorf(path, "expected integer")
+		return
+	}
+
+	bits := 64
+	if f, _ := schema.get("format"); f == "int32" || f == "uint32" {
+		bits = 32
+	}
+	if max, ok := schema.get("maximum"); ok && max == 255 {
+		bits = 8
+	}
+	if min, ok := schema.get("minimum"); ok && min == 0 {
+		if _, err := strconv.ParseUint(s, 10, bits); err != nil {
+			sv.errorf(path, "expected unsigned %d-bit integer", bits)
+		}
+		return
+	}
+	if _, err := strconv.ParseInt(s, 10, bits); err != nil {
+		sv.errorf(path, "expected %d-bit integer", bits)
+	}
+}
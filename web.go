@@ -7,6 +7,7 @@ import (
 	"html/template"
 	"net/http"
 	"net/http/httputil"
+	"os"
 	"regexp"
 	"sort"
 	"strings"
@@ -17,15 +18,14 @@ import (
 	"github.com/micro/go-micro"
 	"github.com/micro/go-micro/api/server"
 	httpapi "github.com/micro/go-micro/api/server/http"
+	"github.com/micro/go-micro/client"
 	"github.com/micro/go-micro/client/selector"
 	"github.com/micro/go-micro/config/cmd"
 	"github.com/micro/go-micro/registry"
 	"github.com/micro/go-micro/util/log"
-	"github.com/micro/micro/internal/handler"
 	"github.com/micro/micro/internal/helper"
 	"github.com/micro/micro/internal/stats"
 	"github.com/micro/micro/plugin"
-	"github.com/serenize/snaker"
 )
 
 var (
@@ -98,132 +98,14 @@ func (s *srv) proxy() http.Handler {
 	}
 }
 
-func format(v *registry.Value) string {
-	// 如果为空，或者Values为0，就反个{}完事
-	if v == nil || len(v.Values) == 0 {
-		return "{}"
-	}
-	// 声明一个字符串切片
-	var f []string
-	// 取出Values，对其进行格式化
-	for _, k := range v.Values {
-		f = append(f, formatEndpoint(k, 0))
-	}
-	// 最后加上大括号，每一行Value用,连接，格式化输出
-	return fmt.Sprintf("{\n%s\n}", strings.Join(f, ",\n")) // 用逗号连接
-}
-
-func formatEndpoint(v *registry.Value, r int) string { // 格式化每一行的Value
-	// default format is tabbed plus the value plus new line
-
-	fparts := []string{"", "\"%s\":%s"} // 格式化的格式，加上了""和:
-	for i := 0; i < r+1; i++ {
-		fparts[0] += "\t"
-	}
-	// its just a primitive of sorts so return
-	// 只有一层
-	// 普通的Value就只有名字name和类型type，没有再下一步的Values了，这种可以直接通过DefaultMap映射设置默认值
-	if len(v.Values) == 0 {
-		return fmt.Sprintf(strings.Join(fparts, ""), snaker.CamelToSnake(v.Name), formatDefault(v, r)) // 给Type做了映射
-		//return fmt.Sprintf(strings.Join(fparts, ""), snaker.CamelToSnake(v.Name) + "["+strconv.Itoa(r) + "层name]", formatDefault(v.Type) + "["+strconv.Itoa(r) + "层type]") // 给Type做了映射
-		//return fmt.Sprintf(strings.Join(fparts, ""), snaker.CamelToSnake(v.Name), v.Type )
-	}
-
-	// this thing has more things, it's complex
-
-	// 还有层在下面
-	// 那这个就是里面还有东西的，是嵌套的
-	fparts = []string{"", "\"%s\":{ ", "\n"}
-	for i := 0; i < r+1; i++ {
-		fparts[0] += "\t"
-	}
-	vals := []interface{}{snaker.CamelToSnake(v.Name)} // 给Type做了映射
-	//vals := []interface{}{snaker.CamelToSnake(v.Name) +  "["+strconv.Itoa(r) + "层name]"} // 给Type做了映射
-	//vals := []interface{}{snaker.CamelToSnake(v.Name), v.Type}
-
-	for _, val := range v.Values {
-		fparts = append(fparts, "%s,\n")
-		vals = append(vals, formatEndpoint(val, r+1))
-	}
-
-	// at the end
-	l := len(fparts) - 1
-	fparts[l] = fparts[l][:2] // 去掉最后一个逗号
-	fparts = append(fparts, "\n")
-	for i := 0; i < r+1; i++ {
-		fparts = append(fparts, "\t")
-	}
-	fparts = append(fparts, "}")
-	// 将多层的要放进去DefaultMap 以方便 repeated 的寻找
-	repeated := strings.Trim(strings.Trim(fmt.Sprintf(strings.Join(fparts, ""), vals...), "\t"), "\""+v.Name+"\":")
-	//repeated := strings.Trim(fmt.Sprintf(strings.Join(fparts, ""), vals...),"\t")
-	// 加上前面的换行符
-	prefix := []string{""}
-	for i := 0; i < r+1; i++ {
-		prefix[0] += "\t"
-	}
-	result := strings.Join(prefix, "") + repeated
-	// 加入映射
-	DefaultMap[v.Type] = result
-	return fmt.Sprintf(strings.Join(fparts, ""), vals...)
-
-}
-
-var DefaultMap = map[string]string{
-	"string":    "\"zhouxiaojun\"",
-	"bool":      "true",
-	"int32":     "32",
-	"int64":     "64",
-	"uint32":    "32",
-	"uint64":    "64",
-	"float32":   "32.00",
-	"float64":   "64.00",
-	"[]string":  "[\"zhou\",\"xiao\",\"jun\"]",
-	"[]bool":    "[true,true,true]",
-	"[]int32":   "[32,32,32]",
-	"[]int64":   "[64,64,64]",
-	"[]uint8":   "[8,8,8]",
-	"[]uint32":  "[32,32,32]",
-	"[]uint64":  "[64,64,64]",
-	"[]float32": "[32.00,32.00,32.00]",
-	"[]float64": "[64.00,64.00,64.00]",
-}
-
-func formatDefault(v *registry.Value, r int) string {
-
-	if DefaultMap[v.Type] != "" {
-		return DefaultMap[v.Type]
-	}
-
-	// 如果判定是repeated里面的
-	if ReName := "[]" + Capitalize(v.Name); ReName == v.Type+"s" || ReName == v.Type+"es" {
-		//
-		TEMP1 := Capitalize(v.Name[0 : len(v.Name)-1]) // 去掉s
-		TEMP2 := Capitalize(v.Name[0 : len(v.Name)-2]) // 去掉es
-		post := []string{""}
-		for i := 0; i < r+1; i++ {
-			post[0] += "\t"
-		}
-		postfix := strings.Join(post, "")
-		if TEMPS := DefaultMap[TEMP1]; TEMPS != "" {
-			TEMPS_LIST := []string{TEMPS, TEMPS, TEMPS}                                // 可以控制一个切片里有多少个
-			return fmt.Sprintf("[\n%s\n%s]", strings.Join(TEMPS_LIST, ",\n"), postfix) // 用逗号连接
-		} else if TEMPES := DefaultMap[TEMP2]; TEMPES != "" {
-			TEMPES_LIST := []string{TEMPES, TEMPES, TEMPES}
-			return fmt.Sprintf("[\n%s\n%s]", strings.Join(TEMPES_LIST, ",\n"), postfix) // 用逗号连接
-		}
-	}
-
-	return v.Type
+// defaultClient 返回 dashboard 使用的 client
+func defaultClient() client.Client {
+	return *cmd.DefaultOptions().Client
 }
 
-
-func Capitalize(str string) string {
-	vv := []rune(str)
-	if vv[0] >= 97 && vv[0] <= 122 {
-		vv[0] -= 32
-	}
-	return string(vv)
+// defaultRegistry 返回 dashboard 使用的 registry
+func defaultRegistry() registry.Registry {
+	return *cmd.DefaultOptions().Registry
 }
 
 // 各个Handler
@@ -280,6 +162,7 @@ func registryHandler(w http.ResponseWriter, r *http.Request) {
 			// map 转 json
 			b, err := json.Marshal(map[string]interface{}{
 				"services": s,
+				"schemas":  serviceSchemas(s),
 			})
 			if err != nil {
 				http.Error(w, "Error occurred:"+err.Error(), 500)
@@ -327,7 +210,7 @@ func callHandler(w http.ResponseWriter, r *http.Request) {
 
 	sort.Sort(sortedServices{services})
 
-	serviceMap := make(map[string][]*registry.Endpoint)
+	serviceMap := make(map[string]*registry.Service)
 	for _, service := range services {
 		// 取每一个服务名下的
 		s, err := (*cmd.DefaultOptions().Registry).GetService(service.Name)
@@ -337,7 +220,7 @@ func callHandler(w http.ResponseWriter, r *http.Request) {
 		if len(s) == 0 {
 			continue
 		}
-		serviceMap[service.Name] = s[0].Endpoints
+		serviceMap[service.Name] = s[0]
 	}
 
 	if r.Header.Get("Content-Type") == "application/json" {
@@ -358,7 +241,14 @@ func callHandler(w http.ResponseWriter, r *http.Request) {
 
 func render(w http.ResponseWriter, r *http.Request, tmpl string, data interface{}) {
 	t, err := template.New("template").Funcs(template.FuncMap{
-		"format": format,
+		"catalog":        catalog,
+		"enumHints":      enumHints,
+		"format":         format,
+		"formatCompact":  formatCompact,
+		"formatRequest":  formatRequest,
+		"formatResponse": formatResponse,
+		"profiles":       profiles.Names,
+		"defaultProfile": func() string { return defaultProfile },
 	}).Parse(layoutTemplate)
 	if err != nil {
 		http.Error(w, "Error occurred:"+err.Error(), 500)
@@ -391,6 +281,57 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 		Namespace = ctx.String("namespace")
 	}
 
+	if err := loadDescriptorFiles(ctx.StringSlice("descriptor_set")); err != nil {
+		log.Fatal(err)
+	}
+	if err := loadRegisteredDescriptors(ctx.StringSlice("proto_file")); err != nil {
+		log.Fatal(err)
+	}
+	if ctx.Bool("strict") {
+		strictMode = true
+	}
+	if len(ctx.String("field_naming")) > 0 {
+		fieldNaming = ctx.String("field_naming")
+	}
+	if err := checkFieldNaming(fieldNaming); err != nil {
+		log.Fatal(err)
+	}
+	if i := ctx.Int("max_depth"); i > 0 {
+		maxDepth = i
+	}
+	if ctx.Bool("truncate_as_null") {
+		truncateAsNull = true
+	}
+	if len(ctx.String("default_profile")) > 0 {
+		defaultProfile = ctx.String("default_profile")
+	}
+	if err := loadProfileFiles(ctx.StringSlice("profile")); err != nil {
+		log.Fatal(err)
+	}
+	if err := loadHeaderFile(ctx.String("headers_file")); err != nil {
+		log.Fatal(err)
+	}
+	if err := openHistory(ctx.String("history_file"), ctx.Int("history_size")); err != nil {
+		log.Fatal(err)
+	}
+	// 写完排队的调用记录
+	defer history.close()
+	if err := loadCollectionFile(ctx.String("collections_file")); err != nil {
+		log.Fatal(err)
+	}
+	if err := loadEnvironmentFile(ctx.String("environments_file")); err != nil {
+		log.Fatal(err)
+	}
+	if err := loadMockFile(ctx.String("mocks_file")); err != nil {
+		log.Fatal(err)
+	}
+	// mock 启动后出错时记录错误并返回，不用 log.Fatal，否则 defer 不执行，mock 留在 registry 中
+	defer mocks.stopAll()
+	if err := loadGoldenFile(ctx.String("golden_file")); err != nil {
+		log.Log(err)
+		return
+	}
+
 	// Init plugins
 	for _, p := range Plugins() {
 		p.Init(ctx)
@@ -412,8 +353,26 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 	// 注册处理器
 	s.HandleFunc("/client", callHandler)
 	s.HandleFunc("/registry", registryHandler)
+	s.HandleFunc("/descriptors", descriptorHandler)
+	s.HandleFunc("/skeleton", skeletonHandler)
+	s.HandleFunc("/schema", schemaHandler)
+	s.HandleFunc("/grpc", grpcHandler)
 	s.HandleFunc("/terminal", cliHandler)
-	s.HandleFunc("/rpc", handler.RPC)
+	s.HandleFunc("/rpc", rpc)
+	s.HandleFunc("/stream", streamHandler)
+	s.HandleFunc("/headers", headerHandler)
+	s.HandleFunc("/history", historyHandler)
+	s.HandleFunc("/history/replay", replayHandler)
+	s.HandleFunc("/collections", collectionHandler)
+	s.HandleFunc("/collections/import", importHandler)
+	s.HandleFunc("/environments", environmentHandler)
+	s.HandleFunc("/scenarios", scenarioHandler)
+	s.HandleFunc("/load", loadHandler)
+	s.HandleFunc("/fanout", fanoutHandler)
+	s.HandleFunc("/snippet", snippetHandler)
+	s.HandleFunc("/mocks", mocksHandler)
+	s.HandleFunc("/golden", goldenHandler)
+	s.HandleFunc("/golden/replay", goldenReplayHandler)
 	s.HandleFunc("/favicon.ico", faviconHandler)
 	s.PathPrefix("/{service:[a-zA-Z0-9]+}").Handler(s.proxy())
 	s.HandleFunc("/", indexHandler)
@@ -458,15 +417,73 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 	service := micro.NewService(srvOpts...)
 
 	if err := srv.Start(); err != nil {
-		log.Fatal(err)
+		log.Log(err)
+		return
 	}
 
 	// Run server
 	if err := service.Run(); err != nil {
-		log.Fatal(err)
+		log.Log(err)
 	}
 
 	if err := srv.Stop(); err != nil {
+		log.Log(err)
+	}
+}
+
+// loadCommandContext 按命令的参数加载 proto 描述、默认 metadata 和环境，命令没有的参数为空
+func loadCommandContext(ctx *cli.Context) error {
+	return loadCommandFiles(ctx.String("field_naming"), ctx.StringSlice("descriptor_set"), ctx.StringSlice("proto_file"),
+		ctx.String("headers_file"), ctx.String("environments_file"))
+}
+
+// runScenarioCommand 在命令行中运行场景，有场景失败时退出码不为 0
+func runScenarioCommand(ctx *cli.Context) {
+	if ctx.Bool("strict") {
+		strictMode = true
+	}
+	if err := loadCommandContext(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := runScenarioFiles(ctx.Args(), ctx.String("env"), ctx.Bool("json"), os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runLoadCommand 在命令行中压测一个 endpoint
+func runLoadCommand(ctx *cli.Context) {
+	if len(ctx.Args()) < 2 {
+		log.Fatal("USAGE: load [service] [endpoint] [request]")
+	}
+	if err := loadCommandContext(ctx); err != nil {
+		log.Fatal(err)
+	}
+	spec := &loadSpec{
+		Concurrency: ctx.Int("concurrency"),
+		QPS:         ctx.Float64("qps"),
+		Duration:    ctx.Duration("duration").String(),
+		Requests:    ctx.Int("requests"),
+	}
+	spec.Service = ctx.Args().Get(0)
+	spec.Endpoint = ctx.Args().Get(1)
+	spec.Request = "{}"
+	if len(ctx.Args()) > 2 {
+		spec.Request = ctx.Args().Get(2)
+	}
+	spec.Transport = ctx.String("transport")
+	spec.Address = ctx.String("address")
+	spec.Env = ctx.String("env")
+	if err := runLoadTest(spec, ctx.String("out"), os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runReplayCommand 重放套件文件中的 golden 调用，有调用的结果不同时退出码不为 0
+func runReplayCommand(ctx *cli.Context) {
+	if err := loadCommandContext(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := runReplayFiles(ctx.Args(), ctx.StringSlice("ignore"), ctx.Bool("json"), os.Stdout); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -489,9 +506,204 @@ func Commands(options ...micro.Option) []cli.Command {
 				Usage:  "Set the namespace used by the Web proxy e.g. com.example.web",
 				EnvVar: "MICRO_WEB_NAMESPACE",
 			},
+			cli.StringSliceFlag{
+				Name:   "descriptor_set",
+				Usage:  "Load a protobuf descriptor set used to build request templates",
+				EnvVar: "MICRO_WEB_DESCRIPTOR_SET",
+			},
+			cli.StringSliceFlag{
+				Name:   "proto_file",
+				Usage:  "Load a proto file compiled into the dashboard and registered with proto.RegisterFile e.g. hello.proto",
+				EnvVar: "MICRO_WEB_PROTO_FILE",
+			},
+			cli.StringSliceFlag{
+				Name:   "profile",
+				Usage:  "Load a YAML/JSON profile of example values for request templates",
+				EnvVar: "MICRO_WEB_PROFILE",
+			},
+			cli.StringFlag{
+				Name:   "default_profile",
+				Usage:  "Set the default example value profile e.g zero, team, fake",
+				EnvVar: "MICRO_WEB_DEFAULT_PROFILE",
+			},
+			cli.BoolFlag{
+				Name:   "strict",
+				Usage:  "Validate /rpc requests against the endpoint types before calling",
+				EnvVar: "MICRO_WEB_STRICT",
+			},
+			cli.StringFlag{
+				Name:   "field_naming",
+				Usage:  "Set the field naming of request templates, schemas and responses: proto, camel or snake",
+				EnvVar: "MICRO_WEB_FIELD_NAMING",
+			},
+			cli.IntFlag{
+				Name:   "max_depth",
+				Usage:  "Set the max nesting depth of generated request templates",
+				EnvVar: "MICRO_WEB_MAX_DEPTH",
+			},
+			cli.BoolFlag{
+				Name:   "truncate_as_null",
+				Usage:  "Render recursive or too deep messages as null instead of a marker",
+				EnvVar: "MICRO_WEB_TRUNCATE_AS_NULL",
+			},
+			cli.StringFlag{
+				Name:   "headers_file",
+				Usage:  "Persist the default metadata saved for each service to this file",
+				EnvVar: "MICRO_WEB_HEADERS_FILE",
+			},
+			cli.StringFlag{
+				Name:   "history_file",
+				Usage:  "Record calls made through the dashboard to this file, empty to disable",
+				EnvVar: "MICRO_WEB_HISTORY_FILE",
+				Value:  historyFile,
+			},
+			cli.IntFlag{
+				Name:   "history_size",
+				Usage:  "Set the max number of calls kept in the history",
+				EnvVar: "MICRO_WEB_HISTORY_SIZE",
+				Value:  historySize,
+			},
+			cli.StringFlag{
+				Name:   "collections_file",
+				Usage:  "Persist saved request collections to this file, empty to keep them in memory",
+				EnvVar: "MICRO_WEB_COLLECTIONS_FILE",
+				Value:  collectionFile,
+			},
+			cli.StringFlag{
+				Name:   "environments_file",
+				Usage:  "Persist the environment variables used in request placeholders to this file, empty to keep them in memory",
+				EnvVar: "MICRO_WEB_ENVIRONMENTS_FILE",
+				Value:  environmentFile,
+			},
+			cli.StringFlag{
+				Name:   "mocks_file",
+				Usage:  "Persist mock services to this file, empty to keep them in memory",
+				EnvVar: "MICRO_WEB_MOCKS_FILE",
+				Value:  mockFile,
+			},
+			cli.StringFlag{
+				Name:   "golden_file",
+				Usage:  "Persist the calls marked as golden to this file, empty to keep them in memory",
+				EnvVar: "MICRO_WEB_GOLDEN_FILE",
+				Value:  goldenFile,
+			},
 		},
 	}
 
+	command.Subcommands = append(command.Subcommands, cli.Command{
+		Name:      "scenario",
+		Usage:     "Run the call scenarios in the given files",
+		ArgsUsage: "[file...]",
+		Action:    runScenarioCommand,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:   "env",
+				Usage:  "Set the environment used for {{env.xxx}} placeholders, defaults to the one in the scenario",
+				EnvVar: "MICRO_WEB_ENV",
+			},
+			cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the results as JSON",
+			},
+			cli.StringSliceFlag{
+				Name:   "descriptor_set",
+				Usage:  "Load a protobuf descriptor set used to validate requests and name response fields",
+				EnvVar: "MICRO_WEB_DESCRIPTOR_SET",
+			},
+			cli.StringSliceFlag{
+				Name:   "proto_file",
+				Usage:  "Load a proto file compiled into the dashboard and registered with proto.RegisterFile e.g. hello.proto",
+				EnvVar: "MICRO_WEB_PROTO_FILE",
+			},
+			cli.BoolFlag{
+				Name:   "strict",
+				Usage:  "Validate requests against the endpoint types before calling",
+				EnvVar: "MICRO_WEB_STRICT",
+			},
+			cli.StringFlag{
+				Name:   "field_naming",
+				Usage:  "Set the response field naming: proto, camel or snake",
+				EnvVar: "MICRO_WEB_FIELD_NAMING",
+			},
+			cli.StringFlag{
+				Name:   "headers_file",
+				Usage:  "Load the default metadata saved for each service from this file",
+				EnvVar: "MICRO_WEB_HEADERS_FILE",
+			},
+			cli.StringFlag{
+				Name:   "environments_file",
+				Usage:  "Load the environment variables used in request placeholders from this file",
+				EnvVar: "MICRO_WEB_ENVIRONMENTS_FILE",
+				Value:  environmentFile,
+			},
+		},
+	})
+
+	command.Subcommands = append(command.Subcommands, cli.Command{
+		Name:      "load",
+		Usage:     "Load test an endpoint with a fixed concurrency, QPS or duration",
+		ArgsUsage: "[service] [endpoint] [request]",
+		Action:    runLoadCommand,
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "concurrency",
+				Usage: "Set the number of concurrent requests",
+				Value: 10,
+			},
+			cli.Float64Flag{
+				Name:  "qps",
+				Usage: "Limit the requests per second, 0 for no limit",
+			},
+			cli.DurationFlag{
+				Name:  "duration",
+				Usage: "Set how long to run, defaults to 10s when no request count is set",
+			},
+			cli.IntFlag{
+				Name:  "requests",
+				Usage: "Stop after this many requests",
+			},
+			cli.StringFlag{
+				Name:  "transport",
+				Usage: "Set the transport: micro or grpc",
+				Value: transportMicro,
+			},
+			cli.StringFlag{
+				Name:  "address",
+				Usage: "Send every request to this address",
+			},
+			cli.StringFlag{
+				Name:   "env",
+				Usage:  "Set the environment used for {{env.xxx}} placeholders",
+				EnvVar: "MICRO_WEB_ENV",
+			},
+			cli.StringFlag{
+				Name:  "out",
+				Usage: "Write the result as JSON to this file",
+			},
+			cli.StringSliceFlag{
+				Name:   "descriptor_set",
+				Usage:  "Load a protobuf descriptor set used to name response fields",
+				EnvVar: "MICRO_WEB_DESCRIPTOR_SET",
+			},
+			cli.StringSliceFlag{
+				Name:   "proto_file",
+				Usage:  "Load a proto file compiled into the dashboard and registered with proto.RegisterFile e.g. hello.proto",
+				EnvVar: "MICRO_WEB_PROTO_FILE",
+			},
+			cli.StringFlag{
+				Name:   "headers_file",
+				Usage:  "Load the default metadata saved for each service from this file",
+				EnvVar: "MICRO_WEB_HEADERS_FILE",
+			},
+			cli.StringFlag{
+				Name:   "environments_file",
+				Usage:  "Load the environment variables used in request placeholders from this file",
+				EnvVar: "MICRO_WEB_ENVIRONMENTS_FILE",
+				Value:  environmentFile,
+			},
+		},
+	})
+
 	for _, p := range Plugins() {
 		if cmds := p.Commands(); len(cmds) > 0 {
 			command.Subcommands = append(command.Subcommands, cmds...)
@@ -502,5 +714,42 @@ func Commands(options ...micro.Option) []cli.Command {
 		}
 	}
 
-	return []cli.Command{command}
+	replay := cli.Command{
+		Name:      "replay",
+		Usage:     "Replay the golden calls in the given suite files and report the fields that changed",
+		ArgsUsage: "[file...]",
+		Action:    runReplayCommand,
+		Flags: []cli.Flag{
+			cli.StringSliceFlag{
+				Name:  "ignore",
+				Usage: "Ignore these fields when comparing responses e.g. $..updated_at",
+			},
+			cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the results as JSON",
+			},
+			cli.StringSliceFlag{
+				Name:   "descriptor_set",
+				Usage:  "Load a protobuf descriptor set used to name response fields",
+				EnvVar: "MICRO_WEB_DESCRIPTOR_SET",
+			},
+			cli.StringSliceFlag{
+				Name:   "proto_file",
+				Usage:  "Load a proto file compiled into the dashboard and registered with proto.RegisterFile e.g. hello.proto",
+				EnvVar: "MICRO_WEB_PROTO_FILE",
+			},
+			cli.StringFlag{
+				Name:   "field_naming",
+				Usage:  "Set the response field naming: proto, camel or snake",
+				EnvVar: "MICRO_WEB_FIELD_NAMING",
+			},
+			cli.StringFlag{
+				Name:   "headers_file",
+				Usage:  "Load the default metadata saved for each service from this file",
+				EnvVar: "MICRO_WEB_HEADERS_FILE",
+			},
+		},
+	}
+
+	return []cli.Command{command, replay}
 }
@@ -2,27 +2,62 @@ package web
 
 import (
 	"encoding/json"
-	"fmt"
 	"git.code.oa.com/cloud_industry/epc/epc-cli/command"
 	"github.com/gorilla/mux"
 	"github.com/micro/go-micro"
 	"github.com/micro/go-micro/api/server"
 	httpapi "github.com/micro/go-micro/api/server/http"
+	"github.com/micro/go-micro/client"
 	"github.com/micro/go-micro/client/selector"
 	"github.com/micro/go-micro/registry"
 	"github.com/micro/go-micro/service/grpc"
-	"github.com/serenize/snaker"
 	"github.com/spf13/cobra"
 	"html/template"
 	"net/http"
 	"net/http/httputil"
+	"os"
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 )
 
 func init() {
+	webCmd.PersistentFlags().StringSliceVar(&descriptorSets, "descriptor_set", nil, "protoc --descriptor_set_out 生成的文件，用于生成请求示例")
+	webCmd.PersistentFlags().StringSliceVar(&protoFiles, "proto_file", nil, "编译进 dashboard、通过 proto.RegisterFile 注册的 proto 文件，如 hello.proto")
+	webCmd.Flags().StringSliceVar(&profileFiles, "profile", nil, "请求示例取值规则文件（YAML/JSON）")
+	webCmd.Flags().StringVar(&defaultProfile, "default_profile", defaultProfile, "默认使用的请求示例 profile：zero、team、fake 或自定义")
+	webCmd.PersistentFlags().BoolVar(&strictMode, "strict", false, "调用前按 endpoint 的类型校验 /rpc 请求")
+	webCmd.PersistentFlags().StringVar(&fieldNaming, "field_naming", fieldNaming, "字段名的写法：proto（proto 原名）、camel（lowerCamel）或 snake")
+	webCmd.Flags().IntVar(&maxDepth, "max_depth", maxDepth, "请求示例中消息嵌套的最大层数")
+	webCmd.PersistentFlags().StringVar(&headerFile, "headers_file", "", "保存每个服务默认 metadata 的文件")
+	webCmd.Flags().StringVar(&historyFile, "history_file", historyFile, "保存调用历史的文件，为空时不记录")
+	webCmd.Flags().IntVar(&historySize, "history_size", historySize, "最多保存的调用历史条数")
+	webCmd.Flags().StringVar(&collectionFile, "collections_file", collectionFile, "保存请求集合的文件，为空时只保存在内存中")
+	webCmd.PersistentFlags().StringVar(&environmentFile, "environments_file", environmentFile, "保存环境变量的文件，为空时只保存在内存中")
+	webCmd.Flags().StringVar(&mockFile, "mocks_file", mockFile, "保存 mock 服务的文件，为空时只保存在内存中")
+	webCmd.Flags().StringVar(&goldenFile, "golden_file", goldenFile, "保存 golden 调用的文件，为空时只保存在内存中")
+	webCmd.Flags().BoolVar(&truncateAsNull, "truncate_as_null", false, "自引用或超过最大层数的消息写成 null，而不是 \"<recursive User>\" 这样的标记")
+	scenarioCmd.Flags().StringVar(&scenarioEnv, "env", "", "替换 {{env.xxx}} 使用的环境，默认使用场景中指定的环境")
+	scenarioCmd.Flags().BoolVar(&scenarioJSON, "json", false, "以 JSON 格式输出结果")
+	loadCmd.Flags().StringVar(&loadTestSpec.Env, "env", "", "替换 {{env.xxx}} 使用的环境")
+	loadCmd.Flags().StringVar(&loadTestSpec.Transport, "transport", transportMicro, "调用方式：micro 或 grpc")
+	loadCmd.Flags().StringVar(&loadTestSpec.Address, "address", "", "固定发往这个地址")
+	loadCmd.Flags().IntVar(&loadTestSpec.Concurrency, "concurrency", 10, "同时发出请求的数量")
+	loadCmd.Flags().Float64Var(&loadTestSpec.QPS, "qps", 0, "每秒最多发出的请求数，为 0 时不限制")
+	loadCmd.Flags().DurationVar(&loadDuration, "duration", 0, "压测的时长，不指定时长和请求数时为 10s")
+	loadCmd.Flags().IntVar(&loadTestSpec.Requests, "requests", 0, "总请求数，为 0 时按时长")
+	loadCmd.Flags().StringVar(&loadOut, "out", "", "把结果以 JSON 格式写入这个文件")
+	replayCmd.Flags().StringSliceVar(&replayIgnore, "ignore", nil, "比较时额外忽略的字段，如 $..updated_at")
+	replayCmd.Flags().BoolVar(&replayJSON, "json", false, "以 JSON 格式输出结果")
+	replayCmd.Flags().StringSliceVar(&descriptorSets, "descriptor_set", nil, "protoc --descriptor_set_out 生成的文件，用于给响应的字段命名")
+	replayCmd.Flags().StringSliceVar(&protoFiles, "proto_file", nil, "编译进 dashboard、通过 proto.RegisterFile 注册的 proto 文件，如 hello.proto")
+	replayCmd.Flags().StringVar(&fieldNaming, "field_naming", fieldNaming, "响应字段名的写法：proto、camel 或 snake")
+	replayCmd.Flags().StringVar(&headerFile, "headers_file", "", "保存每个服务默认 metadata 的文件")
+	webCmd.AddCommand(scenarioCmd)
+	webCmd.AddCommand(loadCmd)
 	command.RootCmd.AddCommand(webCmd)
+	command.RootCmd.AddCommand(replayCmd)
 }
 
 var webCmd = &cobra.Command{
@@ -32,6 +67,33 @@ var webCmd = &cobra.Command{
 	RunE:  web,
 }
 
+// scenarioCmd 在命令行中运行场景，不启动 dashboard
+var scenarioCmd = &cobra.Command{
+	Use:          "scenario [file...]",
+	Short:        "按顺序执行场景文件中的调用",
+	Args:         cobra.MinimumNArgs(1),
+	SilenceUsage: true,
+	RunE:         runScenarios,
+}
+
+// loadCmd 在命令行中压测一个 endpoint
+var loadCmd = &cobra.Command{
+	Use:          "load [service] [endpoint] [request]",
+	Short:        "以固定的并发、QPS 或时长压测 endpoint",
+	Args:         cobra.RangeArgs(2, 3),
+	SilenceUsage: true,
+	RunE:         runLoad,
+}
+
+// replayCmd 重放 dashboard 中标记为 golden 的调用，逐个字段比较结果
+var replayCmd = &cobra.Command{
+	Use:          "replay [file...]",
+	Short:        "重放 golden 调用并报告和记录的结果不同的字段",
+	Args:         cobra.MinimumNArgs(1),
+	SilenceUsage: true,
+	RunE:         runReplay,
+}
+
 var (
 	re = regexp.MustCompile("^[a-zA-Z0-9]+([a-zA-Z0-9-]*[a-zA-Z0-9]*)?$")
 	// Default server name
@@ -50,6 +112,26 @@ var (
 	statsURL       string
 
 	service micro.Service
+	// 启动时加载的 proto descriptor set 文件
+	descriptorSets []string
+	// 启动时加载的编译进来的 proto 文件
+	protoFiles []string
+	// 启动时加载的请求示例 profile 文件
+	profileFiles []string
+	// 保存每个服务默认 metadata 的文件
+	headerFile string
+	// 命令行中运行场景使用的环境
+	scenarioEnv string
+	// 为 true 时场景的结果以 JSON 格式输出
+	scenarioJSON bool
+	// 命令行中压测的参数
+	loadTestSpec = &loadSpec{}
+	loadDuration time.Duration
+	loadOut      string
+	// 重放时额外忽略的字段
+	replayIgnore []string
+	// 为 true 时重放的结果以 JSON 格式输出
+	replayJSON bool
 )
 
 type srv struct {
@@ -104,6 +186,16 @@ func (s *srv) proxy() http.Handler {
 	}
 }
 
+// defaultClient 返回 dashboard 使用的 client
+func defaultClient() client.Client {
+	return service.Client()
+}
+
+// defaultRegistry 返回 dashboard 使用的 registry
+func defaultRegistry() registry.Registry {
+	return service.Client().Options().Registry
+}
+
 func faviconHandler(w http.ResponseWriter, r *http.Request) {
 	return
 }
@@ -159,6 +251,7 @@ func registryHandler(w http.ResponseWriter, r *http.Request) {
 		if r.Header.Get("Content-Type") == "application/json" {
 			b, err := json.Marshal(map[string]interface{}{
 				"services": s,
+				"schemas":  serviceSchemas(s),
 			})
 			if err != nil {
 				http.Error(w, "Error occurred:"+err.Error(), http.StatusInternalServerError)
@@ -207,7 +300,7 @@ func callHandler(w http.ResponseWriter, r *http.Request) {
 
 	sort.Sort(sortedServices{services})
 
-	serviceMap := make(map[string][]*registry.Endpoint)
+	serviceMap := make(map[string]*registry.Service)
 	for _, service := range services {
 		s, err := (client.Options().Registry).GetService(service.Name)
 		if err != nil {
@@ -216,7 +309,7 @@ func callHandler(w http.ResponseWriter, r *http.Request) {
 		if len(s) == 0 {
 			continue
 		}
-		serviceMap[service.Name] = s[0].Endpoints
+		serviceMap[service.Name] = s[0]
 	}
 
 	if r.Header.Get("Content-Type") == "application/json" {
@@ -235,123 +328,16 @@ func callHandler(w http.ResponseWriter, r *http.Request) {
 	render(w, r, callTemplate, serviceMap)
 }
 
-func format(v *registry.Value) string {
-	// 如果为空，或者Values为0，就反个{}完事
-	if v == nil || len(v.Values) == 0 {
-		return "{}"
-	}
-	// 声明一个字符串切片
-	var f []string
-	// 取出Values，对其进行格式化
-	for _, k := range v.Values {
-		f = append(f, formatEndpoint(k, 0))
-	}
-	// 最后加上大括号，每一行Value用,连接，格式化输出
-	return fmt.Sprintf("{\n%s}", strings.Join(f, ",")) // 用逗号连接
-}
-
-func formatEndpoint(v *registry.Value, r int) string { // 格式化每一行的Value
-	fparts := []string{"", "\"%s\":%s", "\n"} // 格式化的格式，加上了""和:
-	for i := 0; i < r+1; i++ {
-		fparts[0] += "\t"
-	}
-	// 只有一层
-	// 普通的Value就只有名字name和类型type，没有再下一步的Values了，这种可以直接通过DefaultMap映射设置默认值
-	if len(v.Values) == 0 {
-		return fmt.Sprintf(strings.Join(fparts, ""), snaker.CamelToSnake(v.Name), formatDefault(v, r))
-	}
-
-	// 那这个就是里面还有东西的，是嵌套的
-	fparts = []string{"", "\"%s\":{ ", "\n"}
-	for i := 0; i < r+1; i++ {
-		fparts[0] += "\t"
-	}
-	vals := []interface{}{snaker.CamelToSnake(v.Name)}
-	for _, val := range v.Values {
-		fparts = append(fparts, "%s,")
-		vals = append(vals, formatEndpoint(val, r+1))
-	}
-
-	// 末尾的格式
-	l := len(fparts) - 1
-	fparts[l] = fparts[l][:2] // 去掉最后一个逗号
-	for i := 0; i < r+1; i++ {
-		fparts[l] += "\t"
-	}
-	fparts = append(fparts, "}\n")
-	// 将多层的要放进去DefaultMap 以方便 repeated 的寻找
-	repeated := strings.Trim(strings.Trim(fmt.Sprintf(strings.Join(fparts, ""), vals...),"\t"), "\"" + v.Name + "\":")
-	// 加上上面为了去掉"v.Name"所去掉的的换行符
-	prefix := []string{""}
-	for i := 0; i < r+1; i++ {
-		prefix[0] += "\t"
-	}
-	result := strings.Join(prefix,"") + repeated
-	// 加入映射
-	DefaultMap[v.Type] = result
-
-	return fmt.Sprintf(strings.Join(fparts, ""), vals...)
-
-}
-var DefaultMap = map[string]string{
-	"string":    "\"zhouxiaojun\"",
-	"bool":      "true",
-	"int32":     "32",
-	"int64":     "64",
-	"uint32":    "32",
-	"uint64":    "64",
-	"float32":   "32.00",
-	"float64":   "64.00",
-	"[]string":  "[\"zhou\",\"xiao\",\"jun\"]",
-	"[]bool":    "[true,true,true]",
-	"[]int32":   "[32,32,32]",
-	"[]int64":   "[64,64,64]",
-	"[]uint8":   "[8,8,8]",
-	"[]uint32":  "[32,32,32]",
-	"[]uint64":  "[64,64,64]",
-	"[]float32": "[32.00,32.00,32.00]",
-	"[]float64": "[64.00,64.00,64.00]",
-}
-
-func formatDefault(v *registry.Value,r int) string {
-
-	if DefaultMap[v.Type] != "" {
-		return DefaultMap[v.Type]
-	}
-
-	// 如果判定是repeated里面的
-	if ReName := "[]" + Capitalize(v.Name); ReName == v.Type+"s" || ReName == v.Type+"es" {
-		//
-		TEMP1 := Capitalize(v.Name[0:len(v.Name)-1]) // 去掉s
-		TEMP2 := Capitalize(v.Name[0:len(v.Name)-2]) // 去掉es
-		post := []string{""}
-		for i := 0; i < r+1; i++ {
-			post[0] += "\t"
-		}
-		postfix := strings.Join(post,"")
-		if TEMPS := DefaultMap[TEMP1] ; TEMPS != "" {
-			TEMPS_LIST := []string{TEMPS,TEMPS,TEMPS} // 可以控制一个切片里有多少个
-			return  fmt.Sprintf("[\n%s%s]", strings.Join(TEMPS_LIST, ","), postfix) // 用逗号连接
-		} else if TEMPES := DefaultMap[TEMP2] ; TEMPES != "" {
-			TEMPES_LIST := []string{TEMPES,TEMPES,TEMPES}
-			return  fmt.Sprintf("[\n%s%s]", strings.Join(TEMPES_LIST, ","), postfix) // 用逗号连接
-		}
-	}
-
-	return v.Type
-}
-
-func Capitalize(str string) string {
-	vv := []rune(str)
-	if vv[0] >= 97 && vv[0] <= 122 {
-		vv[0] -= 32
-	}
-	return string(vv)
-}
-
 func render(w http.ResponseWriter, r *http.Request, tmpl string, data interface{}) {
 	t, err := template.New("template").Funcs(template.FuncMap{
-		"format": format,
+		"catalog":        catalog,
+		"enumHints":      enumHints,
+		"format":         format,
+		"formatCompact":  formatCompact,
+		"formatRequest":  formatRequest,
+		"formatResponse": formatResponse,
+		"profiles":       profiles.Names,
+		"defaultProfile": func() string { return defaultProfile },
 	}).Parse(layoutTemplate)
 	if err != nil {
 		http.Error(w, "Error occurred:"+err.Error(), 500)
@@ -371,6 +357,44 @@ func render(w http.ResponseWriter, r *http.Request, tmpl string, data interface{
 	}
 }
 
+func runScenarios(cmd *cobra.Command, args []string) error {
+	service = grpc.NewService(micro.Name(Name))
+	service.Init()
+
+	if err := loadCommandFiles(fieldNaming, descriptorSets, protoFiles, headerFile, environmentFile); err != nil {
+		return err
+	}
+	return runScenarioFiles(args, scenarioEnv, scenarioJSON, os.Stdout)
+}
+
+func runLoad(cmd *cobra.Command, args []string) error {
+	service = grpc.NewService(micro.Name(Name))
+	service.Init()
+
+	if err := loadCommandFiles(fieldNaming, descriptorSets, protoFiles, headerFile, environmentFile); err != nil {
+		return err
+	}
+	loadTestSpec.Service = args[0]
+	loadTestSpec.Endpoint = args[1]
+	loadTestSpec.Request = "{}"
+	if len(args) > 2 {
+		loadTestSpec.Request = args[2]
+	}
+	loadTestSpec.Duration = loadDuration.String()
+	return runLoadTest(loadTestSpec, loadOut, os.Stdout)
+}
+
+func runReplay(cmd *cobra.Command, args []string) error {
+	service = grpc.NewService(micro.Name(Name))
+	service.Init()
+
+	// replay 命令没有 environments_file 参数，不加载环境
+	if err := loadCommandFiles(fieldNaming, descriptorSets, protoFiles, headerFile, ""); err != nil {
+		return err
+	}
+	return runReplayFiles(args, replayIgnore, replayJSON, os.Stdout)
+}
+
 func web(cmd *cobra.Command, args []string) error {
 	// Initialise Server
 	srvOpts := make([]micro.Option, 0)
@@ -378,6 +402,41 @@ func web(cmd *cobra.Command, args []string) error {
 	service = grpc.NewService(srvOpts...)
 	service.Init()
 
+	if err := checkFieldNaming(fieldNaming); err != nil {
+		return err
+	}
+	if err := loadDescriptorFiles(descriptorSets); err != nil {
+		return err
+	}
+	if err := loadRegisteredDescriptors(protoFiles); err != nil {
+		return err
+	}
+	if err := loadProfileFiles(profileFiles); err != nil {
+		return err
+	}
+	if err := loadHeaderFile(headerFile); err != nil {
+		return err
+	}
+	if err := openHistory(historyFile, historySize); err != nil {
+		return err
+	}
+	// 写完排队的调用记录
+	defer history.close()
+	if err := loadCollectionFile(collectionFile); err != nil {
+		return err
+	}
+	if err := loadEnvironmentFile(environmentFile); err != nil {
+		return err
+	}
+	if err := loadMockFile(mockFile); err != nil {
+		return err
+	}
+	// service.Run 出错返回时也要停掉 mock
+	defer mocks.stopAll()
+	if err := loadGoldenFile(goldenFile); err != nil {
+		return err
+	}
+
 	// Init HTTP Server
 	var h http.Handler
 	r := mux.NewRouter()
@@ -386,8 +445,26 @@ func web(cmd *cobra.Command, args []string) error {
 
 	s.HandleFunc("/client", callHandler)
 	s.HandleFunc("/registry", registryHandler)
+	s.HandleFunc("/descriptors", descriptorHandler)
+	s.HandleFunc("/skeleton", skeletonHandler)
+	s.HandleFunc("/schema", schemaHandler)
+	s.HandleFunc("/grpc", grpcHandler)
 	s.HandleFunc("/terminal", cliHandler)
 	s.HandleFunc("/rpc", rpc)
+	s.HandleFunc("/stream", streamHandler)
+	s.HandleFunc("/headers", headerHandler)
+	s.HandleFunc("/history", historyHandler)
+	s.HandleFunc("/history/replay", replayHandler)
+	s.HandleFunc("/collections", collectionHandler)
+	s.HandleFunc("/collections/import", importHandler)
+	s.HandleFunc("/environments", environmentHandler)
+	s.HandleFunc("/scenarios", scenarioHandler)
+	s.HandleFunc("/load", loadHandler)
+	s.HandleFunc("/fanout", fanoutHandler)
+	s.HandleFunc("/snippet", snippetHandler)
+	s.HandleFunc("/mocks", mocksHandler)
+	s.HandleFunc("/golden", goldenHandler)
+	s.HandleFunc("/golden/replay", goldenReplayHandler)
 	s.HandleFunc("/favicon.ico", faviconHandler)
 	s.PathPrefix("/{service:[a-zA-Z0-9]+}").Handler(s.proxy())
 	s.HandleFunc("/", indexHandler)
@@ -0,0 +1,185 @@
+package web
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/micro/go-micro/registry"
+)
+
+// wellKnownFields 是 well-known type 生成的 Go 结构经 go-micro extractor 提取后的字段名
+// extractor 只给出不带包名的类型名，业务消息也可能叫 Timestamp，所以字段也要对得上才算
+var wellKnownFields = map[string][]string{
+	"google.protobuf.Timestamp":   {"seconds", "nanos"},
+	"google.protobuf.Duration":    {"seconds", "nanos"},
+	"google.protobuf.Struct":      {"fields"},
+	"google.protobuf.Value":       {"isValue_Kind"},
+	"google.protobuf.ListValue":   {"values"},
+	"google.protobuf.Any":         {"type_url", "value"},
+	"google.protobuf.Empty":       {},
+	"google.protobuf.FieldMask":   {"paths"},
+	"google.protobuf.DoubleValue": {"value"},
+	"google.protobuf.FloatValue":  {"value"},
+	"google.protobuf.Int64Value":  {"value"},
+	"google.protobuf.UInt64Value": {"value"},
+	"google.protobuf.Int32Value":  {"value"},
+	"google.protobuf.UInt32Value": {"value"},
+	"google.protobuf.BoolValue":   {"value"},
+	"google.protobuf.StringValue": {"value"},
+	"google.protobuf.BytesValue":  {"value"},
+}
+
+// protoimpl 的内部字段，新版 protoc-gen-go 生成的结构都带着，没有 json tag 时 extractor 用类型名作字段名
+var internalFields = map[string]bool{
+	"MessageState":  true,
+	"int32":         true,
+	"unknownFields": true,
+}
+
+// wrapperTypes 是包装类型对应的基本类型，protojson 中直接写成基本类型的值
+var wrapperTypes = map[string]string{
+	"google.protobuf.DoubleValue": "float64",
+	"google.protobuf.FloatValue":  "float32",
+	"google.protobuf.Int64Value":  "int64",
+	"google.protobuf.UInt64Value": "uint64",
+	"google.protobuf.Int32Value":  "int32",
+	"google.protobuf.UInt32Value": "uint32",
+	"google.protobuf.BoolValue":   "bool",
+	"google.protobuf.StringValue": "string",
+	"google.protobuf.BytesValue":  "[]byte",
+}
+
+// wellKnownType 识别 proto 全名的 well-known type，如 proto 描述中的 .google.protobuf.Timestamp
+func wellKnownType(typ string) (string, bool) {
+	typ = strings.TrimPrefix(typ, ".")
+	if _, ok := wellKnownFields[typ]; !ok {
+		return "", false
+	}
+	return typ, true
+}
+
+// wellKnown 识别 registry 中的 well-known type，v 是字段本身，为空或没有展开时按类型名在 catalog 中找结构
+// 只有字段和 well-known type 一致时才算，找不到结构时当作普通消息
+func (c *typeCatalog) wellKnown(typ string, v *registry.Value) (string, bool) {
+	typ = strings.TrimPrefix(strings.TrimPrefix(typ, "*"), ".")
+	if strings.HasPrefix(typ, "google.protobuf.") {
+		return wellKnownType(typ)
+	}
+	full := "google.protobuf." + typ
+	want, ok := wellKnownFields[full]
+	if !ok {
+		return "", false
+	}
+	// 旧版生成的 Empty 没有字段，和没有展开的消息分不开，但两者都写成 {}
+	if v != nil && len(v.Values) == 0 && len(want) == 0 {
+		return full, true
+	}
+	if (v == nil || len(v.Values) == 0) && c != nil {
+		v = c.types[typ]
+	}
+	if v == nil || len(v.Values) == 0 {
+		return "", false
+	}
+	found := 0
+	for _, f := range v.Values {
+		switch {
+		case internalFields[f.Name]:
+		case contains(want, f.Name):
+			found++
+		default:
+			return "", false
+		}
+	}
+	if found != len(want) {
+		return "", false
+	}
+	return full, true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// mapType 解析 map[string]*Job 形式的类型名
+func mapType(typ string) (key, val string, ok bool) {
+	if !strings.HasPrefix(typ, "map[") {
+		return "", "", false
+	}
+	depth := 0
+	for i := 3; i < len(typ); i++ {
+		switch typ[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return typ[4:i], strings.TrimPrefix(typ[i+1:], "*"), true
+			}
+		}
+	}
+	return "", "", false
+}
+
+func isBytes(typ string) bool {
+	return typ == "[]uint8" || typ == "[]byte"
+}
+
+// bytesValue 按 protojson 的约定把 bytes 写成 base64
+func bytesValue(values valueSource, name string) string {
+	v, _ := values(name, "string")
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprint(v)))
+}
+
+// wellKnownValue 给出 well-known type 的示例值
+func wellKnownValue(values valueSource, name, full string) interface{} {
+	if scalar, ok := wrapperTypes[full]; ok {
+		if isBytes(scalar) {
+			return bytesValue(values, name)
+		}
+		v, _ := values(name, scalar)
+		return v
+	}
+	v, _ := values(name, full)
+	return v
+}
+
+// mapKey 把示例值写成 JSON 对象的键
+func mapKey(values valueSource, name, typ string) string {
+	if v, ok := values(name, typ); ok {
+		return fmt.Sprint(v)
+	}
+	return "key"
+}
+
+// wellKnownSchemas 是 well-known type 按 protojson 表示时的 JSON Schema
+var wellKnownSchemas = map[string]jsonObject{
+	"google.protobuf.Timestamp": {{"type", "string"}, {"format", "date-time"}},
+	"google.protobuf.Duration":  {{"type", "string"}, {"pattern", `^-?[0-9]+(\.[0-9]{1,9})?s$`}},
+	"google.protobuf.Struct":    {{"type", "object"}},
+	"google.protobuf.Value":     {},
+	"google.protobuf.ListValue": {{"type", "array"}},
+	"google.protobuf.Any": {
+		{"type", "object"},
+		{"properties", jsonObject{{"@type", jsonObject{{"type", "string"}}}}},
+	},
+	"google.protobuf.Empty":     {{"type", "object"}, {"additionalProperties", false}},
+	"google.protobuf.FieldMask": {{"type", "string"}},
+}
+
+var bytesSchema = jsonObject{{"type", "string"}, {"contentEncoding", "base64"}}
+
+func wellKnownSchema(full string) jsonObject {
+	if scalar, ok := wrapperTypes[full]; ok {
+		if isBytes(scalar) {
+			return bytesSchema
+		}
+		return scalarSchemas[scalar]
+	}
+	return wellKnownSchemas[full]
+}
@@ -0,0 +1,189 @@
+package web
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/micro/go-micro/registry"
+)
+
+// field 按 a.b 找 registry 中的字段
+func field(v *registry.Value, path string) *registry.Value {
+	for _, name := range strings.Split(path, ".") {
+		var found *registry.Value
+		for _, f := range v.Values {
+			if f.Name == name {
+				found = f
+			}
+		}
+		if found == nil {
+			panic("no field " + path)
+		}
+		v = found
+	}
+	return v
+}
+
+func TestWellKnown(t *testing.T) {
+	users := testService("go.micro.srv.users", new(Users))
+	clock := testService("go.micro.srv.clock", new(Clock))
+	get := testEndpoint(users, "Users.Get").Request
+	update := testEndpoint(users, "Users.Update").Request
+	schedule := testEndpoint(clock, "Clock.Schedule").Request
+
+	tests := []struct {
+		name    string
+		catalog *typeCatalog
+		typ     string
+		value   *registry.Value
+		want    string
+	}{
+		{name: "timestamp", catalog: catalog(users), value: field(get, "created_at"), want: "google.protobuf.Timestamp"},
+		{name: "wrapper", catalog: catalog(users), value: field(get, "age"), want: "google.protobuf.Int64Value"},
+		{name: "duration", catalog: catalog(users), value: field(update, "ttl"), want: "google.protobuf.Duration"},
+		{name: "struct", catalog: catalog(users), value: field(update, "extra"), want: "google.protobuf.Struct"},
+		{name: "any", catalog: catalog(users), value: field(update, "detail"), want: "google.protobuf.Any"},
+		// 第三层的字段没有展开，按类型名在 catalog 中找到结构
+		{name: "unexpanded timestamp", catalog: catalog(users), value: field(update, "user.created_at"), want: "google.protobuf.Timestamp"},
+		{name: "unexpanded wrapper", catalog: catalog(users), value: field(update, "user.age"), want: "google.protobuf.Int64Value"},
+		{name: "proto name", typ: ".google.protobuf.Duration", want: "google.protobuf.Duration"},
+		// 同名的业务消息
+		{name: "business timestamp", catalog: catalog(clock), value: field(schedule, "at")},
+		{name: "business any", catalog: catalog(clock), value: field(schedule, "payload")},
+		{name: "unknown structure", catalog: newTypeCatalog(), typ: "Timestamp"},
+		{name: "message", catalog: catalog(users), value: field(update, "group")},
+	}
+	for _, tt := range tests {
+		typ := tt.typ
+		if tt.value != nil {
+			typ = tt.value.Type
+		}
+		got, ok := tt.catalog.wellKnown(typ, tt.value)
+		if ok != (len(tt.want) > 0) || got != tt.want {
+			t.Errorf("%s: wellKnown(%q) = %q, %v, expected %q", tt.name, typ, got, ok, tt.want)
+		}
+	}
+}
+
+// 按 registry 和 proto 描述生成的示例中 map、bytes 和 well-known type 的值
+func TestSkeletonTypes(t *testing.T) {
+	users := testService("go.micro.srv.users", new(Users))
+	clock := testService("go.micro.srv.clock", new(Clock))
+	team := profiles.get("team", "")
+	d := testDescriptors()
+	render := func(s *registry.Service, endpoint string) interface{} {
+		return skeletonOf(catalog(s), testEndpoint(s, endpoint).Request, team.values(0))
+	}
+	get, update, schedule := render(users, "Users.Get"), render(users, "Users.Update"), render(clock, "Clock.Schedule")
+	proto, _ := d.skeleton(".test.User", team.values(0))
+
+	tests := []struct {
+		name     string
+		skeleton interface{}
+		path     string
+		want     string
+	}{
+		{name: "bytes", skeleton: get, path: "avatar", want: `"emhvdXhpYW9qdW4="`},
+		// extractor 不给出 map 的键和值的类型
+		{name: "map", skeleton: get, path: "scores", want: `{}`},
+		{name: "map of strings", skeleton: update, path: "labels", want: `{}`},
+		{name: "timestamp", skeleton: get, path: "created_at", want: `"2019-11-11T11:11:11Z"`},
+		{name: "wrapper", skeleton: get, path: "age", want: `64`},
+		{name: "duration", skeleton: update, path: "ttl", want: `"1.5s"`},
+		{name: "struct", skeleton: update, path: "extra", want: `{"key":"value"}`},
+		{name: "any", skeleton: update, path: "detail", want: `{"@type":"type.googleapis.com/google.protobuf.StringValue","value":"zhouxiaojun"}`},
+		{name: "unexpanded timestamp", skeleton: update, path: "user.created_at", want: `"2019-11-11T11:11:11Z"`},
+		{name: "business timestamp", skeleton: schedule, path: "at", want: `{"unix":64,"zone":"zhouxiaojun"}`},
+		{name: "business any", skeleton: schedule, path: "payload", want: `{"kind":"zhouxiaojun"}`},
+		{name: "proto bytes", skeleton: proto, path: "avatar", want: `"emhvdXhpYW9qdW4="`},
+		{name: "proto map", skeleton: proto, path: "scores", want: `{"zhouxiaojun":64}`},
+		{name: "proto timestamp", skeleton: proto, path: "created_at", want: `"2019-11-11T11:11:11Z"`},
+		{name: "proto wrapper", skeleton: proto, path: "age", want: `64`},
+	}
+	for _, tt := range tests {
+		v, ok := lookup(tt.skeleton, tt.path)
+		if !ok {
+			t.Errorf("%s: no %s in %s", tt.name, tt.path, compactJSON(t, tt.skeleton))
+			continue
+		}
+		if got := compactJSON(t, v); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestSchemaTypes(t *testing.T) {
+	users := testService("go.micro.srv.users", new(Users))
+	clock := testService("go.micro.srv.clock", new(Clock))
+	schema := func(s *registry.Service, endpoint string) jsonObject {
+		props, _ := valueSchema(catalog(s), testEndpoint(s, endpoint).Request, namingProto).get("properties")
+		return props.(jsonObject)
+	}
+	get, update, schedule := schema(users, "Users.Get"), schema(users, "Users.Update"), schema(clock, "Clock.Schedule")
+
+	tests := []struct {
+		name   string
+		schema jsonObject
+		path   string
+		want   string
+	}{
+		{name: "bytes", schema: get, path: "avatar", want: `{"type":"string","contentEncoding":"base64"}`},
+		{name: "map", schema: get, path: "scores", want: `{"type":"object"}`},
+		{name: "timestamp", schema: get, path: "created_at", want: `{"type":"string","format":"date-time"}`},
+		{name: "wrapper", schema: get, path: "age", want: `{"type":["integer","string"],"format":"int64","pattern":"^-?[0-9]+$"}`},
+		{name: "duration", schema: update, path: "ttl", want: `{"type":"string","pattern":"^-?[0-9]+(\\.[0-9]{1,9})?s$"}`},
+		{name: "business timestamp", schema: schedule, path: "at", want: `{"$ref":"#/$defs/Timestamp"}`},
+	}
+	for _, tt := range tests {
+		v, ok := lookup(tt.schema, tt.path)
+		if !ok {
+			t.Errorf("%s: no %s in %s", tt.name, tt.path, compactJSON(t, tt.schema))
+			continue
+		}
+		if got := compactJSON(t, v); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestWellKnownSchema(t *testing.T) {
+	tests := []struct {
+		full string
+		want string
+	}{
+		{full: "google.protobuf.Timestamp", want: `{"type":"string","format":"date-time"}`},
+		{full: "google.protobuf.Empty", want: `{"type":"object","additionalProperties":false}`},
+		{full: "google.protobuf.Value", want: `{}`},
+		{full: "google.protobuf.BytesValue", want: `{"type":"string","contentEncoding":"base64"}`},
+		{full: "google.protobuf.BoolValue", want: `{"type":"boolean"}`},
+		{full: "google.protobuf.UInt64Value", want: `{"type":["integer","string"],"format":"uint64","minimum":0,"pattern":"^[0-9]+$"}`},
+	}
+	for _, tt := range tests {
+		if got := compactJSON(t, wellKnownSchema(tt.full)); got != tt.want {
+			t.Errorf("wellKnownSchema(%q) = %s, expected %s", tt.full, got, tt.want)
+		}
+	}
+}
+
+func TestWellKnownValue(t *testing.T) {
+	zero := profiles.get("zero", "").values(0)
+	team := profiles.get("team", "").values(0)
+	tests := []struct {
+		name   string
+		values valueSource
+		full   string
+		want   string
+	}{
+		{name: "zero timestamp", values: zero, full: "google.protobuf.Timestamp", want: `"1970-01-01T00:00:00Z"`},
+		{name: "zero wrapper", values: zero, full: "google.protobuf.Int64Value", want: `0`},
+		{name: "zero bytes", values: zero, full: "google.protobuf.BytesValue", want: `""`},
+		{name: "team duration", values: team, full: "google.protobuf.Duration", want: `"1.5s"`},
+		{name: "team bytes", values: team, full: "google.protobuf.BytesValue", want: `"emhvdXhpYW9qdW4="`},
+		{name: "team any", values: team, full: "google.protobuf.Any", want: `{"@type":"type.googleapis.com/google.protobuf.StringValue","value":"zhouxiaojun"}`},
+	}
+	for _, tt := range tests {
+		if got := compactJSON(t, wellKnownValue(tt.values, "field", tt.full)); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}